@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"trading_platform_backend/model"
 	"trading_platform_backend/service"
+	"trading_platform_backend/util"
 )
 
 func GetDashboard(w http.ResponseWriter, r *http.Request) {
@@ -105,10 +106,19 @@ func BuyStocks(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	userId := int64(getClaims(r).UserID)
+
 	type TradeRequest struct {
-		UserID   int64  `json:"userId"`
-		Ticker   string `json:"ticker"`
-		Quantity int64  `json:"quantity"`
+		Ticker       string  `json:"ticker"`
+		Quantity     float64 `json:"quantity"`
+		Amount       float64 `json:"amount"`
+		OrderType    string  `json:"orderType"`
+		TimeInForce  string  `json:"timeInForce"`
+		LimitPrice   float64 `json:"limitPrice"`
+		StopPrice    float64 `json:"stopPrice"`
+		TrailAmount  float64 `json:"trailAmount"`
+		TrailPercent float64 `json:"trailPercent"`
+		TaxLotIDs    []int64 `json:"taxLotIds"`
 	}
 
 	var payload TradeRequest
@@ -118,16 +128,44 @@ func BuyStocks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if payload.UserID == 0 || payload.Quantity == 0 || payload.Ticker == "" {
+	if (payload.Quantity == 0) == (payload.Amount == 0) || payload.Ticker == "" {
 		response = getErrorApiResponse("Invalid payload")
 		return
 	}
 
-	result := service.BuyStocks(payload.UserID, payload.Ticker, payload.Quantity)
-	if result == "" {
+	if (payload.OrderType == util.OrderTypeLimit || payload.OrderType == util.OrderTypeStopLimit) && payload.LimitPrice <= 0 {
+		response = getErrorApiResponse("limitPrice is required for limit orders")
+		return
+	}
+
+	if (payload.OrderType == util.OrderTypeStop || payload.OrderType == util.OrderTypeStopLimit) && payload.StopPrice <= 0 {
+		response = getErrorApiResponse("stopPrice is required for stop orders")
+		return
+	}
+
+	if payload.OrderType == util.OrderTypeTrailingStop && payload.TrailAmount <= 0 && payload.TrailPercent <= 0 {
+		response = getErrorApiResponse("trailAmount or trailPercent is required for trailing stop orders")
+		return
+	}
+
+	err = service.PlaceOrder(model.OrderRequest{
+		UserID:           userId,
+		Ticker:           payload.Ticker,
+		TradeType:        util.TradeTypeBuy,
+		OrderType:        payload.OrderType,
+		TimeInForce:      payload.TimeInForce,
+		Quantity:         util.ConvertSharesToQuantity(payload.Quantity),
+		AmountCents:      util.ConvertDollarsToCents(payload.Amount),
+		LimitPriceCents:  util.ConvertDollarsToCents(payload.LimitPrice),
+		StopPriceCents:   util.ConvertDollarsToCents(payload.StopPrice),
+		TrailAmountCents: util.ConvertDollarsToCents(payload.TrailAmount),
+		TrailPercent:     payload.TrailPercent,
+		TaxLotIDs:        payload.TaxLotIDs,
+	})
+	if err == nil {
 		response = getSuccessApiResponse("")
 	} else {
-		response = getErrorApiResponse(result)
+		response = getErrorCodeApiResponse(err)
 	}
 }
 
@@ -145,10 +183,19 @@ func SellStocks(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	userId := int64(getClaims(r).UserID)
+
 	type TradeRequest struct {
-		UserID   int64  `json:"userId"`
-		Ticker   string `json:"ticker"`
-		Quantity int64  `json:"quantity"`
+		Ticker       string  `json:"ticker"`
+		Quantity     float64 `json:"quantity"`
+		Amount       float64 `json:"amount"`
+		OrderType    string  `json:"orderType"`
+		TimeInForce  string  `json:"timeInForce"`
+		LimitPrice   float64 `json:"limitPrice"`
+		StopPrice    float64 `json:"stopPrice"`
+		TrailAmount  float64 `json:"trailAmount"`
+		TrailPercent float64 `json:"trailPercent"`
+		TaxLotIDs    []int64 `json:"taxLotIds"`
 	}
 
 	var payload TradeRequest
@@ -158,16 +205,44 @@ func SellStocks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if payload.UserID == 0 || payload.Quantity == 0 || payload.Ticker == "" {
+	if (payload.Quantity == 0) == (payload.Amount == 0) || payload.Ticker == "" {
 		response = getErrorApiResponse("Invalid payload")
 		return
 	}
 
-	result := service.SellStocks(payload.UserID, payload.Ticker, payload.Quantity)
-	if result == "" {
+	if (payload.OrderType == util.OrderTypeLimit || payload.OrderType == util.OrderTypeStopLimit) && payload.LimitPrice <= 0 {
+		response = getErrorApiResponse("limitPrice is required for limit orders")
+		return
+	}
+
+	if (payload.OrderType == util.OrderTypeStop || payload.OrderType == util.OrderTypeStopLimit) && payload.StopPrice <= 0 {
+		response = getErrorApiResponse("stopPrice is required for stop orders")
+		return
+	}
+
+	if payload.OrderType == util.OrderTypeTrailingStop && payload.TrailAmount <= 0 && payload.TrailPercent <= 0 {
+		response = getErrorApiResponse("trailAmount or trailPercent is required for trailing stop orders")
+		return
+	}
+
+	err = service.PlaceOrder(model.OrderRequest{
+		UserID:           userId,
+		Ticker:           payload.Ticker,
+		TradeType:        util.TradeTypeSell,
+		OrderType:        payload.OrderType,
+		TimeInForce:      payload.TimeInForce,
+		Quantity:         util.ConvertSharesToQuantity(payload.Quantity),
+		AmountCents:      util.ConvertDollarsToCents(payload.Amount),
+		LimitPriceCents:  util.ConvertDollarsToCents(payload.LimitPrice),
+		StopPriceCents:   util.ConvertDollarsToCents(payload.StopPrice),
+		TrailAmountCents: util.ConvertDollarsToCents(payload.TrailAmount),
+		TrailPercent:     payload.TrailPercent,
+		TaxLotIDs:        payload.TaxLotIDs,
+	})
+	if err == nil {
 		response = getSuccessApiResponse("")
 	} else {
-		response = getErrorApiResponse(result)
+		response = getErrorCodeApiResponse(err)
 	}
 }
 
@@ -275,6 +350,251 @@ func GetOrders(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func GetOrderBook(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	stockIdStr := r.URL.Query().Get("stockId")
+
+	if stockIdStr == "" {
+		response = getErrorApiResponse("stockId is required")
+		return
+	}
+
+	stockId, err := strconv.ParseInt(stockIdStr, 10, 64)
+	if err != nil {
+		response = getErrorApiResponse("stockId is invalid")
+		return
+	}
+
+	levels := util.OrderBookDepthLevels
+	if levelsStr := r.URL.Query().Get("levels"); levelsStr != "" {
+		levels, err = strconv.Atoi(levelsStr)
+		if err != nil || levels <= 0 {
+			response = getErrorApiResponse("levels is invalid")
+			return
+		}
+	}
+
+	response = getSuccessApiResponse(service.GetOrderBookDepth(stockId, levels))
+}
+
+func GetNotifications(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userIdStr := r.URL.Query().Get("userId")
+
+	if userIdStr == "" {
+		response = getErrorApiResponse("userId is required")
+		return
+	}
+
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		response = getErrorApiResponse("userId is invalid")
+		return
+	}
+
+	response = getSuccessApiResponse(service.GetNotifications(userId))
+}
+
+func GetTaxLots(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userIdStr := r.URL.Query().Get("userId")
+
+	if userIdStr == "" {
+		response = getErrorApiResponse("userId is required")
+		return
+	}
+
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		response = getErrorApiResponse("userId is invalid")
+		return
+	}
+
+	response = getSuccessApiResponse(service.GetTaxLots(userId))
+}
+
+func GetRealizedLots(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userIdStr := r.URL.Query().Get("userId")
+
+	if userIdStr == "" {
+		response = getErrorApiResponse("userId is required")
+		return
+	}
+
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		response = getErrorApiResponse("userId is invalid")
+		return
+	}
+
+	response = getSuccessApiResponse(service.GetRealizedLots(userId))
+}
+
+func GetLedger(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userIdStr := r.URL.Query().Get("userId")
+
+	if userIdStr == "" {
+		response = getErrorApiResponse("userId is required")
+		return
+	}
+
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		response = getErrorApiResponse("userId is invalid")
+		return
+	}
+
+	response = getSuccessApiResponse(service.GetLedger(userId))
+}
+
+func ReconcileLedger(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userIdStr := r.URL.Query().Get("userId")
+
+	if userIdStr == "" {
+		response = getErrorApiResponse("userId is required")
+		return
+	}
+
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		response = getErrorApiResponse("userId is invalid")
+		return
+	}
+
+	reconciliation, err := service.ReconcileLedger(userId)
+	if err != nil {
+		response = getErrorApiResponse(err.Error())
+	} else {
+		response = getSuccessApiResponse(reconciliation)
+	}
+}
+
+func GetFeeSchedules(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	response = getSuccessApiResponse(service.GetFeeSchedules())
+}
+
+func SetFeeSchedule(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	type SetFeeScheduleRequest struct {
+		UserID      int64  `json:"userId"`
+		FeeSchedule string `json:"feeSchedule"`
+	}
+
+	var payload SetFeeScheduleRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.UserID == 0 || payload.FeeSchedule == "" {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	err = service.SetFeeSchedule(payload.UserID, payload.FeeSchedule)
+	if err != nil {
+		response = getErrorApiResponse("Failed to set fee schedule, " + err.Error())
+	} else {
+		response = getSuccessApiResponse("")
+	}
+}
+
 func AddStockToWatchlist(w http.ResponseWriter, r *http.Request) {
 
 	var response model.ApiResponse
@@ -293,6 +613,9 @@ func AddStockToWatchlist(w http.ResponseWriter, r *http.Request) {
 		UserId      int32   `json:"userId"`
 		StockId     int32   `json:"stockId"`
 		TargetPrice float64 `json:"targetPrice"`
+		AlertPolicy string  `json:"alertPolicy"`
+		Action      string  `json:"action"`
+		Quantity    float64 `json:"quantity"` // shares bought by a BUY action
 	}
 
 	var payload AddWatchlistRequest
@@ -307,7 +630,8 @@ func AddStockToWatchlist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = service.AddStockToWatchlist(payload.UserId, payload.StockId, payload.TargetPrice)
+	err = service.AddStockToWatchlist(payload.UserId, payload.StockId, payload.TargetPrice, payload.AlertPolicy, payload.Action,
+		util.ConvertSharesToQuantity(payload.Quantity))
 	if err != nil {
 		response = getErrorApiResponse(err.Error())
 	} else {
@@ -376,7 +700,7 @@ func UpdateUserSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId := payload["userId"].(float64)
+	userId := int64(getClaims(r).UserID)
 
 	settings := payload["settings"].(map[string]interface{})
 	if len(settings) == 0 {
@@ -385,7 +709,7 @@ func UpdateUserSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, resRrr := service.UpdateUserSettings(int64(userId), settings)
+	user, resRrr := service.UpdateUserSettings(userId, settings)
 	if resRrr != nil {
 		fmt.Println(resRrr)
 		response = getErrorApiResponse(resRrr.Error())
@@ -491,3 +815,864 @@ func GetStockNews(w http.ResponseWriter, r *http.Request) {
 	news := service.GetStockNewsWithPagination(stockID, page)
 	response = getSuccessApiResponse(news)
 }
+
+func CancelOrder(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	type CancelOrderRequest struct {
+		OrderID int64 `json:"orderId"`
+	}
+
+	var payload CancelOrderRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	if payload.OrderID == 0 {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	//only the owner of the token can cancel its orders
+	err = service.CancelOrder(int64(getClaims(r).UserID), payload.OrderID)
+	if err != nil {
+		response = getErrorApiResponse("Failed to cancel order, " + err.Error())
+	} else {
+		response = getSuccessApiResponse("")
+	}
+}
+
+func AmendOrder(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	type AmendOrderRequest struct {
+		OrderID    int64   `json:"orderId"`
+		Quantity   float64 `json:"quantity"`
+		LimitPrice float64 `json:"limitPrice"`
+	}
+
+	var payload AmendOrderRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	if payload.OrderID == 0 || (payload.Quantity == 0 && payload.LimitPrice == 0) {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	//only the owner of the token can amend its orders
+	err = service.AmendOrder(int64(getClaims(r).UserID), payload.OrderID, util.ConvertSharesToQuantity(payload.Quantity), util.ConvertDollarsToCents(payload.LimitPrice))
+	if err != nil {
+		response = getErrorApiResponse("Failed to amend order, " + err.Error())
+	} else {
+		response = getSuccessApiResponse("")
+	}
+}
+
+func PlaceBracketOrder(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userId := int64(getClaims(r).UserID)
+
+	type BracketOrderRequest struct {
+		Ticker          string  `json:"ticker"`
+		TradeType       string  `json:"tradeType"`
+		Quantity        float64 `json:"quantity"`
+		OrderType       string  `json:"orderType"`
+		TimeInForce     string  `json:"timeInForce"`
+		LimitPrice      float64 `json:"limitPrice"`
+		TakeProfitPrice float64 `json:"takeProfitPrice"`
+		StopLossPrice   float64 `json:"stopLossPrice"`
+	}
+
+	var payload BracketOrderRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	if payload.Quantity <= 0 || payload.Ticker == "" ||
+		(payload.TradeType != util.TradeTypeBuy && payload.TradeType != util.TradeTypeSell) ||
+		payload.TakeProfitPrice <= 0 || payload.StopLossPrice <= 0 {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	err = service.PlaceBracketOrder(model.OrderRequest{
+		UserID:          userId,
+		Ticker:          payload.Ticker,
+		TradeType:       payload.TradeType,
+		OrderType:       payload.OrderType,
+		TimeInForce:     payload.TimeInForce,
+		Quantity:        util.ConvertSharesToQuantity(payload.Quantity),
+		LimitPriceCents: util.ConvertDollarsToCents(payload.LimitPrice),
+	}, util.ConvertDollarsToCents(payload.TakeProfitPrice), util.ConvertDollarsToCents(payload.StopLossPrice))
+	if err == nil {
+		response = getSuccessApiResponse("")
+	} else {
+		response = getErrorCodeApiResponse(err)
+	}
+}
+
+func PlaceOcoOrder(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userId := int64(getClaims(r).UserID)
+
+	type OcoLegRequest struct {
+		TradeType   string  `json:"tradeType"`
+		Quantity    float64 `json:"quantity"`
+		OrderType   string  `json:"orderType"`
+		TimeInForce string  `json:"timeInForce"`
+		LimitPrice  float64 `json:"limitPrice"`
+		StopPrice   float64 `json:"stopPrice"`
+	}
+
+	type OcoOrderRequest struct {
+		Ticker string          `json:"ticker"`
+		Legs   []OcoLegRequest `json:"legs"`
+	}
+
+	var payload OcoOrderRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	if payload.Ticker == "" || len(payload.Legs) != 2 {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	legRequests := make([]model.OrderRequest, 0)
+	for _, leg := range payload.Legs {
+		if leg.TradeType != util.TradeTypeBuy && leg.TradeType != util.TradeTypeSell {
+			response = getErrorApiResponse("Invalid payload")
+			return
+		}
+		legRequests = append(legRequests, model.OrderRequest{
+			UserID:          userId,
+			Ticker:          payload.Ticker,
+			TradeType:       leg.TradeType,
+			OrderType:       leg.OrderType,
+			TimeInForce:     leg.TimeInForce,
+			Quantity:        util.ConvertSharesToQuantity(leg.Quantity),
+			LimitPriceCents: util.ConvertDollarsToCents(leg.LimitPrice),
+			StopPriceCents:  util.ConvertDollarsToCents(leg.StopPrice),
+		})
+	}
+
+	err = service.PlaceOcoOrder(legRequests)
+	if err == nil {
+		response = getSuccessApiResponse("")
+	} else {
+		response = getErrorCodeApiResponse(err)
+	}
+}
+
+func PlaceBasketOrder(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userId := int64(getClaims(r).UserID)
+
+	type BasketLegRequest struct {
+		Ticker    string  `json:"ticker"`
+		TradeType string  `json:"tradeType"`
+		Quantity  float64 `json:"quantity"`
+		Amount    float64 `json:"amount"`
+	}
+
+	type BasketOrderRequest struct {
+		ExecutionMode string             `json:"executionMode"`
+		Legs          []BasketLegRequest `json:"legs"`
+	}
+
+	var payload BasketOrderRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.ExecutionMode == "" || len(payload.Legs) == 0 {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	legRequests := make([]model.OrderRequest, len(payload.Legs))
+	for i, leg := range payload.Legs {
+		legRequests[i] = model.OrderRequest{
+			UserID:      userId,
+			Ticker:      leg.Ticker,
+			TradeType:   leg.TradeType,
+			OrderType:   util.OrderTypeMarket,
+			Quantity:    util.ConvertSharesToQuantity(leg.Quantity),
+			AmountCents: util.ConvertDollarsToCents(leg.Amount),
+		}
+	}
+
+	basket, err := service.PlaceBasketOrder(model.BasketOrderRequest{
+		UserID:        userId,
+		ExecutionMode: payload.ExecutionMode,
+		Legs:          legRequests,
+	})
+	if err != nil {
+		response = getErrorApiResponse("Failed to place basket order, " + err.Error())
+	} else {
+		response = getSuccessApiResponse(basket)
+	}
+}
+
+func GetCorporateActions(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	var stockId int64
+	stockIdStr := r.URL.Query().Get("stockId")
+
+	if stockIdStr != "" {
+		var err error
+		stockId, err = strconv.ParseInt(stockIdStr, 10, 64)
+		if err != nil {
+			response = getErrorApiResponse("stockId is invalid")
+			return
+		}
+	}
+
+	response = getSuccessApiResponse(service.GetCorporateActions(stockId))
+}
+
+func ScheduleCorporateAction(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	type CorporateActionRequest struct {
+		Ticker           string  `json:"ticker"`
+		ActionType       string  `json:"actionType"`
+		SplitFrom        int64   `json:"splitFrom"`
+		SplitTo          int64   `json:"splitTo"`
+		DividendPerShare float64 `json:"dividendPerShare"`
+		ExDate           string  `json:"exDate"`
+		PayDate          string  `json:"payDate"`
+	}
+
+	var payload CorporateActionRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	if payload.Ticker == "" || payload.ActionType == "" || payload.ExDate == "" {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	corporateAction, err := service.ScheduleCorporateAction(model.CorporateActionRequest{
+		Ticker:                payload.Ticker,
+		ActionType:            payload.ActionType,
+		SplitFrom:             payload.SplitFrom,
+		SplitTo:               payload.SplitTo,
+		DividendPerShareCents: util.ConvertDollarsToCents(payload.DividendPerShare),
+		ExDate:                payload.ExDate,
+		PayDate:               payload.PayDate,
+		CreatedBy:             int64(getClaims(r).UserID),
+	})
+	if err != nil {
+		response = getErrorApiResponse("Failed to schedule corporate action, " + err.Error())
+	} else {
+		response = getSuccessApiResponse(corporateAction)
+	}
+}
+
+func CancelCorporateAction(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	type CancelCorporateActionRequest struct {
+		CorporateActionID int64 `json:"corporateActionId"`
+	}
+
+	var payload CancelCorporateActionRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.CorporateActionID == 0 {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	err = service.CancelCorporateAction(payload.CorporateActionID)
+	if err != nil {
+		response = getErrorApiResponse("Failed to cancel corporate action, " + err.Error())
+	} else {
+		response = getSuccessApiResponse("")
+	}
+}
+
+func GetMarketSession(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	response = getSuccessApiResponse(service.GetMarketSession())
+}
+
+func GetOptionChain(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	stockIdStr := r.URL.Query().Get("stockId")
+
+	if stockIdStr == "" {
+		response = getErrorApiResponse("stockId is required")
+		return
+	}
+
+	stockId, err := strconv.ParseInt(stockIdStr, 10, 64)
+	if err != nil {
+		response = getErrorApiResponse("stockId is invalid")
+		return
+	}
+
+	chain, err := service.GetOptionChain(stockId)
+	if err != nil {
+		response = getErrorApiResponse("Failed to get option chain, " + err.Error())
+	} else {
+		response = getSuccessApiResponse(chain)
+	}
+}
+
+func PlaceOptionOrder(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userId := int64(getClaims(r).UserID)
+
+	type OptionOrderRequest struct {
+		OptionContractID int64   `json:"optionContractId"`
+		TradeType        string  `json:"tradeType"`
+		OrderType        string  `json:"orderType"`
+		Quantity         int64   `json:"quantity"`
+		LimitPrice       float64 `json:"limitPrice"`
+	}
+
+	var payload OptionOrderRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	if payload.OptionContractID == 0 || payload.Quantity <= 0 {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	err = service.PlaceOptionOrder(model.OptionOrderRequest{
+		UserID:           userId,
+		OptionContractID: payload.OptionContractID,
+		TradeType:        payload.TradeType,
+		OrderType:        payload.OrderType,
+		Quantity:         payload.Quantity,
+		LimitPriceCents:  util.ConvertDollarsToCents(payload.LimitPrice),
+	})
+	if err != nil {
+		response = getErrorApiResponse("Failed to place option order, " + err.Error())
+	} else {
+		response = getSuccessApiResponse("")
+	}
+}
+
+func CancelOptionOrder(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userId := int64(getClaims(r).UserID)
+
+	type CancelOptionOrderRequest struct {
+		OptionOrderID int64 `json:"optionOrderId"`
+	}
+
+	var payload CancelOptionOrderRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.OptionOrderID == 0 {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	err = service.CancelOptionOrder(userId, payload.OptionOrderID)
+	if err != nil {
+		response = getErrorApiResponse("Failed to cancel option order, " + err.Error())
+	} else {
+		response = getSuccessApiResponse("")
+	}
+}
+
+func GetOptionOrders(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userId := int64(getClaims(r).UserID)
+
+	response = getSuccessApiResponse(service.GetOptionOrders(userId))
+}
+
+func GetFxRates(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	response = getSuccessApiResponse(service.GetFxRates())
+}
+
+func ConvertCurrency(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userId := int64(getClaims(r).UserID)
+
+	type ConvertCurrencyRequest struct {
+		FromCurrency string  `json:"fromCurrency"`
+		ToCurrency   string  `json:"toCurrency"`
+		Amount       float64 `json:"amount"` // in FromCurrency
+	}
+
+	var payload ConvertCurrencyRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.FromCurrency == "" || payload.ToCurrency == "" || payload.Amount <= 0 {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	conversion, err := service.ConvertCurrency(userId, payload.FromCurrency, payload.ToCurrency, util.ConvertDollarsToCents(payload.Amount))
+	if err != nil {
+		response = getErrorApiResponse("Failed to convert currency, " + err.Error())
+	} else {
+		response = getSuccessApiResponse(conversion)
+	}
+}
+
+func SaveTargetAllocation(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userId := int64(getClaims(r).UserID)
+
+	type TargetAllocationRequest struct {
+		Targets               map[string]float64 `json:"targets"` // ticker to percent
+		CashPercent           float64            `json:"cashPercent"`
+		DriftThresholdPercent float64            `json:"driftThresholdPercent"`
+		MinTrade              float64            `json:"minTrade"`
+		RebalanceFrequency    string             `json:"rebalanceFrequency"`
+	}
+
+	var payload TargetAllocationRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || len(payload.Targets) == 0 {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	allocation, err := service.SaveTargetAllocation(model.TargetAllocationRequest{
+		UserID:                userId,
+		Targets:               payload.Targets,
+		CashPercent:           payload.CashPercent,
+		DriftThresholdPercent: payload.DriftThresholdPercent,
+		MinTradeCents:         util.ConvertDollarsToCents(payload.MinTrade),
+		RebalanceFrequency:    payload.RebalanceFrequency,
+	})
+	if err != nil {
+		response = getErrorApiResponse("Failed to save target allocation, " + err.Error())
+	} else {
+		response = getSuccessApiResponse(allocation)
+	}
+}
+
+func GetTargetAllocation(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userIdStr := r.URL.Query().Get("userId")
+
+	if userIdStr == "" {
+		response = getErrorApiResponse("userId is required")
+		return
+	}
+
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		response = getErrorApiResponse("userId is invalid")
+		return
+	}
+
+	allocation, err := service.GetTargetAllocation(userId)
+	if err != nil {
+		response = getErrorApiResponse("Failed to get target allocation, " + err.Error())
+	} else {
+		response = getSuccessApiResponse(allocation)
+	}
+}
+
+func GetRebalancePreview(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userIdStr := r.URL.Query().Get("userId")
+
+	if userIdStr == "" {
+		response = getErrorApiResponse("userId is required")
+		return
+	}
+
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		response = getErrorApiResponse("userId is invalid")
+		return
+	}
+
+	preview, err := service.GetRebalancePreview(userId)
+	if err != nil {
+		response = getErrorApiResponse("Failed to preview rebalance, " + err.Error())
+	} else {
+		response = getSuccessApiResponse(preview)
+	}
+}
+
+func ExecuteRebalance(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	rebalance, err := service.ExecuteRebalance(int64(getClaims(r).UserID))
+	if err != nil {
+		response = getErrorApiResponse("Failed to rebalance, " + err.Error())
+	} else {
+		response = getSuccessApiResponse(rebalance)
+	}
+}
+
+func CreateRecurringInvestment(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userId := int64(getClaims(r).UserID)
+
+	type RecurringInvestmentRequest struct {
+		Ticker     string  `json:"ticker"`
+		Amount     float64 `json:"amount"` // in the stock's currency
+		Cadence    string  `json:"cadence"`
+		FirstRunAt string  `json:"firstRunAt"`
+	}
+
+	var payload RecurringInvestmentRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.Ticker == "" || payload.Cadence == "" {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	recurringInvestment, err := service.CreateRecurringInvestment(model.RecurringInvestmentRequest{
+		UserID:      userId,
+		Ticker:      payload.Ticker,
+		AmountCents: util.ConvertDollarsToCents(payload.Amount),
+		Cadence:     payload.Cadence,
+		FirstRunAt:  payload.FirstRunAt,
+	})
+	if err != nil {
+		response = getErrorApiResponse("Failed to create recurring investment, " + err.Error())
+	} else {
+		response = getSuccessApiResponse(recurringInvestment)
+	}
+}
+
+func GetRecurringInvestments(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userId := int64(getClaims(r).UserID)
+
+	response = getSuccessApiResponse(service.GetRecurringInvestments(userId))
+}
+
+func PauseRecurringInvestment(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userId := int64(getClaims(r).UserID)
+
+	type RecurringInvestmentRequest struct {
+		RecurringInvestmentID int64 `json:"recurringInvestmentId"`
+	}
+
+	var payload RecurringInvestmentRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.RecurringInvestmentID == 0 {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	err = service.PauseRecurringInvestment(userId, payload.RecurringInvestmentID)
+	if err != nil {
+		response = getErrorApiResponse("Failed to pause recurring investment, " + err.Error())
+	} else {
+		response = getSuccessApiResponse("")
+	}
+}
+
+func ResumeRecurringInvestment(w http.ResponseWriter, r *http.Request) {
+
+	var response model.ApiResponse
+
+	//LIFO
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic(err)
+		}
+	}()
+
+	userId := int64(getClaims(r).UserID)
+
+	type RecurringInvestmentRequest struct {
+		RecurringInvestmentID int64 `json:"recurringInvestmentId"`
+	}
+
+	var payload RecurringInvestmentRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.RecurringInvestmentID == 0 {
+		response = getErrorApiResponse("Invalid payload")
+		return
+	}
+
+	err = service.ResumeRecurringInvestment(userId, payload.RecurringInvestmentID)
+	if err != nil {
+		response = getErrorApiResponse("Failed to resume recurring investment, " + err.Error())
+	} else {
+		response = getSuccessApiResponse("")
+	}
+}
@@ -45,9 +45,40 @@ func registerRoutes() *http.ServeMux {
 	apiMux.HandleFunc("/stock-news", GetStockNews)
 	apiMux.HandleFunc("/user", JwtMiddleware(GetUserByEmailAndPassword))
 	apiMux.HandleFunc("/user/v2", JwtMiddleware(GetUserById))
-	apiMux.HandleFunc("/buy-stocks", JwtMiddleware(BuyStocks))
-	apiMux.HandleFunc("/sell-stocks", JwtMiddleware(SellStocks))
+	apiMux.HandleFunc("/buy-stocks", JwtMiddleware(IdempotencyMiddleware(BuyStocks)))
+	apiMux.HandleFunc("/sell-stocks", JwtMiddleware(IdempotencyMiddleware(SellStocks)))
 	apiMux.HandleFunc("/orders", JwtMiddleware(GetOrders))
+	apiMux.HandleFunc("/order-book", JwtMiddleware(GetOrderBook))
+	apiMux.HandleFunc("/cancel-order", JwtMiddleware(CancelOrder))
+	apiMux.HandleFunc("/amend-order", JwtMiddleware(AmendOrder))
+	apiMux.HandleFunc("/bracket-order", JwtMiddleware(IdempotencyMiddleware(PlaceBracketOrder)))
+	apiMux.HandleFunc("/oco-order", JwtMiddleware(IdempotencyMiddleware(PlaceOcoOrder)))
+	apiMux.HandleFunc("/basket-orders", JwtMiddleware(IdempotencyMiddleware(PlaceBasketOrder)))
+	apiMux.HandleFunc("/target-allocation", JwtMiddleware(GetTargetAllocation))
+	apiMux.HandleFunc("/save-target-allocation", JwtMiddleware(SaveTargetAllocation))
+	apiMux.HandleFunc("/rebalance-preview", JwtMiddleware(GetRebalancePreview))
+	apiMux.HandleFunc("/rebalance", JwtMiddleware(IdempotencyMiddleware(ExecuteRebalance)))
+	apiMux.HandleFunc("/recurring-investment", JwtMiddleware(IdempotencyMiddleware(CreateRecurringInvestment)))
+	apiMux.HandleFunc("/recurring-investments", JwtMiddleware(GetRecurringInvestments))
+	apiMux.HandleFunc("/pause-recurring-investment", JwtMiddleware(PauseRecurringInvestment))
+	apiMux.HandleFunc("/resume-recurring-investment", JwtMiddleware(ResumeRecurringInvestment))
+	apiMux.HandleFunc("/notifications", JwtMiddleware(GetNotifications))
+	apiMux.HandleFunc("/fee-schedules", JwtMiddleware(GetFeeSchedules))
+	apiMux.HandleFunc("/set-fee-schedule", JwtMiddleware(AdminMiddleware(SetFeeSchedule)))
+	apiMux.HandleFunc("/tax-lots", JwtMiddleware(GetTaxLots))
+	apiMux.HandleFunc("/realized-lots", JwtMiddleware(GetRealizedLots))
+	apiMux.HandleFunc("/ledger", JwtMiddleware(GetLedger))
+	apiMux.HandleFunc("/ledger/reconcile", JwtMiddleware(ReconcileLedger))
+	apiMux.HandleFunc("/market-session", JwtMiddleware(GetMarketSession))
+	apiMux.HandleFunc("/corporate-actions", JwtMiddleware(GetCorporateActions))
+	apiMux.HandleFunc("/schedule-corporate-action", JwtMiddleware(AdminMiddleware(ScheduleCorporateAction)))
+	apiMux.HandleFunc("/cancel-corporate-action", JwtMiddleware(AdminMiddleware(CancelCorporateAction)))
+	apiMux.HandleFunc("/option-chain", JwtMiddleware(GetOptionChain))
+	apiMux.HandleFunc("/option-order", JwtMiddleware(IdempotencyMiddleware(PlaceOptionOrder)))
+	apiMux.HandleFunc("/cancel-option-order", JwtMiddleware(CancelOptionOrder))
+	apiMux.HandleFunc("/option-orders", JwtMiddleware(GetOptionOrders))
+	apiMux.HandleFunc("/fx-rates", JwtMiddleware(GetFxRates))
+	apiMux.HandleFunc("/convert-currency", JwtMiddleware(IdempotencyMiddleware(ConvertCurrency)))
 	apiMux.HandleFunc("/add-stock-watchlist", JwtMiddleware(AddStockToWatchlist))
 	apiMux.HandleFunc("/delete-stock-watchlist", JwtMiddleware(DeleteStockFromWatchlist))
 	apiMux.HandleFunc("/update-user-setting", JwtMiddleware(UpdateUserSettings))
@@ -1,14 +1,24 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"runtime/debug"
 	"strings"
+	"time"
 	"trading_platform_backend/auth"
+	"trading_platform_backend/service"
+	"trading_platform_backend/util"
 )
 
+type claimsContextKey struct{}
+
 func RecoverMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -50,12 +60,96 @@ func JwtMiddleware(next http.HandlerFunc) http.HandlerFunc {
 
 		tokenString := parts[1]
 
-		_, err := auth.ValidateJWT(tokenString)
+		claims, err := auth.ValidateJWT(tokenString)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Invalid token: %v", err), http.StatusUnauthorized)
 			return
 		}
 
+		next(w, r.WithContext(context.WithValue(r.Context(), claimsContextKey{}, claims)))
+	}
+}
+
+// getClaims returns the claims of the token validated by JwtMiddleware.
+func getClaims(r *http.Request) *auth.CustomClaims {
+	claims, _ := r.Context().Value(claimsContextKey{}).(*auth.CustomClaims)
+	return claims
+}
+
+// AdminMiddleware only lets the users flagged as admin through, it runs after JwtMiddleware.
+func AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		claims := getClaims(r)
+		if claims == nil || !service.IsAdmin(int64(claims.UserID)) {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
 		next(w, r)
 	}
 }
+
+// responseRecorder keeps a copy of the response body written by the handler.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (recorder *responseRecorder) Write(b []byte) (int, error) {
+	recorder.body.Write(b)
+	return recorder.ResponseWriter.Write(b)
+}
+
+// IdempotencyMiddleware executes a request sent with an Idempotency-Key header once per user and key,
+// a retry with the same key gets the original response back. Must be wrapped by JwtMiddleware.
+func IdempotencyMiddleware(next http.HandlerFunc) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		idempotencyKey := r.Header.Get(util.IdempotencyKeyHeader)
+		claims := getClaims(r)
+		if idempotencyKey == "" || claims == nil {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(getErrorApiResponse("Invalid payload"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		requestHash := sha256.Sum256(body)
+		userId := int64(claims.UserID)
+
+		savedResponse, found, err := service.BeginIdempotentRequest(userId, idempotencyKey, r.URL.Path, hex.EncodeToString(requestHash[:]), time.Now())
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(getErrorApiResponse(err.Error()))
+			return
+		}
+		if found {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			io.WriteString(w, savedResponse)
+			return
+		}
+
+		completed := false
+		defer func() {
+			//a panicking handler gives the key back so the request can be retried
+			if !completed {
+				service.ReleaseIdempotencyKey(userId, idempotencyKey)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		next(recorder, r)
+
+		service.CompleteIdempotentRequest(userId, idempotencyKey, recorder.body.String())
+		completed = true
+	}
+}
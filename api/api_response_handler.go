@@ -1,7 +1,9 @@
 package api
 
 import (
+	"errors"
 	"trading_platform_backend/model"
+	"trading_platform_backend/service"
 )
 
 func getSuccessApiResponse(data interface{}) model.ApiResponse {
@@ -11,3 +13,13 @@ func getSuccessApiResponse(data interface{}) model.ApiResponse {
 func getErrorApiResponse(errorMessage string) model.ApiResponse {
 	return model.ApiResponse{ErrorMessage: errorMessage}
 }
+
+// getErrorCodeApiResponse adds the reason code of orders rejected by the risk checks.
+func getErrorCodeApiResponse(err error) model.ApiResponse {
+	response := getErrorApiResponse(err.Error())
+	var rejection *service.RiskRejection
+	if errors.As(err, &rejection) {
+		response.ErrorCode = rejection.Code
+	}
+	return response
+}
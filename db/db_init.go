@@ -45,8 +45,5 @@ func InitDB() {
 		fmt.Printf("Error opening database: %q\n", err)
 	} else {
 		fmt.Println("Successfully connected to PostgreSQL database!")
-
-		//reset current price
-		UpdateStocksResetCurrentPrice()
 	}
 }
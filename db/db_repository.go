@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
 
 	"gorm.io/gorm"
 )
@@ -44,10 +45,11 @@ func GetHoldingByUserIdAndStockId(userId int64, stockId int64) orm.Holdings {
 	return holding
 }
 
-func UpdateStocksResetCurrentPrice() {
-	result := DB.Model(&orm.Stocks{}).Where("1 = 1").Update("current_price_cents", gorm.Expr("opening_price_cents"))
+// UpdateStocksRollOpeningPrice makes the current prices the opening prices of the new trading day.
+func UpdateStocksRollOpeningPrice() {
+	result := DB.Model(&orm.Stocks{}).Where("1 = 1").Update("opening_price_cents", gorm.Expr("current_price_cents"))
 	if result.Error != nil {
-		fmt.Println("Failed to reset current stock price, " + result.Error.Error())
+		fmt.Println("Failed to roll over opening stock price, " + result.Error.Error())
 	}
 }
 
@@ -74,9 +76,30 @@ func GetStockWatchlistByUserIdAndStockId(userId int32, stockId int32) orm.StockW
 	return stockWatchlist
 }
 
+// GetStockWatchlistByUserId also returns the watches deactivated by their alert, to show how they ended.
 func GetStockWatchlistByUserId(userId int32) []orm.StockWatchlist {
 	var stockWatchlist []orm.StockWatchlist
-	DB.Where("user_id = ? and is_active = true", userId).Find(&stockWatchlist)
+	DB.Where("user_id = ?", userId).Order("created_at asc").Find(&stockWatchlist)
+	return stockWatchlist
+}
+
+// GetActiveStockWatchlistByStockIdAndTargetRange returns the active watches of a stock with a target price from
+// minPriceCents to maxPriceCents, both included.
+func GetActiveStockWatchlistByStockIdAndTargetRange(stockId int64, minPriceCents int64, maxPriceCents int64) []orm.StockWatchlist {
+	var stockWatchlist []orm.StockWatchlist
+	DB.Where("stock_id = ? and is_active = true and target_price_cents between ? and ?", stockId, minPriceCents, maxPriceCents).
+		Order("stock_watchlist_id").
+		Find(&stockWatchlist)
+	return stockWatchlist
+}
+
+// GetStockWatchlistWithTriggeredActions returns the watches whose price crossed the target while their action
+// could not run yet.
+func GetStockWatchlistWithTriggeredActions() []orm.StockWatchlist {
+	var stockWatchlist []orm.StockWatchlist
+	DB.Where("action_status = ? and last_alerted_at is not null", util.WatchActionStatusPending).
+		Order("last_alerted_at asc").
+		Find(&stockWatchlist)
 	return stockWatchlist
 }
 
@@ -137,3 +160,514 @@ func GetSentimentDataForStock(stockId int64, days int) []orm.NewsArticles {
 		Find(&newsArticles)
 	return newsArticles
 }
+
+func GetPendingOrdersByStockId(stockId int64) []orm.Orders {
+	var orders []orm.Orders
+	DB.Where("stock_id = ? and order_status in ?", stockId, util.FillableOrderStatuses).Order("created_at asc").Find(&orders)
+	return orders
+}
+
+// GetReservedSellQuantityByUserIdAndStockIdTx is the quantity left to fill of the user's pending sell orders of the
+// stock, read through tx.
+func GetReservedSellQuantityByUserIdAndStockIdTx(tx *gorm.DB, userId int64, stockId int64) int64 {
+	var reservedQuantity int64
+	tx.Model(&orm.Orders{}).
+		Select("coalesce(sum(quantity - filled_quantity), 0)").
+		Where("user_id = ? and stock_id = ? and order_status in ? and trade_type = ?", userId, stockId, util.FillableOrderStatuses, util.TradeTypeSell).
+		Scan(&reservedQuantity)
+	return reservedQuantity
+}
+
+func GetOrdersAwaitingTriggerByStockId(stockId int64) []orm.Orders {
+	var orders []orm.Orders
+	DB.Where("stock_id = ? and order_status = ?", stockId, util.OrderStatusAwaitingTrigger).Order("created_at asc").Find(&orders)
+	return orders
+}
+
+func GetOrderById(orderId int64) orm.Orders {
+	var order orm.Orders
+	DB.Find(&order, orderId)
+	return order
+}
+
+func GetOrdersByIds(orderIds []int64) []orm.Orders {
+	var orders []orm.Orders
+	DB.Where("order_id in ?", orderIds).Find(&orders)
+	return orders
+}
+
+func GetOrderIdsByOrderGroupIdTx(tx *gorm.DB, orderGroupId int64) []int64 {
+	var orderIds []int64
+	tx.Model(&orm.Orders{}).Where("order_group_id = ?", orderGroupId).Pluck("order_id", &orderIds)
+	return orderIds
+}
+
+func GetOpenOrderIdsByUserIdTx(tx *gorm.DB, userId int64, openOrderStatuses []string) []int64 {
+	var orderIds []int64
+	tx.Model(&orm.Orders{}).Where("user_id = ? and order_status in ?", userId, openOrderStatuses).Pluck("order_id", &orderIds)
+	return orderIds
+}
+
+// GetOpenDayOrderIdsTx returns the open DAY orders placed before sessionClose.
+func GetOpenDayOrderIdsTx(tx *gorm.DB, openOrderStatuses []string, sessionClose time.Time) []int64 {
+	var orderIds []int64
+	tx.Model(&orm.Orders{}).
+		Where("time_in_force = ? and order_status in ? and created_at < ?", util.TimeInForceDay, openOrderStatuses, sessionClose).
+		Pluck("order_id", &orderIds)
+	return orderIds
+}
+
+func GetOrderAmendmentsByUserId(userId int64) []orm.OrderAmendments {
+	var orderAmendments []orm.OrderAmendments
+	DB.Where("user_id = ?", userId).Order("created_at asc").Find(&orderAmendments)
+	return orderAmendments
+}
+
+// GetReservedMarginCentsByUserIdTx is the initial margin in USD held back for the user's pending buy orders and the
+// part of the pending sell orders not covered by the long holding, which opens a short, read through tx.
+func GetReservedMarginCentsByUserIdTx(tx *gorm.DB, userId int64) int64 {
+	var reservedBuyCents float64
+	tx.Table("orders").
+		Select("coalesce(sum(orders.total_order_value_cents * fx_rates.usd_rate_micros / ? * stocks.initial_margin_percent / 100), 0)", float64(util.FxRateScale)).
+		Joins("join stocks on stocks.stock_id = orders.stock_id").
+		Joins("join fx_rates on fx_rates.currency = stocks.currency").
+		Where("orders.user_id = ? and orders.order_status in ? and orders.trade_type = ?", userId, util.FillableOrderStatuses, util.TradeTypeBuy).
+		Scan(&reservedBuyCents)
+
+	//the sells of a stock close the long holding first, the rest of their value is reserved pro rata
+	sells := tx.Model(&orm.Orders{}).
+		Select("stock_id, sum(quantity - filled_quantity) as quantity, sum(total_order_value_cents) as value_cents").
+		Where("user_id = ? and order_status in ? and trade_type = ?", userId, util.FillableOrderStatuses, util.TradeTypeSell).
+		Group("stock_id")
+
+	var reservedSellCents float64
+	tx.Table("(?) as sells", sells).
+		Select("coalesce(sum(sells.value_cents::float8 * greatest(sells.quantity - greatest(coalesce(holdings.quantity, 0), 0), 0) / sells.quantity"+
+			" * fx_rates.usd_rate_micros / ? * stocks.initial_margin_percent / 100), 0)", float64(util.FxRateScale)).
+		Joins("left join holdings on holdings.user_id = ? and holdings.stock_id = sells.stock_id", userId).
+		Joins("join stocks on stocks.stock_id = sells.stock_id").
+		Joins("join fx_rates on fx_rates.currency = stocks.currency").
+		Where("sells.quantity > 0").
+		Scan(&reservedSellCents)
+
+	return int64(reservedBuyCents + reservedSellCents)
+}
+
+// GetMarginAccountUsers returns the users holding a position or currently under a margin call.
+func GetMarginAccountUsers() []orm.Users {
+	var users []orm.Users
+	DB.Where("user_id in (?) or margin_call_at is not null",
+		DB.Model(&orm.Holdings{}).Select("user_id").Where("quantity != 0")).Find(&users)
+	return users
+}
+
+func GetNotificationsByUserId(userId int64, limit int) []orm.Notifications {
+	var notifications []orm.Notifications
+	DB.Where("user_id = ?", userId).Order("created_at desc").Limit(limit).Find(&notifications)
+	return notifications
+}
+
+func GetAllFeeSchedules() []orm.FeeSchedules {
+	var feeSchedules []orm.FeeSchedules
+	DB.Order("fee_schedule_id asc").Find(&feeSchedules)
+	return feeSchedules
+}
+
+func GetFeeScheduleById(feeScheduleId int64) orm.FeeSchedules {
+	var feeSchedule orm.FeeSchedules
+	DB.Find(&feeSchedule, feeScheduleId)
+	return feeSchedule
+}
+
+func GetFeeScheduleByName(name string) orm.FeeSchedules {
+	var feeSchedule orm.FeeSchedules
+	DB.Where("name = ?", name).Find(&feeSchedule)
+	return feeSchedule
+}
+
+func GetDefaultFeeSchedule() orm.FeeSchedules {
+	var feeSchedule orm.FeeSchedules
+	DB.Where("is_default = true").Order("fee_schedule_id asc").Limit(1).Find(&feeSchedule)
+	return feeSchedule
+}
+
+func GetTotalFeeCentsByUserId(userId int64) int64 {
+	var totalFeeCents int64
+	DB.Model(&orm.Executions{}).
+		Select("coalesce(sum(fee_cents), 0)").
+		Where("user_id = ?", userId).
+		Scan(&totalFeeCents)
+	return totalFeeCents
+}
+
+func GetPendingLimitOrdersByStockId(stockId int64) []orm.Orders {
+	var orders []orm.Orders
+	DB.Where("stock_id = ? and order_status in ? and order_type = ?", stockId, util.FillableOrderStatuses, util.OrderTypeLimit).Find(&orders)
+	return orders
+}
+
+// GetUserByIdTx reads the user through tx, seeing the changes made earlier in the same transaction.
+func GetUserByIdTx(tx *gorm.DB, userId int64) orm.Users {
+	var user orm.Users
+	tx.Find(&user, userId)
+	return user
+}
+
+// GetOrderByIdTx reads the order through tx, seeing the changes made earlier in the same transaction.
+func GetOrderByIdTx(tx *gorm.DB, orderId int64) orm.Orders {
+	var order orm.Orders
+	tx.Find(&order, orderId)
+	return order
+}
+
+// GetActiveHoldingsByUserIDTx reads the user's open positions through tx.
+func GetActiveHoldingsByUserIDTx(tx *gorm.DB, userID int64) []orm.Holdings {
+	var holdings []orm.Holdings
+	tx.Where("user_id = ? and quantity != 0", userID).Find(&holdings)
+	return holdings
+}
+
+// GetHoldingByUserIdAndStockIdTx reads the holding through tx, seeing the changes made earlier in the same transaction.
+func GetHoldingByUserIdAndStockIdTx(tx *gorm.DB, userId int64, stockId int64) orm.Holdings {
+	var holding orm.Holdings
+	tx.Where("user_id = ? and stock_id = ?", userId, stockId).Limit(1).Find(&holding)
+	return holding
+}
+
+func GetExecutionsByUserId(userId int64) []orm.Executions {
+	var executions []orm.Executions
+	DB.Where("user_id = ?", userId).Order("created_at asc, execution_id asc").Find(&executions)
+	return executions
+}
+
+// GetExecutedValueCentsByOrderIdTx is the value of the order's executions, each rounded to the cent like its fill, read through tx.
+func GetExecutedValueCentsByOrderIdTx(tx *gorm.DB, orderId int64) int64 {
+	var executedValueCents int64
+	tx.Model(&orm.Executions{}).
+		Select("coalesce(sum(round(quantity * price_per_share_cents / ?)), 0)::bigint", float64(util.ShareScale)).
+		Where("order_id = ?", orderId).
+		Scan(&executedValueCents)
+	return executedValueCents
+}
+
+func GetIdempotencyKey(userId int64, idempotencyKey string) orm.IdempotencyKeys {
+	var record orm.IdempotencyKeys
+	DB.Where("user_id = ? and idempotency_key = ?", userId, idempotencyKey).Limit(1).Find(&record)
+	return record
+}
+
+func GetOpenTaxLotsByUserId(userId int64) []orm.TaxLots {
+	var taxLots []orm.TaxLots
+	DB.Where("user_id = ? and remaining_quantity > 0", userId).Order("acquired_at asc, tax_lot_id asc").Find(&taxLots)
+	return taxLots
+}
+
+// GetOpenTaxLotsByUserIdAndStockIdTx reads the open lots through tx, oldest first.
+func GetOpenTaxLotsByUserIdAndStockIdTx(tx *gorm.DB, userId int64, stockId int64) []orm.TaxLots {
+	var taxLots []orm.TaxLots
+	tx.Where("user_id = ? and stock_id = ? and remaining_quantity > 0", userId, stockId).
+		Order("acquired_at asc, tax_lot_id asc").
+		Find(&taxLots)
+	return taxLots
+}
+
+func GetRealizedLotsByUserId(userId int64) []orm.RealizedLots {
+	var realizedLots []orm.RealizedLots
+	DB.Where("user_id = ?", userId).Order("closed_at desc, realized_lot_id desc").Find(&realizedLots)
+	return realizedLots
+}
+
+// GetRealizedPnlCentsByUserId is the user's realized PnL per stock id, in cents of the stock's currency.
+func GetRealizedPnlCentsByUserId(userId int64) map[int64]int64 {
+	var rows []struct {
+		StockID          int64
+		RealizedPnlCents int64
+	}
+	DB.Model(&orm.RealizedLots{}).
+		Select("stock_id, sum(realized_pnl_cents) as realized_pnl_cents").
+		Where("user_id = ?", userId).
+		Group("stock_id").
+		Scan(&rows)
+
+	realizedPnls := make(map[int64]int64)
+	for _, row := range rows {
+		realizedPnls[row.StockID] = row.RealizedPnlCents
+	}
+	return realizedPnls
+}
+
+func GetJournalsByUserId(userId int64, limit int) []orm.Journals {
+	var journals []orm.Journals
+	DB.Where("user_id = ?", userId).Order("created_at desc, journal_id desc").Limit(limit).Find(&journals)
+	return journals
+}
+
+func GetJournalEntriesByJournalIds(journalIds []int64) []orm.JournalEntries {
+	var journalEntries []orm.JournalEntries
+	DB.Where("journal_id in ?", journalIds).Order("journal_entry_id asc").Find(&journalEntries)
+	return journalEntries
+}
+
+// GetLedgerCashBalancesByUserId are the user's cash balances in cents per currency derived from the journal.
+func GetLedgerCashBalancesByUserId(userId int64) map[string]int64 {
+	var rows []struct {
+		Currency     string
+		BalanceCents int64
+	}
+	DB.Model(&orm.JournalEntries{}).
+		Select("currency, sum(debit - credit) as balance_cents").
+		Where("user_id = ? and account = ? and stock_id is null", userId, util.LedgerAccountUserCash).
+		Group("currency").
+		Scan(&rows)
+
+	balances := make(map[string]int64)
+	for _, row := range rows {
+		balances[row.Currency] = row.BalanceCents
+	}
+	return balances
+}
+
+// GetLedgerPositionsByUserId are the user's positions in millionths of a share per stock id derived from the journal.
+func GetLedgerPositionsByUserId(userId int64) map[int64]int64 {
+	var rows []struct {
+		StockID  int64
+		Quantity int64
+	}
+	DB.Model(&orm.JournalEntries{}).
+		Select("stock_id, sum(debit - credit) as quantity").
+		Where("user_id = ? and account = ? and stock_id is not null", userId, util.LedgerAccountUserSecurities).
+		Group("stock_id").
+		Scan(&rows)
+
+	positions := make(map[int64]int64)
+	for _, row := range rows {
+		positions[row.StockID] = row.Quantity
+	}
+	return positions
+}
+
+func GetHoldingsByUserID(userID int64) []orm.Holdings {
+	var holdings []orm.Holdings
+	DB.Where("user_id = ?", userID).Find(&holdings)
+	return holdings
+}
+
+func GetCorporateActions(stockId int64) []orm.CorporateActions {
+	var corporateActions []orm.CorporateActions
+	query := DB.Order("ex_date desc, corporate_action_id desc")
+	if stockId > 0 {
+		query = query.Where("stock_id = ?", stockId)
+	}
+	query.Find(&corporateActions)
+	return corporateActions
+}
+
+func GetCorporateActionById(corporateActionId int64) orm.CorporateActions {
+	var corporateAction orm.CorporateActions
+	DB.Find(&corporateAction, corporateActionId)
+	return corporateAction
+}
+
+// GetDueCorporateActions returns the actions past their ex date that are still scheduled
+// and the recorded dividends past their pay date, oldest first.
+func GetDueCorporateActions(now time.Time) []orm.CorporateActions {
+	var corporateActions []orm.CorporateActions
+	DB.Where("(status = ? and ex_date <= ?) or (status = ? and pay_date <= ?)",
+		util.CorporateActionStatusScheduled, now, util.CorporateActionStatusRecorded, now).
+		Order("ex_date asc, corporate_action_id asc").
+		Find(&corporateActions)
+	return corporateActions
+}
+
+func GetActiveHoldingsByStockIdTx(tx *gorm.DB, stockId int64) []orm.Holdings {
+	var holdings []orm.Holdings
+	tx.Where("stock_id = ? and quantity != 0", stockId).Find(&holdings)
+	return holdings
+}
+
+func GetOrdersByStockIdAndStatusesTx(tx *gorm.DB, stockId int64, orderStatuses []string) []orm.Orders {
+	var orders []orm.Orders
+	tx.Where("stock_id = ? and order_status in ?", stockId, orderStatuses).Find(&orders)
+	return orders
+}
+
+func GetOpenTaxLotsByStockIdTx(tx *gorm.DB, stockId int64) []orm.TaxLots {
+	var taxLots []orm.TaxLots
+	tx.Where("stock_id = ? and remaining_quantity > 0", stockId).Order("acquired_at asc, tax_lot_id asc").Find(&taxLots)
+	return taxLots
+}
+
+func GetUnpaidDividendPaymentsTx(tx *gorm.DB, corporateActionId int64) []orm.DividendPayments {
+	var dividendPayments []orm.DividendPayments
+	tx.Where("corporate_action_id = ? and paid_at is null", corporateActionId).Find(&dividendPayments)
+	return dividendPayments
+}
+
+func GetMarketHolidays(fromDate time.Time) []orm.MarketHolidays {
+	var marketHolidays []orm.MarketHolidays
+	DB.Where("holiday_date >= ?", fromDate).Order("holiday_date asc").Find(&marketHolidays)
+	return marketHolidays
+}
+
+func GetLatestMarketSessionEvent() orm.MarketSessionEvents {
+	var marketSessionEvent orm.MarketSessionEvents
+	DB.Order("market_session_event_id desc").First(&marketSessionEvent)
+	return marketSessionEvent
+}
+
+func GetQueuedOrders() []orm.Orders {
+	var orders []orm.Orders
+	DB.Where("order_status = ?", util.OrderStatusQueued).Order("created_at asc, order_id asc").Find(&orders)
+	return orders
+}
+
+func GetActiveOptionContractsByStockId(stockId int64) []orm.OptionContracts {
+	var optionContracts []orm.OptionContracts
+	DB.Where("stock_id = ? and status = ?", stockId, util.OptionContractStatusActive).
+		Order("expires_at asc, strike_price_cents asc, option_type asc").
+		Find(&optionContracts)
+	return optionContracts
+}
+
+func GetActiveOptionContractsByStockIdTx(tx *gorm.DB, stockId int64) []orm.OptionContracts {
+	var optionContracts []orm.OptionContracts
+	tx.Where("stock_id = ? and status = ?", stockId, util.OptionContractStatusActive).Find(&optionContracts)
+	return optionContracts
+}
+
+func GetOptionContractById(optionContractId int64) orm.OptionContracts {
+	var optionContract orm.OptionContracts
+	DB.Find(&optionContract, optionContractId)
+	return optionContract
+}
+
+func GetOptionContractsByIds(optionContractIds []int64) []orm.OptionContracts {
+	var optionContracts []orm.OptionContracts
+	DB.Where("option_contract_id in ?", optionContractIds).Find(&optionContracts)
+	return optionContracts
+}
+
+// GetDueOptionContracts returns the active contracts past their expiration, oldest first.
+func GetDueOptionContracts(now time.Time) []orm.OptionContracts {
+	var optionContracts []orm.OptionContracts
+	DB.Where("status = ? and expires_at <= ?", util.OptionContractStatusActive, now).
+		Order("expires_at asc, option_contract_id asc").
+		Find(&optionContracts)
+	return optionContracts
+}
+
+func GetOptionOrdersByUserId(userId int64) []orm.OptionOrders {
+	var optionOrders []orm.OptionOrders
+	DB.Where("user_id = ?", userId).Order("created_at desc, option_order_id desc").Find(&optionOrders)
+	return optionOrders
+}
+
+func GetOptionOrderById(optionOrderId int64) orm.OptionOrders {
+	var optionOrder orm.OptionOrders
+	DB.Find(&optionOrder, optionOrderId)
+	return optionOrder
+}
+
+func GetPendingOptionOrders() []orm.OptionOrders {
+	var optionOrders []orm.OptionOrders
+	DB.Where("order_status = ?", util.OrderStatusPending).Order("created_at asc, option_order_id asc").Find(&optionOrders)
+	return optionOrders
+}
+
+// GetPendingOptionSellQuantityByUserIdAndContractId is the number of contracts held by the user's resting sell orders.
+func GetPendingOptionSellQuantityByUserIdAndContractId(userId int64, optionContractId int64) int64 {
+	var quantity int64
+	DB.Model(&orm.OptionOrders{}).
+		Select("coalesce(sum(quantity), 0)").
+		Where("user_id = ? and option_contract_id = ? and trade_type = ? and order_status = ?",
+			userId, optionContractId, util.TradeTypeSell, util.OrderStatusPending).
+		Scan(&quantity)
+	return quantity
+}
+
+func GetActiveOptionHoldingsByUserId(userId int64) []orm.OptionHoldings {
+	var optionHoldings []orm.OptionHoldings
+	DB.Where("user_id = ? and quantity > 0", userId).Find(&optionHoldings)
+	return optionHoldings
+}
+
+// GetOptionHoldingByUserIdAndContractIdTx reads the option holding through tx, seeing the changes made earlier in the same transaction.
+func GetOptionHoldingByUserIdAndContractIdTx(tx *gorm.DB, userId int64, optionContractId int64) orm.OptionHoldings {
+	var optionHolding orm.OptionHoldings
+	tx.Where("user_id = ? and option_contract_id = ?", userId, optionContractId).Limit(1).Find(&optionHolding)
+	return optionHolding
+}
+
+func GetActiveOptionHoldingsByContractIdTx(tx *gorm.DB, optionContractId int64) []orm.OptionHoldings {
+	var optionHoldings []orm.OptionHoldings
+	tx.Where("option_contract_id = ? and quantity > 0", optionContractId).Find(&optionHoldings)
+	return optionHoldings
+}
+
+func GetFxRates() []orm.FxRates {
+	var fxRates []orm.FxRates
+	DB.Order("currency asc").Find(&fxRates)
+	return fxRates
+}
+
+func GetCashBalancesByUserId(userId int64) []orm.CashBalances {
+	var cashBalances []orm.CashBalances
+	DB.Where("user_id = ?", userId).Order("currency asc").Find(&cashBalances)
+	return cashBalances
+}
+
+// GetCashBalancesByUserIdTx reads the user's cash balances in every currency but USD through tx.
+func GetCashBalancesByUserIdTx(tx *gorm.DB, userId int64) []orm.CashBalances {
+	var cashBalances []orm.CashBalances
+	tx.Where("user_id = ?", userId).Order("currency asc").Find(&cashBalances)
+	return cashBalances
+}
+
+// GetCashBalanceByUserIdAndCurrencyTx reads the cash balance through tx, seeing the changes made earlier in the same transaction.
+func GetCashBalanceByUserIdAndCurrencyTx(tx *gorm.DB, userId int64, currency string) orm.CashBalances {
+	var cashBalance orm.CashBalances
+	tx.Where("user_id = ? and currency = ?", userId, currency).Limit(1).Find(&cashBalance)
+	return cashBalance
+}
+
+func GetPortfolioTargetByUserId(userId int64) orm.PortfolioTargets {
+	var portfolioTarget orm.PortfolioTargets
+	DB.Where("user_id = ?", userId).Limit(1).Find(&portfolioTarget)
+	return portfolioTarget
+}
+
+func GetTargetAllocationsByUserId(userId int64) []orm.TargetAllocations {
+	var targetAllocations []orm.TargetAllocations
+	DB.Where("user_id = ?", userId).Order("target_percent desc, stock_id asc").Find(&targetAllocations)
+	return targetAllocations
+}
+
+// GetDuePortfolioTargets are the scheduled rebalances due at now.
+func GetDuePortfolioTargets(now time.Time) []orm.PortfolioTargets {
+	var portfolioTargets []orm.PortfolioTargets
+	DB.Where("rebalance_frequency <> ? and next_rebalance_at <= ?", util.RebalanceFrequencyNone, now).
+		Order("next_rebalance_at asc, user_id asc").
+		Find(&portfolioTargets)
+	return portfolioTargets
+}
+
+func GetRecurringInvestmentsByUserId(userId int64) []orm.RecurringInvestments {
+	var recurringInvestments []orm.RecurringInvestments
+	DB.Where("user_id = ?", userId).Order("recurring_investment_id asc").Find(&recurringInvestments)
+	return recurringInvestments
+}
+
+func GetRecurringInvestmentById(recurringInvestmentId int64) orm.RecurringInvestments {
+	var recurringInvestment orm.RecurringInvestments
+	DB.Where("recurring_investment_id = ?", recurringInvestmentId).Limit(1).Find(&recurringInvestment)
+	return recurringInvestment
+}
+
+// GetDueRecurringInvestments are the active recurring investments due at now.
+func GetDueRecurringInvestments(now time.Time) []orm.RecurringInvestments {
+	var recurringInvestments []orm.RecurringInvestments
+	DB.Where("status = ? and next_run_at <= ?", util.RecurringInvestmentStatusActive, now).
+		Order("next_run_at asc, recurring_investment_id asc").
+		Find(&recurringInvestments)
+	return recurringInvestments
+}
@@ -4,6 +4,7 @@ type ApiResponse struct {
 	Success      bool
 	Data         interface{}
 	ErrorMessage string
+	ErrorCode    string // machine readable reason, set for risk check rejections
 }
 
 type SentimentRequest struct {
@@ -0,0 +1,28 @@
+package model
+
+type BasketOrderRequest struct {
+	UserID        int64
+	ExecutionMode string         // util.BasketModeAllOrNone or util.BasketModeBestEffort
+	Legs          []OrderRequest // market orders
+}
+
+type BasketOrderModel struct {
+	OrderGroupID  int64
+	ExecutionMode string
+	ExecutedLegs  int
+	FailedLegs    int
+	ErrorMessage  string           // failure of the basket itself, the leg failures are on the legs
+	Legs          []BasketLegModel // in the order of the request
+}
+
+type BasketLegModel struct {
+	Ticker               string
+	TradeType            string
+	Quantity             float64
+	OrderID              int64
+	OrderStatus          string // EXECUTED or FAILED
+	PricePerShareDollars float64
+	FeeDollars           float64
+	ErrorMessage         string
+	ErrorCode            string // risk rejections only
+}
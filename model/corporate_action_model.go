@@ -0,0 +1,26 @@
+package model
+
+type CorporateActionModel struct {
+	CorporateActionID       int64
+	StockTicker             string
+	ActionType              string
+	SplitFrom               int64
+	SplitTo                 int64
+	DividendPerShareDollars float64
+	ExDate                  string
+	PayDate                 string
+	Status                  string
+	CreatedAt               string
+	ProcessedAt             string
+}
+
+type CorporateActionRequest struct {
+	Ticker                string
+	ActionType            string
+	SplitFrom             int64
+	SplitTo               int64
+	DividendPerShareCents int64
+	ExDate                string // RFC 3339
+	PayDate               string // RFC 3339, cash dividends only
+	CreatedBy             int64
+}
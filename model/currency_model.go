@@ -0,0 +1,25 @@
+package model
+
+type FxRateModel struct {
+	Currency  string
+	Name      string
+	UsdRate   float64 // USD per unit of the currency
+	UsdBid    float64 // rate the currency is sold at
+	UsdAsk    float64 // rate the currency is bought at
+	UpdatedAt string
+}
+
+// CashBalanceModel is the user's cash in one currency, with its value in the user's base currency.
+type CashBalanceModel struct {
+	Currency  string
+	Balance   float64
+	BaseValue float64
+}
+
+type CurrencyConversionModel struct {
+	FromCurrency string
+	FromAmount   float64
+	ToCurrency   string
+	ToAmount     float64
+	Rate         float64 // units of ToCurrency received per unit of FromCurrency
+}
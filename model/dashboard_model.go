@@ -1,12 +1,25 @@
 package model
 
+// DashboardModel totals are in the user's base currency.
 type DashboardModel struct {
 	User                     UserModel
 	Stocks                   []StockModel
 	Holdings                 []HoldingModel
 	StockWatchlist           []StockWatchlistModel
+	OptionHoldings           []OptionHoldingModel
+	OptionGreeks             OptionGreeksModel // sum of the option positions
+	CashBalances             []CashBalanceModel
+	TotalCashValueDollars    float64
 	TotalHoldingValueDollars float64
+	TotalOptionValueDollars  float64
+	BuyingPowerDollars       float64
+	EquityDollars            float64
+	MaintenanceMarginDollars float64
+	MarginCall               bool
 	PortfolioValueDollars    float64
-	TotalPnLDollars          float64
+	TotalPnLDollars          float64 // realized plus unrealized, net of fees
+	RealizedPnLDollars       float64
+	UnrealizedPnLDollars     float64
+	TotalFeesDollars         float64
 	TotalReturnPercent       float64
 }
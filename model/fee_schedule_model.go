@@ -0,0 +1,12 @@
+package model
+
+type FeeScheduleModel struct {
+	FeeScheduleID   int64
+	Name            string
+	PerTradeDollars float64
+	PerShareDollars float64
+	Percent         float64
+	MinFeeDollars   float64
+	SellFeePercent  float64
+	IsDefault       bool
+}
@@ -3,7 +3,8 @@ package model
 type HoldingModel struct {
 	HoldingID                  int64
 	StockTicker                string
-	Quantity                   int64
+	Currency                   string // values are in the stock's currency
+	Quantity                   float64
 	AverageCostPerShareDollars float64
 	TotalValueDollars          float64
 	PnLDollars                 float64
@@ -0,0 +1,43 @@
+package model
+
+type JournalModel struct {
+	JournalID   int64
+	EventType   string
+	ExecutionID int64
+	Description string
+	CreatedAt   string
+	Entries     []JournalEntryModel
+}
+
+// JournalEntryModel is a cash entry in units of Currency, or a securities entry in shares when StockTicker is set.
+type JournalEntryModel struct {
+	Account        string
+	StockTicker    string
+	Currency       string // cash entries only
+	DebitDollars   float64
+	CreditDollars  float64
+	DebitQuantity  float64
+	CreditQuantity float64
+}
+
+type ReconciliationModel struct {
+	UserID                int64
+	IsReconciled          bool
+	LedgerCashDollars     float64
+	CashBalanceDollars    float64
+	CashDifferenceDollars float64
+	CashBreaks            []CashBreakModel // currencies other than USD
+	PositionBreaks        []PositionBreakModel
+}
+
+type CashBreakModel struct {
+	Currency      string
+	LedgerBalance float64
+	CashBalance   float64
+}
+
+type PositionBreakModel struct {
+	StockTicker     string
+	LedgerQuantity  float64
+	HoldingQuantity float64
+}
@@ -3,6 +3,7 @@ package model
 type MarketModel struct {
 	Stock StockModel
 	News  []NewsModel
+	Depth OrderBookModel
 }
 
 type NewsModel struct {
@@ -0,0 +1,20 @@
+package model
+
+type MarketSessionModel struct {
+	Session            string
+	TradingDate        string
+	IsTradingDay       bool
+	Holiday            string
+	RegularOpen        string // empty on days the market is closed
+	RegularClose       string
+	NextSession        string
+	NextSessionAt      string
+	AcceptedOrderTypes []string
+	UpcomingHolidays   []MarketHolidayModel
+}
+
+type MarketHolidayModel struct {
+	Date       string
+	Name       string
+	EarlyClose bool
+}
@@ -0,0 +1,11 @@
+package model
+
+type NotificationModel struct {
+	NotificationID int64
+	UserID         int64
+	EventType      string
+	Message        string
+	OrderID        int64
+	IsRead         bool
+	CreatedAt      string
+}
@@ -0,0 +1,72 @@
+package model
+
+type OptionContractModel struct {
+	OptionContractID   int64
+	Symbol             string
+	StockTicker        string
+	OptionType         string
+	StrikePriceDollars float64
+	ExpiresAt          string
+	DaysToExpiry       float64
+	SharesPerContract  float64
+	Status             string
+	UnderlyingDollars  float64
+	ImpliedVolatility  float64
+	MarkDollars        float64 // premiums are per share of the underlying
+	BidDollars         float64
+	AskDollars         float64
+	Greeks             OptionGreeksModel
+}
+
+// OptionGreeksModel holds the Greeks of one share of the underlying on a contract, and of whole positions in the dashboard.
+type OptionGreeksModel struct {
+	Delta float64
+	Gamma float64
+	Theta float64 // per calendar day
+	Vega  float64 // per percentage point of volatility
+	Rho   float64 // per percentage point of the risk-free rate
+}
+
+type OptionChainModel struct {
+	StockTicker       string
+	UnderlyingDollars float64
+	ImpliedVolatility float64
+	Contracts         []OptionContractModel
+}
+
+type OptionOrderModel struct {
+	OptionOrderID          int64
+	Symbol                 string
+	TradeType              string
+	OrderType              string
+	OrderStatus            string
+	Quantity               int64
+	LimitPriceDollars      float64
+	PricePerShareDollars   float64
+	TotalOrderValueDollars float64
+	FeeDollars             float64
+	CreatedAt              string
+	ExecutedAt             string
+	Notes                  string
+}
+
+type OptionOrderRequest struct {
+	UserID           int64
+	OptionContractID int64
+	TradeType        string
+	OrderType        string
+	Quantity         int64 // whole contracts
+	LimitPriceCents  int64
+}
+
+type OptionHoldingModel struct {
+	OptionHoldingID            int64
+	Contract                   OptionContractModel
+	Quantity                   int64
+	AverageCostPerShareDollars float64
+	TotalValueDollars          float64
+	PnLDollars                 float64
+	PnLPercent                 float64
+	Greeks                     OptionGreeksModel // of the whole position
+	UpdatedAt                  string
+}
@@ -0,0 +1,13 @@
+package model
+
+type OrderBookModel struct {
+	StockID int64
+	Bids    []OrderBookLevelModel
+	Asks    []OrderBookLevelModel
+}
+
+type OrderBookLevelModel struct {
+	PriceDollars float64
+	Quantity     float64
+	Orders       int
+}
@@ -1,14 +1,62 @@
 package model
 
 type OrderModel struct {
-	OrderID                int64
-	StockTicker            string
-	StockName              string
-	TradeType              string
-	OrderStatus            string
-	Quantity               int64
-	PricePerShareDollars   float64
-	TotalOrderValueDollars float64
-	CreatedAt              string
-	Notes                  string
+	OrderID                 int64
+	StockTicker             string
+	StockName               string
+	TradeType               string
+	OrderType               string
+	TimeInForce             string
+	OrderStatus             string
+	Quantity                float64
+	FilledQuantity          float64
+	RemainingQuantity       float64
+	AverageFillPriceDollars float64
+	LimitPriceDollars       float64
+	StopPriceDollars        float64
+	TrailAmountDollars      float64
+	TrailPercent            float64
+	PricePerShareDollars    float64
+	TotalOrderValueDollars  float64
+	FeeDollars              float64
+	CreatedAt               string
+	TriggeredAt             string
+	Notes                   string
+	OrderGroupID            int64
+	ParentOrderID           int64
+	Amendments              []OrderAmendmentModel
+	Executions              []ExecutionModel
+}
+
+type OrderAmendmentModel struct {
+	OrderAmendmentID     int64
+	OldQuantity          float64
+	NewQuantity          float64
+	OldLimitPriceDollars float64
+	NewLimitPriceDollars float64
+	CreatedAt            string
+}
+
+type ExecutionModel struct {
+	ExecutionID          int64
+	Quantity             float64
+	PricePerShareDollars float64
+	FeeDollars           float64
+	CounterpartyOrderID  int64
+	CreatedAt            string
+}
+
+type OrderRequest struct {
+	UserID           int64
+	Ticker           string
+	TradeType        string
+	OrderType        string
+	TimeInForce      string
+	Quantity         int64 // millionths of a share
+	AmountCents      int64 // dollar-based market orders, converted to a quantity when placed
+	LimitPriceCents  int64
+	StopPriceCents   int64
+	TrailAmountCents int64
+	TrailPercent     float64
+	TaxLotIDs        []int64 // lots to close first, SPECIFIC_LOT cost basis only
 }
@@ -0,0 +1,58 @@
+package model
+
+type TargetAllocationRequest struct {
+	UserID                int64
+	Targets               map[string]float64 // ticker to percent of the portfolio
+	CashPercent           float64
+	DriftThresholdPercent float64
+	MinTradeCents         int64
+	RebalanceFrequency    string
+}
+
+type TargetAllocationModel struct {
+	Targets               []TargetWeightModel
+	CashPercent           float64
+	DriftThresholdPercent float64
+	MinTradeDollars       float64
+	RebalanceFrequency    string
+	NextRebalanceAt       string
+	LastRebalancedAt      string
+}
+
+type TargetWeightModel struct {
+	StockTicker   string
+	TargetPercent float64
+}
+
+// RebalancePreviewModel values are in USD at the generator's current prices.
+type RebalancePreviewModel struct {
+	PortfolioValueDollars float64
+	CashDollars           float64
+	CashPercent           float64
+	TargetCashPercent     float64
+	Positions             []RebalancePositionModel
+	Trades                []RebalanceTradeModel
+	IsBalanced            bool // no position drifted past the threshold
+}
+
+type RebalancePositionModel struct {
+	StockTicker    string
+	Quantity       float64
+	ValueDollars   float64
+	CurrentPercent float64
+	TargetPercent  float64
+	DriftPercent   float64 // current minus target, in percentage points
+}
+
+type RebalanceTradeModel struct {
+	StockTicker         string
+	TradeType           string
+	Quantity            float64
+	ValueDollars        float64 // estimated at the quote with slippage
+	EstimatedFeeDollars float64
+}
+
+type RebalanceResultModel struct {
+	Preview RebalancePreviewModel
+	Basket  BasketOrderModel
+}
@@ -0,0 +1,22 @@
+package model
+
+type RecurringInvestmentModel struct {
+	RecurringInvestmentID int64
+	StockTicker           string
+	Currency              string // of the amount
+	AmountDollars         float64
+	Cadence               string
+	Status                string
+	NextRunAt             string
+	LastRunAt             string
+	LastRunStatus         string
+	CreatedAt             string
+}
+
+type RecurringInvestmentRequest struct {
+	UserID      int64
+	Ticker      string
+	AmountCents int64 // in the stock's currency
+	Cadence     string
+	FirstRunAt  string // RFC 3339, optional, right away by default
+}
@@ -4,8 +4,11 @@ type StockModel struct {
 	StockID               int64
 	Ticker                string
 	Name                  string
+	Currency              string // prices are in this currency
 	OpeningPriceDollars   float64
 	CurrentPriceDollars   float64
+	BidDollars            float64
+	AskDollars            float64
 	ChangedPriceDollars   float64
 	ChangedPercent        float64
 	UpdatedAt             string
@@ -11,4 +11,12 @@ type StockWatchlistModel struct {
 	DiffPercent        float64
 	IsActive           bool
 	CreatedAt          string
+	AlertPolicy        string
+	LastAlertedAt      string
+	ActionType         string
+	ActionQuantity     float64 // shares
+	ActionStatus       string
+	ActionOrderID      int64
+	ActionMessage      string
+	ActionExecutedAt   string
 }
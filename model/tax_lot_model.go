@@ -0,0 +1,24 @@
+package model
+
+type TaxLotModel struct {
+	TaxLotID             int64
+	StockTicker          string
+	IsShort              bool
+	Quantity             float64
+	RemainingQuantity    float64
+	CostPerShareDollars  float64
+	UnrealizedPnLDollars float64
+	AcquiredAt           string
+}
+
+type RealizedLotModel struct {
+	RealizedLotID        int64
+	TaxLotID             int64
+	StockTicker          string
+	IsShort              bool
+	Quantity             float64
+	CostPerShareDollars  float64
+	PricePerShareDollars float64
+	RealizedPnLDollars   float64
+	ClosedAt             string
+}
@@ -4,8 +4,12 @@ type UserModel struct {
 	UserID             int64
 	Username           string
 	Email              string
-	CashBalanceDollars float64
+	CashBalanceDollars float64 // USD
+	BaseCurrency       string
 	CreatedAt          string
 	UpdatedAt          string
 	NotificationsOn    bool
+	FeeSchedule        string
+	CostBasisMethod    string
+	DripEnabled        bool
 }
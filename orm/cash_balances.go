@@ -0,0 +1,12 @@
+package orm
+
+import "time"
+
+type CashBalances struct {
+	CashBalanceID int64 `gorm:"primaryKey"`
+	UserID        int64
+	Currency      string
+	BalanceCents  int64
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
@@ -0,0 +1,18 @@
+package orm
+
+import "time"
+
+type CorporateActions struct {
+	CorporateActionID     int64 `gorm:"primaryKey"`
+	StockID               int64
+	ActionType            string
+	SplitFrom             int64
+	SplitTo               int64
+	DividendPerShareCents int64
+	ExDate                time.Time
+	PayDate               *time.Time
+	Status                string
+	CreatedBy             *int64
+	CreatedAt             time.Time
+	ProcessedAt           *time.Time
+}
@@ -0,0 +1,13 @@
+package orm
+
+import "time"
+
+type DividendPayments struct {
+	DividendPaymentID int64 `gorm:"primaryKey"`
+	CorporateActionID int64
+	UserID            int64
+	Quantity          int64
+	AmountCents       int64
+	CreatedAt         time.Time
+	PaidAt            *time.Time
+}
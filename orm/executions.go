@@ -0,0 +1,16 @@
+package orm
+
+import "time"
+
+type Executions struct {
+	ExecutionID         int64 `gorm:"primaryKey"`
+	OrderID             int64
+	UserID              int64
+	StockID             int64
+	TradeType           string
+	Quantity            int64
+	PricePerShareCents  int64
+	FeeCents            int64
+	CounterpartyOrderID *int64
+	CreatedAt           time.Time
+}
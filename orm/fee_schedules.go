@@ -0,0 +1,15 @@
+package orm
+
+import "time"
+
+type FeeSchedules struct {
+	FeeScheduleID  int64 `gorm:"primaryKey"`
+	Name           string
+	PerTradeCents  int64
+	PerShareCents  float64
+	Percent        float64
+	MinFeeCents    int64
+	SellFeePercent float64
+	IsDefault      bool
+	CreatedAt      time.Time
+}
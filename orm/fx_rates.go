@@ -0,0 +1,13 @@
+package orm
+
+import "time"
+
+type FxRates struct {
+	Currency               string `gorm:"primaryKey"`
+	Name                   string
+	UsdRateMicros          int64
+	MinRateGeneratorMicros int64
+	MaxRateGeneratorMicros int64
+	SpreadBps              float64
+	UpdatedAt              time.Time
+}
@@ -0,0 +1,14 @@
+package orm
+
+import "time"
+
+type IdempotencyKeys struct {
+	IdempotencyKeyID int64 `gorm:"primaryKey"`
+	UserID           int64
+	IdempotencyKey   string
+	RequestPath      string
+	RequestHash      string
+	Response         *string
+	CreatedAt        time.Time
+	ExpiresAt        time.Time
+}
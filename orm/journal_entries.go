@@ -0,0 +1,12 @@
+package orm
+
+type JournalEntries struct {
+	JournalEntryID int64 `gorm:"primaryKey"`
+	JournalID      int64
+	UserID         int64
+	Account        string
+	StockID        *int64
+	Currency       *string
+	Debit          int64
+	Credit         int64
+}
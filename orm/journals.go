@@ -0,0 +1,12 @@
+package orm
+
+import "time"
+
+type Journals struct {
+	JournalID   int64 `gorm:"primaryKey"`
+	UserID      int64
+	EventType   string
+	ExecutionID *int64
+	Description string
+	CreatedAt   time.Time
+}
@@ -0,0 +1,9 @@
+package orm
+
+import "time"
+
+type MarketHolidays struct {
+	HolidayDate time.Time `gorm:"primaryKey;type:date"`
+	Name        string
+	EarlyClose  bool
+}
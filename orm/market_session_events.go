@@ -0,0 +1,10 @@
+package orm
+
+import "time"
+
+type MarketSessionEvents struct {
+	MarketSessionEventID int64 `gorm:"primaryKey"`
+	Session              string
+	TradingDate          time.Time `gorm:"type:date"`
+	StartedAt            time.Time
+}
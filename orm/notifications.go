@@ -0,0 +1,13 @@
+package orm
+
+import "time"
+
+type Notifications struct {
+	NotificationID int64 `gorm:"primaryKey"`
+	UserID         int64
+	EventType      string
+	Message        string
+	OrderID        *int64
+	IsRead         bool
+	CreatedAt      time.Time
+}
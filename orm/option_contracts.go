@@ -0,0 +1,15 @@
+package orm
+
+import "time"
+
+type OptionContracts struct {
+	OptionContractID    int64 `gorm:"primaryKey"`
+	StockID             int64
+	Symbol              string
+	OptionType          string
+	StrikePriceCents    int64
+	DeliverableQuantity int64
+	ExpiresAt           time.Time
+	Status              string
+	CreatedAt           time.Time
+}
@@ -0,0 +1,13 @@
+package orm
+
+import "time"
+
+type OptionHoldings struct {
+	OptionHoldingID          int64 `gorm:"primaryKey"`
+	UserID                   int64
+	OptionContractID         int64
+	Quantity                 int64
+	AverageCostPerShareCents int64
+	CreatedAt                time.Time
+	UpdatedAt                time.Time
+}
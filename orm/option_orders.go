@@ -0,0 +1,20 @@
+package orm
+
+import "time"
+
+type OptionOrders struct {
+	OptionOrderID        int64 `gorm:"primaryKey"`
+	UserID               int64
+	OptionContractID     int64
+	TradeType            string
+	OrderType            string
+	OrderStatus          string
+	Quantity             int64
+	LimitPriceCents      int64
+	PricePerShareCents   int64
+	TotalOrderValueCents int64
+	FeeCents             int64
+	CreatedAt            time.Time
+	ExecutedAt           *time.Time
+	Notes                string
+}
@@ -0,0 +1,14 @@
+package orm
+
+import "time"
+
+type OrderAmendments struct {
+	OrderAmendmentID   int64 `gorm:"primaryKey"`
+	OrderID            int64
+	UserID             int64
+	OldQuantity        int64
+	NewQuantity        int64
+	OldLimitPriceCents int64
+	NewLimitPriceCents int64
+	CreatedAt          time.Time
+}
@@ -0,0 +1,10 @@
+package orm
+
+import "time"
+
+type OrderGroups struct {
+	OrderGroupID int64 `gorm:"primaryKey"`
+	UserID       int64
+	GroupType    string
+	CreatedAt    time.Time
+}
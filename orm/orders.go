@@ -2,17 +2,33 @@ package orm
 
 import (
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type Orders struct {
-	OrderID              int64 `gorm:"primaryKey"`
-	UserID               int64
-	StockID              int64
-	TradeType            string
-	OrderStatus          string
-	Quantity             int64
-	PricePerShareCents   int64
-	TotalOrderValueCents int64
-	CreatedAt            time.Time
-	Notes                string
+	OrderID               int64 `gorm:"primaryKey"`
+	UserID                int64
+	StockID               int64
+	TradeType             string
+	OrderType             string
+	TimeInForce           string
+	OrderStatus           string
+	Quantity              int64
+	FilledQuantity        int64
+	AverageFillPriceCents int64
+	LimitPriceCents       int64
+	StopPriceCents        int64
+	TrailAmountCents      int64
+	TrailPercent          float64
+	TrailAnchorCents      int64
+	PricePerShareCents    int64
+	TotalOrderValueCents  int64
+	FeeCents              int64
+	CreatedAt             time.Time
+	TriggeredAt           *time.Time
+	OrderGroupID          *int64
+	ParentOrderID         *int64
+	Notes                 string
+	TaxLotIDs             pq.Int64Array `gorm:"type:bigint[]"`
 }
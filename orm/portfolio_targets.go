@@ -0,0 +1,15 @@
+package orm
+
+import "time"
+
+type PortfolioTargets struct {
+	UserID                int64 `gorm:"primaryKey"`
+	CashPercent           float64
+	DriftThresholdPercent float64
+	MinTradeCents         int64
+	RebalanceFrequency    string
+	NextRebalanceAt       *time.Time
+	LastRebalancedAt      *time.Time
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+}
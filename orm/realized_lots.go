@@ -0,0 +1,17 @@
+package orm
+
+import "time"
+
+type RealizedLots struct {
+	RealizedLotID      int64 `gorm:"primaryKey"`
+	TaxLotID           int64
+	UserID             int64
+	StockID            int64
+	ExecutionID        *int64
+	IsShort            bool
+	Quantity           int64
+	CostPerShareCents  int64
+	PricePerShareCents int64
+	RealizedPnlCents   int64
+	ClosedAt           time.Time
+}
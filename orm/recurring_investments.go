@@ -0,0 +1,17 @@
+package orm
+
+import "time"
+
+type RecurringInvestments struct {
+	RecurringInvestmentID int64 `gorm:"primaryKey"`
+	UserID                int64
+	StockID               int64
+	AmountCents           int64
+	Cadence               string
+	Status                string
+	NextRunAt             time.Time
+	LastRunAt             *time.Time
+	LastRunStatus         string
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+}
@@ -9,6 +9,14 @@ type StockWatchlist struct {
 	TargetPriceCents int64
 	IsActive         bool
 	CreatedAt        time.Time
+	AlertPolicy      string
+	LastAlertedAt    *time.Time
+	ActionType       string
+	ActionQuantity   int64 // millionths of a share
+	ActionStatus     string
+	ActionOrderID    *int64
+	ActionMessage    string
+	ActionExecutedAt *time.Time
 }
 
 func (StockWatchlist) TableName() string {
@@ -5,14 +5,23 @@ import (
 )
 
 type Stocks struct {
-	StockID                int64 `gorm:"primaryKey"`
-	Ticker                 string
-	Name                   string
-	OpeningPriceCents      int64
-	CurrentPriceCents      int64
-	MinPriceGeneratorCents int64
-	MaxPriceGeneratorCents int64
-	CreatedAt              time.Time
-	UpdatedAt              time.Time
-	OverallSentimentScore  float32
+	StockID                  int64 `gorm:"primaryKey"`
+	Ticker                   string
+	Name                     string
+	Currency                 string
+	OpeningPriceCents        int64
+	CurrentPriceCents        int64
+	MinPriceGeneratorCents   int64
+	MaxPriceGeneratorCents   int64
+	CreatedAt                time.Time
+	UpdatedAt                time.Time
+	OverallSentimentScore    float32
+	InitialMarginPercent     float64
+	MaintenanceMarginPercent float64
+	BidPriceCents            int64
+	AskPriceCents            int64
+	SpreadBps                float64
+	LiquidityShares          int64
+	SlippageBps              float64
+	ImpliedVolatility        float64
 }
@@ -0,0 +1,11 @@
+package orm
+
+import "time"
+
+type TargetAllocations struct {
+	TargetAllocationID int64 `gorm:"primaryKey"`
+	UserID             int64
+	StockID            int64
+	TargetPercent      float64
+	CreatedAt          time.Time
+}
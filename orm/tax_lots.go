@@ -0,0 +1,16 @@
+package orm
+
+import "time"
+
+type TaxLots struct {
+	TaxLotID          int64 `gorm:"primaryKey"`
+	UserID            int64
+	StockID           int64
+	ExecutionID       *int64
+	IsShort           bool
+	Quantity          int64
+	RemainingQuantity int64
+	CostPerShareCents int64
+	AcquiredAt        time.Time
+	ClosedAt          *time.Time
+}
@@ -13,4 +13,10 @@ type Users struct {
 	CreatedAt        time.Time
 	UpdatedAt        time.Time
 	NotificationsOn  bool
+	MarginCallAt     *time.Time
+	FeeScheduleID    *int64
+	CostBasisMethod  string
+	IsAdmin          bool
+	DripEnabled      bool
+	BaseCurrency     string
 }
@@ -1,4 +1,38 @@
 
+-- Commission and fee schedules, the account tiers users can pick from
+DROP TABLE IF EXISTS fee_schedules;
+CREATE TABLE IF NOT EXISTS fee_schedules(
+    fee_schedule_id SERIAL PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,                          -- e.g., "STANDARD"
+    per_trade_cents BIGINT NOT NULL DEFAULT 0,          -- Flat fee per order
+    per_share_cents DOUBLE PRECISION NOT NULL DEFAULT 0,
+    percent DOUBLE PRECISION NOT NULL DEFAULT 0,        -- % of the order value
+    min_fee_cents BIGINT NOT NULL DEFAULT 0,            -- Minimum of the flat, per share and percent fees
+    sell_fee_percent DOUBLE PRECISION NOT NULL DEFAULT 0, -- Regulatory-style fee, % of the value of sells
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,          -- Used for users without a fee schedule
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Simulated FX rates, the rate generator moves every currency but USD within its bounds
+DROP TABLE IF EXISTS fx_rates;
+CREATE TABLE IF NOT EXISTS fx_rates(
+    currency TEXT PRIMARY KEY,                          -- ISO 4217 code, e.g. "EUR"
+    name TEXT NOT NULL,
+    usd_rate_micros BIGINT NOT NULL,                    -- USD per unit of the currency, in millionths
+    min_rate_generator_micros BIGINT NOT NULL,
+    max_rate_generator_micros BIGINT NOT NULL,
+    spread_bps DOUBLE PRECISION NOT NULL DEFAULT 20,    -- Bid/ask spread of conversions in basis points of the rate
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+INSERT INTO fx_rates (currency, name, usd_rate_micros, min_rate_generator_micros, max_rate_generator_micros, spread_bps) VALUES
+    ('USD', 'US Dollar', 1000000, 1000000, 1000000, 0),
+    ('EUR', 'Euro', 1080000, 1050000, 1110000, 20),
+    ('GBP', 'British Pound', 1270000, 1230000, 1310000, 20),
+    ('JPY', 'Japanese Yen', 6700, 6500, 6900, 30),
+    ('CAD', 'Canadian Dollar', 730000, 710000, 750000, 25)
+ON CONFLICT (currency) DO NOTHING;
+
 -- Table for Users
 DROP TABLE IF EXISTS users;
 CREATE TABLE IF NOT EXISTS users (
@@ -6,10 +40,16 @@ CREATE TABLE IF NOT EXISTS users (
     username TEXT UNIQUE NOT NULL DEFAULT 'default_user', -- For V1, we can have a default user
     email TEXT UNIQUE,                                  -- Optional for V1, can be NULL
     hashed_password TEXT,                               -- Not used in V1
-    cash_balance_cents BIGINT NOT NULL DEFAULT 0, -- e.g., $10,000.00 stored as 1,000,000 cents
+    cash_balance_cents BIGINT NOT NULL DEFAULT 0, -- USD balance, e.g., $10,000.00 stored as 1,000,000 cents. Other currencies are in cash_balances
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW(),
-    notifications_on BOOLEAN DEFAULT FALSE
+    notifications_on BOOLEAN DEFAULT FALSE,
+    margin_call_at TIMESTAMPTZ,                         -- Set while the account is below its maintenance requirement
+    fee_schedule_id INTEGER REFERENCES fee_schedules(fee_schedule_id) ON DELETE SET NULL,
+    cost_basis_method TEXT NOT NULL DEFAULT 'FIFO',    -- FIFO, LIFO, HIFO or SPECIFIC_LOT, order in which sells consume lots
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE,            -- Can schedule corporate actions
+    drip_enabled BOOLEAN NOT NULL DEFAULT FALSE,        -- Reinvest cash dividends in the paying stock
+    base_currency TEXT NOT NULL DEFAULT 'USD' REFERENCES fx_rates(currency) -- Currency the dashboard aggregates the portfolio in
 );
 
 -- Table for Mock Stocks
@@ -18,13 +58,22 @@ CREATE TABLE IF NOT EXISTS stocks (
     stock_id SERIAL PRIMARY KEY,
     ticker TEXT UNIQUE NOT NULL,                        -- e.g., "FAKE_AAPL"
     name TEXT NOT NULL,                                 -- e.g., "Fake Apple Inc."
+    currency TEXT NOT NULL DEFAULT 'USD' REFERENCES fx_rates(currency), -- Listing currency, prices are in its cents
     opening_price_cents BIGINT,                         -- For V2: daily opening price
     current_price_cents BIGINT,
     min_price_generator_cents BIGINT,                   -- For V2: lower bound for dynamic price generator
     max_price_generator_cents BIGINT,                   -- For V2: upper bound for dynamic price generator
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW(),
-    overall_sentiment_score INTEGER NOT NULL DEAFULT 0
+    overall_sentiment_score INTEGER NOT NULL DEAFULT 0,
+    initial_margin_percent DOUBLE PRECISION NOT NULL DEFAULT 50,     -- Equity required to open a position, % of its value
+    maintenance_margin_percent DOUBLE PRECISION NOT NULL DEFAULT 30, -- Equity required to keep it open
+    bid_price_cents BIGINT NOT NULL DEFAULT 0,          -- Published by the price generator around current_price_cents
+    ask_price_cents BIGINT NOT NULL DEFAULT 0,
+    spread_bps DOUBLE PRECISION NOT NULL DEFAULT 10,    -- Bid/ask spread in basis points of the price
+    liquidity_shares BIGINT NOT NULL DEFAULT 10000,     -- Simulated shares available at the quote
+    slippage_bps DOUBLE PRECISION NOT NULL DEFAULT 0,   -- Price impact of trading liquidity_shares, 0 disables slippage
+    implied_volatility DOUBLE PRECISION NOT NULL DEFAULT 0.30 -- Annualized volatility used to price the stock's options
 );
 
 -- Table for User's Portfolio Holdings (Current Stock Positions)
@@ -33,13 +82,22 @@ CREATE TABLE IF NOT EXISTS holdings (
     holding_id SERIAL PRIMARY KEY,                      -- Surrogate key
     user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
     stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE RESTRICT,
-    quantity BIGINT NOT NULL DEFAULT 0,
+    quantity BIGINT NOT NULL DEFAULT 0,                 -- Millionths of a share, negative when short
     average_cost_per_share_cents BIGINT NOT NULL DEFAULT 0, -- Crucial for V2 P&L. For V1, can be set to buy price.
     created_at TIMESTAMPTZ DEFAULT NOW(),              -- When the holding was first initiated
     updated_at TIMESTAMPTZ DEFAULT NOW(),              -- When quantity or avg_cost was last changed
     CONSTRAINT unique_user_stock_holding UNIQUE (user_id, stock_id) -- Ensures one holding record per user per stock
 );
 
+-- Bracket and one-cancels-other (OCO) groups of linked orders
+DROP TABLE IF EXISTS order_groups;
+CREATE TABLE IF NOT EXISTS order_groups(
+    order_group_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    group_type TEXT NOT NULL,                           -- BRACKET, OCO or BASKET
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
 -- Table for Transaction History (Log of all executed buy/sell orders)
 DROP TABLE IF EXISTS orders;
 CREATE TABLE IF NOT EXISTS orders (
@@ -47,14 +105,158 @@ CREATE TABLE IF NOT EXISTS orders (
     user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
     stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE RESTRICT,
     trade_type TEXT NOT NULL,
+    order_type TEXT NOT NULL DEFAULT 'MARKET',          -- MARKET, LIMIT, STOP, STOP_LIMIT or TRAILING_STOP
+    time_in_force TEXT NOT NULL DEFAULT 'GTC',          -- DAY, GTC, IOC or FOK
     order_status TEXT NOT NULL,
-    quantity BIGINT NOT NULL CHECK (quantity > 0),
+    quantity BIGINT NOT NULL CHECK (quantity > 0),      -- Millionths of a share, like every quantity
+    filled_quantity BIGINT NOT NULL DEFAULT 0,          -- Sum of the executions' quantities
+    average_fill_price_cents BIGINT NOT NULL DEFAULT 0, -- Volume-weighted average price of the executions
+    limit_price_cents BIGINT NOT NULL DEFAULT 0,        -- Only set for LIMIT and STOP_LIMIT orders
+    stop_price_cents BIGINT NOT NULL DEFAULT 0,         -- Only set for stop orders, the current trigger level for TRAILING_STOP
+    trail_amount_cents BIGINT NOT NULL DEFAULT 0,       -- TRAILING_STOP offset in cents, or
+    trail_percent DOUBLE PRECISION NOT NULL DEFAULT 0,  -- TRAILING_STOP offset in percent
+    trail_anchor_cents BIGINT NOT NULL DEFAULT 0,       -- TRAILING_STOP high-water (sell) or low-water (buy) mark
     price_per_share_cents BIGINT NOT NULL,
     total_order_value_cents BIGINT NOT NULL,      -- Calculated: quantity * price_per_share_cents_at_execution
+    fee_cents BIGINT NOT NULL DEFAULT 0,                -- Commission and fees charged on execution
     created_at TIMESTAMPTZ DEFAULT NOW(),
-    notes TEXT                                          -- Optional, for any specific details
+    triggered_at TIMESTAMPTZ,                           -- When a stop order was triggered
+    order_group_id INTEGER REFERENCES order_groups(order_group_id) ON DELETE SET NULL,
+    parent_order_id INTEGER REFERENCES orders(order_id) ON DELETE SET NULL, -- Bracket children activate once the parent fills
+    notes TEXT,                                         -- Optional, for any specific details
+    tax_lot_ids BIGINT[]                                -- Lots to close first, picked by the user
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_stock_id_order_status ON orders(stock_id, order_status);
+CREATE INDEX IF NOT EXISTS idx_orders_order_group_id ON orders(order_group_id);
+CREATE INDEX IF NOT EXISTS idx_orders_parent_order_id ON orders(parent_order_id);
+
+-- Fills of orders, an order can fill in several pieces at different prices
+DROP TABLE IF EXISTS executions;
+CREATE TABLE IF NOT EXISTS executions(
+    execution_id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE RESTRICT,
+    trade_type TEXT NOT NULL,
+    quantity BIGINT NOT NULL CHECK (quantity > 0),
+    price_per_share_cents BIGINT NOT NULL,
+    fee_cents BIGINT NOT NULL DEFAULT 0,
+    counterparty_order_id INTEGER REFERENCES orders(order_id) ON DELETE SET NULL, -- Set when matched in the order book
+    created_at TIMESTAMPTZ DEFAULT NOW()
 );
 
+CREATE INDEX IF NOT EXISTS idx_executions_order_id ON executions(order_id);
+CREATE INDEX IF NOT EXISTS idx_executions_user_id ON executions(user_id);
+
+-- Tax lots, one per opening fill, closed by the fills on the other side in the user's cost basis order
+DROP TABLE IF EXISTS tax_lots;
+CREATE TABLE IF NOT EXISTS tax_lots(
+    tax_lot_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE RESTRICT,
+    execution_id INTEGER REFERENCES executions(execution_id) ON DELETE SET NULL,
+    is_short BOOLEAN NOT NULL DEFAULT FALSE,
+    quantity BIGINT NOT NULL CHECK (quantity > 0),
+    remaining_quantity BIGINT NOT NULL CHECK (remaining_quantity >= 0),
+    cost_per_share_cents BIGINT NOT NULL,               -- Sale price for short lots
+    acquired_at TIMESTAMPTZ DEFAULT NOW(),
+    closed_at TIMESTAMPTZ                               -- Set once remaining_quantity is 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_tax_lots_user_id_stock_id ON tax_lots(user_id, stock_id);
+
+-- Realized P&L of every lot closed, in part or fully, by a fill
+DROP TABLE IF EXISTS realized_lots;
+CREATE TABLE IF NOT EXISTS realized_lots(
+    realized_lot_id SERIAL PRIMARY KEY,
+    tax_lot_id INTEGER NOT NULL REFERENCES tax_lots(tax_lot_id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE RESTRICT,
+    execution_id INTEGER REFERENCES executions(execution_id) ON DELETE SET NULL,
+    is_short BOOLEAN NOT NULL DEFAULT FALSE,
+    quantity BIGINT NOT NULL CHECK (quantity > 0),
+    cost_per_share_cents BIGINT NOT NULL,
+    price_per_share_cents BIGINT NOT NULL,              -- Sale price of long lots, cover price of short lots
+    realized_pnl_cents BIGINT NOT NULL,
+    closed_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_realized_lots_user_id ON realized_lots(user_id);
+
+-- Splits, reverse splits and cash dividends scheduled by an admin
+DROP TABLE IF EXISTS corporate_actions;
+CREATE TABLE IF NOT EXISTS corporate_actions(
+    corporate_action_id SERIAL PRIMARY KEY,
+    stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE CASCADE,
+    action_type TEXT NOT NULL,                          -- SPLIT, REVERSE_SPLIT or CASH_DIVIDEND
+    split_from INTEGER NOT NULL DEFAULT 1,              -- split_to new shares for every split_from old shares
+    split_to INTEGER NOT NULL DEFAULT 1,
+    dividend_per_share_cents BIGINT NOT NULL DEFAULT 0,
+    ex_date TIMESTAMPTZ NOT NULL,                       -- Splits take effect, dividend holders are recorded
+    pay_date TIMESTAMPTZ,                               -- Dividends are paid
+    status TEXT NOT NULL DEFAULT 'SCHEDULED',           -- SCHEDULED, RECORDED (dividend holders recorded), COMPLETED or CANCELED
+    created_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    processed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_corporate_actions_status ON corporate_actions(status);
+
+-- Dividend owed to each holder on the ex date, negative for short positions
+DROP TABLE IF EXISTS dividend_payments;
+CREATE TABLE IF NOT EXISTS dividend_payments(
+    dividend_payment_id SERIAL PRIMARY KEY,
+    corporate_action_id INTEGER NOT NULL REFERENCES corporate_actions(corporate_action_id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    quantity BIGINT NOT NULL,                           -- Millionths of a share held on the ex date
+    amount_cents BIGINT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    paid_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_dividend_payments_corporate_action_id ON dividend_payments(corporate_action_id);
+
+-- Append-only double-entry journal, one journal per event with balanced entries per asset:
+-- cash entries (stock_id null) are in cents of their currency, securities entries are in millionths of a share
+DROP TABLE IF EXISTS journal_entries;
+DROP TABLE IF EXISTS journals;
+CREATE TABLE IF NOT EXISTS journals(
+    journal_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id),
+    event_type TEXT NOT NULL,                           -- TRADE, OPTION_TRADE, DEPOSIT, CORPORATE_ACTION, FX_CONVERSION or OPENING_BALANCE
+    execution_id INTEGER REFERENCES executions(execution_id),
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_journals_user_id ON journals(user_id);
+
+CREATE TABLE IF NOT EXISTS journal_entries(
+    journal_entry_id SERIAL PRIMARY KEY,
+    journal_id INTEGER NOT NULL REFERENCES journals(journal_id),
+    user_id INTEGER NOT NULL REFERENCES users(user_id),
+    account TEXT NOT NULL,                              -- USER_CASH and USER_SECURITIES, the rest are contra accounts
+    stock_id INTEGER REFERENCES stocks(stock_id),
+    currency TEXT,                                      -- Currency of cash entries, null for securities entries
+    debit BIGINT NOT NULL DEFAULT 0 CHECK (debit >= 0),
+    credit BIGINT NOT NULL DEFAULT 0 CHECK (credit >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_user_id_account ON journal_entries(user_id, account);
+CREATE INDEX IF NOT EXISTS idx_journal_entries_journal_id ON journal_entries(journal_id);
+
+CREATE OR REPLACE FUNCTION prevent_journal_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'the journal is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER journals_append_only BEFORE UPDATE OR DELETE ON journals
+    FOR EACH ROW EXECUTE FUNCTION prevent_journal_change();
+CREATE TRIGGER journal_entries_append_only BEFORE UPDATE OR DELETE ON journal_entries
+    FOR EACH ROW EXECUTE FUNCTION prevent_journal_change();
+
 -- Optional: Indexes for frequently queried columns (PostgreSQL automatically creates indexes for PRIMARY KEY and UNIQUE constraints)
 -- Consider adding indexes on foreign keys and columns used in WHERE clauses or ORDER BY for performance as your data grows.
 -- Example:
@@ -67,11 +269,27 @@ CREATE TABLE IF NOT EXISTS orders (
 
 -- Initial Data for V1 MVP
 
+-- Fee schedules
+INSERT INTO fee_schedules (name, per_trade_cents, per_share_cents, percent, min_fee_cents, sell_fee_percent, is_default)
+VALUES
+    ('STANDARD', 0, 0.5, 0, 100, 0.00278, TRUE),
+    ('PRO', 0, 0.35, 0, 35, 0.00278, FALSE)
+    ON CONFLICT (name) DO NOTHING;
+
 -- Insert a default user for the MVP
 INSERT INTO users (username, email, cash_balance_cents)
 VALUES ('default_user', 'user@example.com', 10000000)
     ON CONFLICT (username) DO NOTHING;
 
+-- The initial cash is a deposit in the journal
+INSERT INTO journals (user_id, event_type, description)
+SELECT user_id, 'DEPOSIT', 'Initial investment' FROM users WHERE username = 'default_user';
+
+INSERT INTO journal_entries (journal_id, user_id, account, currency, debit, credit)
+SELECT journal_id, user_id, 'USER_CASH', 'USD', 10000000, 0 FROM journals WHERE event_type = 'DEPOSIT'
+UNION ALL
+SELECT journal_id, user_id, 'DEPOSITS', 'USD', 0, 10000000 FROM journals WHERE event_type = 'DEPOSIT';
+
 -- Insert some mock stocks for V1
 INSERT INTO stocks (ticker, name, opening_price_cents, current_price_cents, min_price_generator_cents, max_price_generator_cents)
 VALUES
@@ -82,6 +300,13 @@ VALUES
     ('TSLA', 'Tesla Inc.', 20000, 20000, 19000, 21000)      -- $250.00
     ON CONFLICT (ticker) DO NOTHING;
 
+-- Foreign-listed stocks, priced in their listing currency
+INSERT INTO stocks (ticker, name, currency, opening_price_cents, current_price_cents, min_price_generator_cents, max_price_generator_cents)
+VALUES
+    ('SAP', 'SAP SE', 'EUR', 22000, 22000, 21000, 23000),   -- 220.00 EUR
+    ('AZN', 'AstraZeneca PLC', 'GBP', 11000, 11000, 10500, 11500) -- 110.00 GBP
+    ON CONFLICT (ticker) DO NOTHING;
+
 -- Function to automatically update 'updated_at' columns
 CREATE OR REPLACE FUNCTION trigger_set_timestamp()
 RETURNS TRIGGER AS $$
@@ -119,9 +344,19 @@ CREATE TABLE IF NOT EXISTS stock_watchlist(
     stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE RESTRICT,
     target_price_cents BIGINT NOT NULL DEFAULT 0,
     is_active BOOLEAN DEFAULT TRUE,
-    created_at TIMESTAMPTZ DEFAULT NOW()
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    alert_policy TEXT NOT NULL DEFAULT 'ONCE',          -- ONCE deactivates the watch when the price crosses the target, REARM keeps it
+    last_alerted_at TIMESTAMPTZ,
+    action_type TEXT NOT NULL DEFAULT '',               -- BUY or SELL_ALL, placed once when the price crosses the target, '' for alerts only
+    action_quantity BIGINT NOT NULL DEFAULT 0,          -- Millionths of a share, BUY only
+    action_status TEXT NOT NULL DEFAULT '',             -- PENDING until the action runs, then EXECUTED or FAILED
+    action_order_id INTEGER REFERENCES orders(order_id) ON DELETE SET NULL,
+    action_message TEXT NOT NULL DEFAULT '',            -- Why the action failed
+    action_executed_at TIMESTAMPTZ
 );
 
+CREATE INDEX IF NOT EXISTS idx_stock_watchlist_stock_id ON stock_watchlist(stock_id);
+
 DROP TABLE IF EXISTS news_articles;
 CREATE TABLE IF NOT EXISTS news_articles(
     news_article_id SERIAL PRIMARY KEY,
@@ -132,3 +367,212 @@ CREATE TABLE IF NOT EXISTS news_articles(
     publication_time TIMESTAMPTZ,
     sentiment_score DECIMAL(3,2) NOT NULL DEFAULT 0
 );
+
+-- History of quantity / limit price changes made to resting orders
+DROP TABLE IF EXISTS order_amendments;
+CREATE TABLE IF NOT EXISTS order_amendments(
+    order_amendment_id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    old_quantity BIGINT NOT NULL,
+    new_quantity BIGINT NOT NULL,
+    old_limit_price_cents BIGINT NOT NULL DEFAULT 0,
+    new_limit_price_cents BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_amendments_user_id ON order_amendments(user_id);
+
+-- Notifications pushed to the user (margin calls, triggered orders...)
+DROP TABLE IF EXISTS notifications;
+CREATE TABLE IF NOT EXISTS notifications(
+    notification_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    message TEXT NOT NULL,
+    order_id INTEGER REFERENCES orders(order_id) ON DELETE SET NULL,
+    is_read BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id);
+
+-- Responses of order requests sent with an Idempotency-Key header, response is null while the request is running
+DROP TABLE IF EXISTS idempotency_keys;
+CREATE TABLE IF NOT EXISTS idempotency_keys(
+    idempotency_key_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    idempotency_key TEXT NOT NULL,
+    request_path TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    response TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (user_id, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+-- Exchange holidays, early_close days trade until 1pm instead of being closed
+DROP TABLE IF EXISTS market_holidays;
+CREATE TABLE IF NOT EXISTS market_holidays(
+    holiday_date DATE PRIMARY KEY,
+    name TEXT NOT NULL,
+    early_close BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+INSERT INTO market_holidays (holiday_date, name, early_close) VALUES
+    ('2025-01-01', 'New Year''s Day', FALSE),
+    ('2025-01-20', 'Martin Luther King Jr. Day', FALSE),
+    ('2025-02-17', 'Washington''s Birthday', FALSE),
+    ('2025-04-18', 'Good Friday', FALSE),
+    ('2025-05-26', 'Memorial Day', FALSE),
+    ('2025-06-19', 'Juneteenth', FALSE),
+    ('2025-07-03', 'Independence Day (early close)', TRUE),
+    ('2025-07-04', 'Independence Day', FALSE),
+    ('2025-09-01', 'Labor Day', FALSE),
+    ('2025-11-27', 'Thanksgiving Day', FALSE),
+    ('2025-11-28', 'Day after Thanksgiving (early close)', TRUE),
+    ('2025-12-24', 'Christmas Eve (early close)', TRUE),
+    ('2025-12-25', 'Christmas Day', FALSE),
+    ('2026-01-01', 'New Year''s Day', FALSE),
+    ('2026-01-19', 'Martin Luther King Jr. Day', FALSE),
+    ('2026-02-16', 'Washington''s Birthday', FALSE),
+    ('2026-04-03', 'Good Friday', FALSE),
+    ('2026-05-25', 'Memorial Day', FALSE),
+    ('2026-06-19', 'Juneteenth', FALSE),
+    ('2026-07-03', 'Independence Day (observed)', FALSE),
+    ('2026-09-07', 'Labor Day', FALSE),
+    ('2026-11-26', 'Thanksgiving Day', FALSE),
+    ('2026-11-27', 'Day after Thanksgiving (early close)', TRUE),
+    ('2026-12-24', 'Christmas Eve (early close)', TRUE),
+    ('2026-12-25', 'Christmas Day', FALSE),
+    ('2027-01-01', 'New Year''s Day', FALSE),
+    ('2027-01-18', 'Martin Luther King Jr. Day', FALSE),
+    ('2027-02-15', 'Washington''s Birthday', FALSE),
+    ('2027-03-26', 'Good Friday', FALSE),
+    ('2027-05-31', 'Memorial Day', FALSE),
+    ('2027-06-18', 'Juneteenth (observed)', FALSE),
+    ('2027-07-05', 'Independence Day (observed)', FALSE),
+    ('2027-09-06', 'Labor Day', FALSE),
+    ('2027-11-25', 'Thanksgiving Day', FALSE),
+    ('2027-11-26', 'Day after Thanksgiving (early close)', TRUE),
+    ('2027-12-24', 'Christmas Day (observed)', FALSE)
+ON CONFLICT (holiday_date) DO NOTHING;
+
+-- Every transition of the market session state machine, the latest row is the current session
+DROP TABLE IF EXISTS market_session_events;
+CREATE TABLE IF NOT EXISTS market_session_events(
+    market_session_event_id SERIAL PRIMARY KEY,
+    session TEXT NOT NULL,                              -- PRE_MARKET, REGULAR, AFTER_HOURS or CLOSED
+    trading_date DATE NOT NULL,                         -- Market time zone date the session belongs to
+    started_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Listed call and put contracts, generated for every stock around its current price
+DROP TABLE IF EXISTS option_holdings;
+DROP TABLE IF EXISTS option_orders;
+DROP TABLE IF EXISTS option_contracts;
+CREATE TABLE IF NOT EXISTS option_contracts(
+    option_contract_id SERIAL PRIMARY KEY,
+    stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE CASCADE,
+    symbol TEXT UNIQUE NOT NULL,                        -- OCC style, e.g. "AAPL260320C00150000"
+    option_type TEXT NOT NULL,                          -- CALL or PUT
+    strike_price_cents BIGINT NOT NULL,
+    deliverable_quantity BIGINT NOT NULL DEFAULT 100000000, -- Millionths of a share per contract, 100 shares unless adjusted by a split
+    expires_at TIMESTAMPTZ NOT NULL,                    -- Regular session close of the expiration date
+    status TEXT NOT NULL DEFAULT 'ACTIVE',              -- ACTIVE or EXPIRED
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_option_contracts_stock_id_status ON option_contracts(stock_id, status);
+
+-- Orders on option contracts, prices are per share of the underlying
+CREATE TABLE IF NOT EXISTS option_orders(
+    option_order_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    option_contract_id INTEGER NOT NULL REFERENCES option_contracts(option_contract_id) ON DELETE RESTRICT,
+    trade_type TEXT NOT NULL,                           -- BUY to open or add, SELL to close
+    order_type TEXT NOT NULL,                           -- MARKET or LIMIT
+    order_status TEXT NOT NULL,                         -- PENDING, EXECUTED, CANCELED, EXPIRED or FAILED
+    quantity BIGINT NOT NULL,                           -- Whole contracts
+    limit_price_cents BIGINT NOT NULL DEFAULT 0,
+    price_per_share_cents BIGINT NOT NULL DEFAULT 0,    -- Premium the order was filled at
+    total_order_value_cents BIGINT NOT NULL DEFAULT 0,
+    fee_cents BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    executed_at TIMESTAMPTZ,
+    notes TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_option_orders_user_id ON option_orders(user_id);
+CREATE INDEX IF NOT EXISTS idx_option_orders_order_status ON option_orders(order_status);
+
+-- Long option positions, contracts are exercised or expire worthless at the regular close of their expiration date
+CREATE TABLE IF NOT EXISTS option_holdings(
+    option_holding_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    option_contract_id INTEGER NOT NULL REFERENCES option_contracts(option_contract_id) ON DELETE RESTRICT,
+    quantity BIGINT NOT NULL DEFAULT 0,                 -- Whole contracts
+    average_cost_per_share_cents BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, option_contract_id)
+);
+
+-- Users' cash in currencies other than USD
+DROP TABLE IF EXISTS cash_balances;
+CREATE TABLE IF NOT EXISTS cash_balances(
+    cash_balance_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    currency TEXT NOT NULL REFERENCES fx_rates(currency),
+    balance_cents BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, currency)
+);
+
+-- Target allocation of a user's portfolio, the rest of the stocks' percents is held in cash
+DROP TABLE IF EXISTS portfolio_targets;
+CREATE TABLE IF NOT EXISTS portfolio_targets(
+    user_id INTEGER PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    cash_percent DOUBLE PRECISION NOT NULL DEFAULT 0,
+    drift_threshold_percent DOUBLE PRECISION NOT NULL DEFAULT 5, -- Percentage points a position drifts before it is traded
+    min_trade_cents BIGINT NOT NULL DEFAULT 100,               -- Smaller trades are skipped
+    rebalance_frequency TEXT NOT NULL DEFAULT 'NONE',          -- NONE, DAILY, WEEKLY or MONTHLY
+    next_rebalance_at TIMESTAMPTZ,
+    last_rebalanced_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_portfolio_targets_next_rebalance_at ON portfolio_targets(next_rebalance_at);
+
+DROP TABLE IF EXISTS target_allocations;
+CREATE TABLE IF NOT EXISTS target_allocations(
+    target_allocation_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES portfolio_targets(user_id) ON DELETE CASCADE,
+    stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE RESTRICT,
+    target_percent DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, stock_id)
+);
+
+-- Recurring market buys of a fixed amount (dollar-cost averaging), run by the price routine during the regular session
+DROP TABLE IF EXISTS recurring_investments;
+CREATE TABLE IF NOT EXISTS recurring_investments(
+    recurring_investment_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE RESTRICT,
+    amount_cents BIGINT NOT NULL,                       -- In the stock's currency
+    cadence TEXT NOT NULL,                              -- DAILY, WEEKLY, BIWEEKLY or MONTHLY
+    status TEXT NOT NULL DEFAULT 'ACTIVE',              -- ACTIVE or PAUSED
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_run_at TIMESTAMPTZ,
+    last_run_status TEXT NOT NULL DEFAULT '',           -- EXECUTED, SKIPPED (not enough cash) or FAILED
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_recurring_investments_user_id ON recurring_investments(user_id);
+CREATE INDEX IF NOT EXISTS idx_recurring_investments_status_next_run_at ON recurring_investments(status, next_run_at);
@@ -0,0 +1,4 @@
+ALTER TABLE stock_watchlist ADD COLUMN alert_policy TEXT NOT NULL DEFAULT 'ONCE';
+ALTER TABLE stock_watchlist ADD COLUMN last_alerted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_stock_watchlist_stock_id ON stock_watchlist(stock_id);
@@ -0,0 +1,6 @@
+ALTER TABLE stock_watchlist ADD COLUMN action_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE stock_watchlist ADD COLUMN action_quantity BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE stock_watchlist ADD COLUMN action_status TEXT NOT NULL DEFAULT '';
+ALTER TABLE stock_watchlist ADD COLUMN action_order_id INTEGER REFERENCES orders(order_id) ON DELETE SET NULL;
+ALTER TABLE stock_watchlist ADD COLUMN action_message TEXT NOT NULL DEFAULT '';
+ALTER TABLE stock_watchlist ADD COLUMN action_executed_at TIMESTAMPTZ;
@@ -0,0 +1,4 @@
+ALTER TABLE orders ADD COLUMN order_type TEXT NOT NULL DEFAULT 'MARKET';
+ALTER TABLE orders ADD COLUMN limit_price_cents BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_orders_stock_id_order_status ON orders(stock_id, order_status);
@@ -0,0 +1,2 @@
+ALTER TABLE orders ADD COLUMN stop_price_cents BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN triggered_at TIMESTAMPTZ;
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS order_amendments;
+CREATE TABLE IF NOT EXISTS order_amendments(
+    order_amendment_id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    old_quantity BIGINT NOT NULL,
+    new_quantity BIGINT NOT NULL,
+    old_limit_price_cents BIGINT NOT NULL DEFAULT 0,
+    new_limit_price_cents BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_amendments_user_id ON order_amendments(user_id);
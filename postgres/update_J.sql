@@ -0,0 +1,2 @@
+-- orders resting before time in force existed never expired, so they are kept as GTC
+ALTER TABLE orders ADD COLUMN time_in_force TEXT NOT NULL DEFAULT 'GTC';
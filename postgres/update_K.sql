@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS order_groups;
+CREATE TABLE IF NOT EXISTS order_groups(
+    order_group_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    group_type TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+ALTER TABLE orders ADD COLUMN order_group_id INTEGER REFERENCES order_groups(order_group_id) ON DELETE SET NULL;
+ALTER TABLE orders ADD COLUMN parent_order_id INTEGER REFERENCES orders(order_id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_orders_order_group_id ON orders(order_group_id);
+CREATE INDEX IF NOT EXISTS idx_orders_parent_order_id ON orders(parent_order_id);
@@ -0,0 +1,3 @@
+ALTER TABLE orders ADD COLUMN trail_amount_cents BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN trail_percent DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN trail_anchor_cents BIGINT NOT NULL DEFAULT 0;
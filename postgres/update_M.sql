@@ -0,0 +1,17 @@
+ALTER TABLE stocks ADD COLUMN initial_margin_percent DOUBLE PRECISION NOT NULL DEFAULT 50;
+ALTER TABLE stocks ADD COLUMN maintenance_margin_percent DOUBLE PRECISION NOT NULL DEFAULT 30;
+
+ALTER TABLE users ADD COLUMN margin_call_at TIMESTAMPTZ;
+
+DROP TABLE IF EXISTS notifications;
+CREATE TABLE IF NOT EXISTS notifications(
+    notification_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    message TEXT NOT NULL,
+    order_id INTEGER REFERENCES orders(order_id) ON DELETE SET NULL,
+    is_read BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id);
@@ -0,0 +1,22 @@
+DROP TABLE IF EXISTS fee_schedules;
+CREATE TABLE IF NOT EXISTS fee_schedules(
+    fee_schedule_id SERIAL PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    per_trade_cents BIGINT NOT NULL DEFAULT 0,
+    per_share_cents DOUBLE PRECISION NOT NULL DEFAULT 0,
+    percent DOUBLE PRECISION NOT NULL DEFAULT 0,
+    min_fee_cents BIGINT NOT NULL DEFAULT 0,
+    sell_fee_percent DOUBLE PRECISION NOT NULL DEFAULT 0,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+INSERT INTO fee_schedules (name, per_trade_cents, per_share_cents, percent, min_fee_cents, sell_fee_percent, is_default)
+VALUES
+    ('STANDARD', 0, 0.5, 0, 100, 0.00278, TRUE),
+    ('PRO', 0, 0.35, 0, 35, 0.00278, FALSE)
+    ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE users ADD COLUMN fee_schedule_id INTEGER REFERENCES fee_schedules(fee_schedule_id) ON DELETE SET NULL;
+
+ALTER TABLE orders ADD COLUMN fee_cents BIGINT NOT NULL DEFAULT 0;
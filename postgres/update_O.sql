@@ -0,0 +1,5 @@
+ALTER TABLE stocks ADD COLUMN bid_price_cents BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE stocks ADD COLUMN ask_price_cents BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE stocks ADD COLUMN spread_bps DOUBLE PRECISION NOT NULL DEFAULT 10;
+ALTER TABLE stocks ADD COLUMN liquidity_shares BIGINT NOT NULL DEFAULT 10000;
+ALTER TABLE stocks ADD COLUMN slippage_bps DOUBLE PRECISION NOT NULL DEFAULT 0;
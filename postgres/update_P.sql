@@ -0,0 +1,27 @@
+ALTER TABLE orders ADD COLUMN filled_quantity BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN average_fill_price_cents BIGINT NOT NULL DEFAULT 0;
+
+DROP TABLE IF EXISTS executions;
+CREATE TABLE IF NOT EXISTS executions(
+    execution_id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE RESTRICT,
+    trade_type TEXT NOT NULL,
+    quantity BIGINT NOT NULL CHECK (quantity > 0),
+    price_per_share_cents BIGINT NOT NULL,
+    fee_cents BIGINT NOT NULL DEFAULT 0,
+    counterparty_order_id INTEGER REFERENCES orders(order_id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_executions_order_id ON executions(order_id);
+CREATE INDEX IF NOT EXISTS idx_executions_user_id ON executions(user_id);
+
+-- Orders executed so far were filled in one piece
+UPDATE orders SET filled_quantity = quantity, average_fill_price_cents = price_per_share_cents
+WHERE order_status = 'EXECUTED';
+
+INSERT INTO executions (order_id, user_id, stock_id, trade_type, quantity, price_per_share_cents, fee_cents, created_at)
+SELECT order_id, user_id, stock_id, trade_type, quantity, price_per_share_cents, fee_cents, created_at
+FROM orders WHERE order_status = 'EXECUTED';
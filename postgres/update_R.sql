@@ -0,0 +1,41 @@
+ALTER TABLE users ADD COLUMN cost_basis_method TEXT NOT NULL DEFAULT 'FIFO';
+
+ALTER TABLE orders ADD COLUMN tax_lot_ids BIGINT[];
+
+DROP TABLE IF EXISTS tax_lots;
+CREATE TABLE IF NOT EXISTS tax_lots(
+    tax_lot_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE RESTRICT,
+    execution_id INTEGER REFERENCES executions(execution_id) ON DELETE SET NULL,
+    is_short BOOLEAN NOT NULL DEFAULT FALSE,
+    quantity BIGINT NOT NULL CHECK (quantity > 0),
+    remaining_quantity BIGINT NOT NULL CHECK (remaining_quantity >= 0),
+    cost_per_share_cents BIGINT NOT NULL,
+    acquired_at TIMESTAMPTZ DEFAULT NOW(),
+    closed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_tax_lots_user_id_stock_id ON tax_lots(user_id, stock_id);
+
+DROP TABLE IF EXISTS realized_lots;
+CREATE TABLE IF NOT EXISTS realized_lots(
+    realized_lot_id SERIAL PRIMARY KEY,
+    tax_lot_id INTEGER NOT NULL REFERENCES tax_lots(tax_lot_id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE RESTRICT,
+    execution_id INTEGER REFERENCES executions(execution_id) ON DELETE SET NULL,
+    is_short BOOLEAN NOT NULL DEFAULT FALSE,
+    quantity BIGINT NOT NULL CHECK (quantity > 0),
+    cost_per_share_cents BIGINT NOT NULL,
+    price_per_share_cents BIGINT NOT NULL,
+    realized_pnl_cents BIGINT NOT NULL,
+    closed_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_realized_lots_user_id ON realized_lots(user_id);
+
+-- Existing positions become one lot at their average cost
+INSERT INTO tax_lots (user_id, stock_id, is_short, quantity, remaining_quantity, cost_per_share_cents, acquired_at)
+SELECT user_id, stock_id, quantity < 0, abs(quantity), abs(quantity), average_cost_per_share_cents, created_at
+FROM holdings WHERE quantity != 0;
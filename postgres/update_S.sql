@@ -0,0 +1,56 @@
+DROP TABLE IF EXISTS journal_entries;
+DROP TABLE IF EXISTS journals;
+
+CREATE TABLE IF NOT EXISTS journals(
+    journal_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id),
+    event_type TEXT NOT NULL,
+    execution_id INTEGER REFERENCES executions(execution_id),
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_journals_user_id ON journals(user_id);
+
+CREATE TABLE IF NOT EXISTS journal_entries(
+    journal_entry_id SERIAL PRIMARY KEY,
+    journal_id INTEGER NOT NULL REFERENCES journals(journal_id),
+    user_id INTEGER NOT NULL REFERENCES users(user_id),
+    account TEXT NOT NULL,
+    stock_id INTEGER REFERENCES stocks(stock_id),
+    debit BIGINT NOT NULL DEFAULT 0 CHECK (debit >= 0),
+    credit BIGINT NOT NULL DEFAULT 0 CHECK (credit >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_user_id_account ON journal_entries(user_id, account);
+CREATE INDEX IF NOT EXISTS idx_journal_entries_journal_id ON journal_entries(journal_id);
+
+CREATE OR REPLACE FUNCTION prevent_journal_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'the journal is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER journals_append_only BEFORE UPDATE OR DELETE ON journals
+    FOR EACH ROW EXECUTE FUNCTION prevent_journal_change();
+CREATE TRIGGER journal_entries_append_only BEFORE UPDATE OR DELETE ON journal_entries
+    FOR EACH ROW EXECUTE FUNCTION prevent_journal_change();
+
+-- Balances before the ledger are posted as opening balances
+INSERT INTO journals (user_id, event_type, description)
+SELECT user_id, 'OPENING_BALANCE', 'Balances before the ledger was introduced' FROM users;
+
+INSERT INTO journal_entries (journal_id, user_id, account, stock_id, debit, credit)
+SELECT j.journal_id, u.user_id, 'USER_CASH', NULL, greatest(u.cash_balance_cents, 0), greatest(-u.cash_balance_cents, 0)
+FROM users u JOIN journals j ON j.user_id = u.user_id AND j.event_type = 'OPENING_BALANCE'
+UNION ALL
+SELECT j.journal_id, u.user_id, 'OPENING_BALANCE', NULL, greatest(-u.cash_balance_cents, 0), greatest(u.cash_balance_cents, 0)
+FROM users u JOIN journals j ON j.user_id = u.user_id AND j.event_type = 'OPENING_BALANCE'
+UNION ALL
+SELECT j.journal_id, h.user_id, 'USER_SECURITIES', h.stock_id, greatest(h.quantity, 0), greatest(-h.quantity, 0)
+FROM holdings h JOIN journals j ON j.user_id = h.user_id AND j.event_type = 'OPENING_BALANCE'
+WHERE h.quantity != 0
+UNION ALL
+SELECT j.journal_id, h.user_id, 'OPENING_BALANCE', h.stock_id, greatest(-h.quantity, 0), greatest(h.quantity, 0)
+FROM holdings h JOIN journals j ON j.user_id = h.user_id AND j.event_type = 'OPENING_BALANCE'
+WHERE h.quantity != 0;
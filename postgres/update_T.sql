@@ -0,0 +1,34 @@
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN drip_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+DROP TABLE IF EXISTS dividend_payments;
+DROP TABLE IF EXISTS corporate_actions;
+
+CREATE TABLE IF NOT EXISTS corporate_actions(
+    corporate_action_id SERIAL PRIMARY KEY,
+    stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE CASCADE,
+    action_type TEXT NOT NULL,
+    split_from INTEGER NOT NULL DEFAULT 1,
+    split_to INTEGER NOT NULL DEFAULT 1,
+    dividend_per_share_cents BIGINT NOT NULL DEFAULT 0,
+    ex_date TIMESTAMPTZ NOT NULL,
+    pay_date TIMESTAMPTZ,
+    status TEXT NOT NULL DEFAULT 'SCHEDULED',
+    created_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    processed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_corporate_actions_status ON corporate_actions(status);
+
+CREATE TABLE IF NOT EXISTS dividend_payments(
+    dividend_payment_id SERIAL PRIMARY KEY,
+    corporate_action_id INTEGER NOT NULL REFERENCES corporate_actions(corporate_action_id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    quantity BIGINT NOT NULL,
+    amount_cents BIGINT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    paid_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_dividend_payments_corporate_action_id ON dividend_payments(corporate_action_id);
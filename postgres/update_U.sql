@@ -0,0 +1,54 @@
+DROP TABLE IF EXISTS market_holidays;
+DROP TABLE IF EXISTS market_session_events;
+
+CREATE TABLE IF NOT EXISTS market_holidays(
+    holiday_date DATE PRIMARY KEY,
+    name TEXT NOT NULL,
+    early_close BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS market_session_events(
+    market_session_event_id SERIAL PRIMARY KEY,
+    session TEXT NOT NULL,
+    trading_date DATE NOT NULL,
+    started_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+INSERT INTO market_holidays (holiday_date, name, early_close) VALUES
+    ('2025-01-01', 'New Year''s Day', FALSE),
+    ('2025-01-20', 'Martin Luther King Jr. Day', FALSE),
+    ('2025-02-17', 'Washington''s Birthday', FALSE),
+    ('2025-04-18', 'Good Friday', FALSE),
+    ('2025-05-26', 'Memorial Day', FALSE),
+    ('2025-06-19', 'Juneteenth', FALSE),
+    ('2025-07-03', 'Independence Day (early close)', TRUE),
+    ('2025-07-04', 'Independence Day', FALSE),
+    ('2025-09-01', 'Labor Day', FALSE),
+    ('2025-11-27', 'Thanksgiving Day', FALSE),
+    ('2025-11-28', 'Day after Thanksgiving (early close)', TRUE),
+    ('2025-12-24', 'Christmas Eve (early close)', TRUE),
+    ('2025-12-25', 'Christmas Day', FALSE),
+    ('2026-01-01', 'New Year''s Day', FALSE),
+    ('2026-01-19', 'Martin Luther King Jr. Day', FALSE),
+    ('2026-02-16', 'Washington''s Birthday', FALSE),
+    ('2026-04-03', 'Good Friday', FALSE),
+    ('2026-05-25', 'Memorial Day', FALSE),
+    ('2026-06-19', 'Juneteenth', FALSE),
+    ('2026-07-03', 'Independence Day (observed)', FALSE),
+    ('2026-09-07', 'Labor Day', FALSE),
+    ('2026-11-26', 'Thanksgiving Day', FALSE),
+    ('2026-11-27', 'Day after Thanksgiving (early close)', TRUE),
+    ('2026-12-24', 'Christmas Eve (early close)', TRUE),
+    ('2026-12-25', 'Christmas Day', FALSE),
+    ('2027-01-01', 'New Year''s Day', FALSE),
+    ('2027-01-18', 'Martin Luther King Jr. Day', FALSE),
+    ('2027-02-15', 'Washington''s Birthday', FALSE),
+    ('2027-03-26', 'Good Friday', FALSE),
+    ('2027-05-31', 'Memorial Day', FALSE),
+    ('2027-06-18', 'Juneteenth (observed)', FALSE),
+    ('2027-07-05', 'Independence Day (observed)', FALSE),
+    ('2027-09-06', 'Labor Day', FALSE),
+    ('2027-11-25', 'Thanksgiving Day', FALSE),
+    ('2027-11-26', 'Day after Thanksgiving (early close)', TRUE),
+    ('2027-12-24', 'Christmas Day (observed)', FALSE)
+ON CONFLICT (holiday_date) DO NOTHING;
@@ -0,0 +1,13 @@
+-- Quantities become fixed-point millionths of a share
+UPDATE holdings SET quantity = quantity * 1000000;
+UPDATE orders SET quantity = quantity * 1000000, filled_quantity = filled_quantity * 1000000;
+UPDATE executions SET quantity = quantity * 1000000;
+UPDATE order_amendments SET old_quantity = old_quantity * 1000000, new_quantity = new_quantity * 1000000;
+UPDATE tax_lots SET quantity = quantity * 1000000, remaining_quantity = remaining_quantity * 1000000;
+UPDATE realized_lots SET quantity = quantity * 1000000;
+UPDATE dividend_payments SET quantity = quantity * 1000000;
+
+-- The journal is append-only, the securities entries are rescaled with its trigger off
+ALTER TABLE journal_entries DISABLE TRIGGER journal_entries_append_only;
+UPDATE journal_entries SET debit = debit * 1000000, credit = credit * 1000000 WHERE stock_id IS NOT NULL;
+ALTER TABLE journal_entries ENABLE TRIGGER journal_entries_append_only;
@@ -0,0 +1,50 @@
+ALTER TABLE stocks ADD COLUMN implied_volatility DOUBLE PRECISION NOT NULL DEFAULT 0.30;
+
+DROP TABLE IF EXISTS option_holdings;
+DROP TABLE IF EXISTS option_orders;
+DROP TABLE IF EXISTS option_contracts;
+
+CREATE TABLE IF NOT EXISTS option_contracts(
+    option_contract_id SERIAL PRIMARY KEY,
+    stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE CASCADE,
+    symbol TEXT UNIQUE NOT NULL,
+    option_type TEXT NOT NULL,
+    strike_price_cents BIGINT NOT NULL,
+    deliverable_quantity BIGINT NOT NULL DEFAULT 100000000,
+    expires_at TIMESTAMPTZ NOT NULL,
+    status TEXT NOT NULL DEFAULT 'ACTIVE',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_option_contracts_stock_id_status ON option_contracts(stock_id, status);
+
+CREATE TABLE IF NOT EXISTS option_orders(
+    option_order_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    option_contract_id INTEGER NOT NULL REFERENCES option_contracts(option_contract_id) ON DELETE RESTRICT,
+    trade_type TEXT NOT NULL,
+    order_type TEXT NOT NULL,
+    order_status TEXT NOT NULL,
+    quantity BIGINT NOT NULL,
+    limit_price_cents BIGINT NOT NULL DEFAULT 0,
+    price_per_share_cents BIGINT NOT NULL DEFAULT 0,
+    total_order_value_cents BIGINT NOT NULL DEFAULT 0,
+    fee_cents BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    executed_at TIMESTAMPTZ,
+    notes TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_option_orders_user_id ON option_orders(user_id);
+CREATE INDEX IF NOT EXISTS idx_option_orders_order_status ON option_orders(order_status);
+
+CREATE TABLE IF NOT EXISTS option_holdings(
+    option_holding_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    option_contract_id INTEGER NOT NULL REFERENCES option_contracts(option_contract_id) ON DELETE RESTRICT,
+    quantity BIGINT NOT NULL DEFAULT 0,
+    average_cost_per_share_cents BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, option_contract_id)
+);
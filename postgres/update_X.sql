@@ -0,0 +1,45 @@
+-- Simulated FX rates, in USD per unit of the currency, moved by the rate generator
+CREATE TABLE IF NOT EXISTS fx_rates(
+    currency TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    usd_rate_micros BIGINT NOT NULL,
+    min_rate_generator_micros BIGINT NOT NULL,
+    max_rate_generator_micros BIGINT NOT NULL,
+    spread_bps DOUBLE PRECISION NOT NULL DEFAULT 20,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+INSERT INTO fx_rates (currency, name, usd_rate_micros, min_rate_generator_micros, max_rate_generator_micros, spread_bps) VALUES
+    ('USD', 'US Dollar', 1000000, 1000000, 1000000, 0),
+    ('EUR', 'Euro', 1080000, 1050000, 1110000, 20),
+    ('GBP', 'British Pound', 1270000, 1230000, 1310000, 20),
+    ('JPY', 'Japanese Yen', 6700, 6500, 6900, 30),
+    ('CAD', 'Canadian Dollar', 730000, 710000, 750000, 25)
+ON CONFLICT (currency) DO NOTHING;
+
+ALTER TABLE stocks ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD' REFERENCES fx_rates(currency);
+ALTER TABLE users ADD COLUMN base_currency TEXT NOT NULL DEFAULT 'USD' REFERENCES fx_rates(currency);
+
+DROP TABLE IF EXISTS cash_balances;
+
+CREATE TABLE IF NOT EXISTS cash_balances(
+    cash_balance_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    currency TEXT NOT NULL REFERENCES fx_rates(currency),
+    balance_cents BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, currency)
+);
+
+-- The journal is append-only, the existing cash entries get their currency with its trigger off
+ALTER TABLE journal_entries ADD COLUMN currency TEXT;
+ALTER TABLE journal_entries DISABLE TRIGGER journal_entries_append_only;
+UPDATE journal_entries SET currency = 'USD' WHERE stock_id IS NULL;
+ALTER TABLE journal_entries ENABLE TRIGGER journal_entries_append_only;
+
+INSERT INTO stocks (ticker, name, currency, opening_price_cents, current_price_cents, min_price_generator_cents, max_price_generator_cents)
+VALUES
+    ('SAP', 'SAP SE', 'EUR', 22000, 22000, 21000, 23000),
+    ('AZN', 'AstraZeneca PLC', 'GBP', 11000, 11000, 10500, 11500)
+    ON CONFLICT (ticker) DO NOTHING;
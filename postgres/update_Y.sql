@@ -0,0 +1,26 @@
+-- Target allocation of a user's portfolio, the rest of the stocks' percents is held in cash
+DROP TABLE IF EXISTS target_allocations;
+DROP TABLE IF EXISTS portfolio_targets;
+
+CREATE TABLE IF NOT EXISTS portfolio_targets(
+    user_id INTEGER PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    cash_percent DOUBLE PRECISION NOT NULL DEFAULT 0,
+    drift_threshold_percent DOUBLE PRECISION NOT NULL DEFAULT 5, -- Percentage points a position drifts before it is traded
+    min_trade_cents BIGINT NOT NULL DEFAULT 100,               -- Smaller trades are skipped
+    rebalance_frequency TEXT NOT NULL DEFAULT 'NONE',          -- NONE, DAILY, WEEKLY or MONTHLY
+    next_rebalance_at TIMESTAMPTZ,
+    last_rebalanced_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_portfolio_targets_next_rebalance_at ON portfolio_targets(next_rebalance_at);
+
+CREATE TABLE IF NOT EXISTS target_allocations(
+    target_allocation_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES portfolio_targets(user_id) ON DELETE CASCADE,
+    stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE RESTRICT,
+    target_percent DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, stock_id)
+);
@@ -0,0 +1,19 @@
+-- Recurring market buys of a fixed amount (dollar-cost averaging), run by the price routine during the regular session
+DROP TABLE IF EXISTS recurring_investments;
+
+CREATE TABLE IF NOT EXISTS recurring_investments(
+    recurring_investment_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    stock_id INTEGER NOT NULL REFERENCES stocks(stock_id) ON DELETE RESTRICT,
+    amount_cents BIGINT NOT NULL,                       -- In the stock's currency
+    cadence TEXT NOT NULL,                              -- DAILY, WEEKLY, BIWEEKLY or MONTHLY
+    status TEXT NOT NULL DEFAULT 'ACTIVE',              -- ACTIVE or PAUSED
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_run_at TIMESTAMPTZ,
+    last_run_status TEXT NOT NULL DEFAULT '',           -- EXECUTED, SKIPPED (not enough cash) or FAILED
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_recurring_investments_user_id ON recurring_investments(user_id);
+CREATE INDEX IF NOT EXISTS idx_recurring_investments_status_next_run_at ON recurring_investments(status, next_run_at);
@@ -0,0 +1,51 @@
+package routine
+
+import (
+	"fmt"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+)
+
+func initFxRateRoutine() {
+	go startFxRateLoop()
+}
+
+func startFxRateLoop() {
+
+	fxRates := db.GetFxRates()
+
+	// The rates move like stock prices, in millionths of a dollar per unit of the currency
+	generators := make(map[string]*StockPriceGenerator)
+	for _, fxRate := range fxRates {
+		if fxRate.Currency == util.CurrencyUsd {
+			continue
+		}
+		generators[fxRate.Currency] = NewStockPriceGenerator(
+			fxRate.Currency,
+			fxRate.UsdRateMicros,
+			fxRate.MinRateGeneratorMicros,
+			fxRate.MaxRateGeneratorMicros,
+			max(fxRate.UsdRateMicros/10000, 1),
+			fxRate.SpreadBps,
+		)
+	}
+
+	// FX trades around the clock, the rates move whatever the market session
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for currency, generator := range generators {
+			err := db.DB.Model(&orm.FxRates{}).Where("currency = ?", currency).
+				Updates(map[string]interface{}{
+					"usd_rate_micros": generator.GenerateNewPrice(),
+					"updated_at":      time.Now(),
+				}).Error
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package routine
+
+import (
+	"time"
+	"trading_platform_backend/service"
+)
+
+func initOrderExpiryRoutine() {
+	go startOrderExpiryLoop()
+}
+
+func startOrderExpiryLoop() {
+	// Run every minute, idempotency keys past their TTL are deleted.
+	// DAY orders are expired by the market session at the regular close.
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		service.DeleteExpiredIdempotencyKeys(time.Now())
+		<-ticker.C
+	}
+}
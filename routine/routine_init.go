@@ -5,4 +5,6 @@ func InitRoutines() {
 	initMarketWebSocket()
 	initStockPriceGenerator()
 	initNewsFetchRoutine()
+	initOrderExpiryRoutine()
+	initFxRateRoutine()
 }
@@ -7,6 +7,8 @@ import (
 	"time"
 	"trading_platform_backend/db"
 	"trading_platform_backend/orm"
+	"trading_platform_backend/service"
+	"trading_platform_backend/util"
 
 	"gorm.io/gorm/clause"
 )
@@ -18,9 +20,23 @@ type StockPriceGenerator struct {
 	MaxPrice     int64
 	MaxChange    int64      // Maximum absolute change per minute
 	mu           sync.Mutex // Mutex to protect CurrentPrice during concurrent access
+
+	SpreadBps float64 // Bid/ask spread in basis points of the current price
+	Bid       int64   // Quote published around CurrentPrice on every new price
+	Ask       int64
 }
 
 func initStockPriceGenerator() {
+	// Rebuild the order books from the pending orders before the first tick
+	service.InitOrderBooks()
+
+	// Restore the market session before orders come in, and list the options missing since the last open
+	_, notifications := service.AdvanceMarketSession(time.Now())
+	for _, notification := range notifications {
+		WsHub.Notify <- notification
+	}
+	service.GenerateOptionChains(time.Now())
+
 	// Initialize the stock price generator
 	go startGeneratorLoop()
 }
@@ -30,6 +46,7 @@ func startGeneratorLoop() {
 	stocks := db.GetAllStocks()
 	stocksMap := make(map[string]*orm.Stocks)
 	generators := make([]*StockPriceGenerator, 0)
+	generatorsMap := make(map[int64]*StockPriceGenerator)
 
 	for i := range stocks {
 		generators = append(generators, NewStockPriceGenerator(
@@ -38,8 +55,10 @@ func startGeneratorLoop() {
 			stocks[i].MinPriceGeneratorCents,
 			stocks[i].MaxPriceGeneratorCents,
 			2.00,
+			stocks[i].SpreadBps,
 		))
 		stocksMap[stocks[i].Ticker] = &stocks[i]
+		generatorsMap[stocks[i].StockID] = generators[i]
 	}
 
 	// Create a ticker that fires every minute
@@ -49,23 +68,96 @@ func startGeneratorLoop() {
 	// Loop indefinitely, generating a new price every minute
 
 	for range ticker.C {
+
+		session, notifications := service.AdvanceMarketSession(time.Now())
+		for _, notification := range notifications {
+			WsHub.Notify <- notification
+		}
+
+		// Splits, dividend records and dividend payments due, the split stocks restart from their adjusted prices
+		notifications, splitStockIds := service.ProcessCorporateActions(time.Now())
+		for _, notification := range notifications {
+			WsHub.Notify <- notification
+		}
+		for _, stockId := range splitStockIds {
+			stock := db.GetStockById(stockId)
+			generator := generatorsMap[stockId]
+			if generator == nil {
+				continue
+			}
+			*stocksMap[generator.Ticker] = stock
+			generator.Reset(stock.CurrentPriceCents, stock.MinPriceGeneratorCents, stock.MaxPriceGeneratorCents)
+		}
+
+		// Prices only move, and orders only fill, while the market is open
+		if !service.IsMarketSessionTicking(session) {
+			continue
+		}
+
+		previousPrices := make(map[string]int64, len(generators))
 		for _, generator := range generators {
+			previousPrices[generator.Ticker] = stocksMap[generator.Ticker].CurrentPriceCents
 			price := generator.GenerateNewPrice()
 			//fmt.Printf("[%s] New Stock Price: %s $%d\n", time.Now().Format("15:04:05"), generator.Ticker, price)
 
 			// Here you would typically publish this price, store it, or do something else with it.
 			stocksMap[generator.Ticker].CurrentPriceCents = price
+			stocksMap[generator.Ticker].BidPriceCents = generator.Bid
+			stocksMap[generator.Ticker].AskPriceCents = generator.Ask
 		}
 
 		err := db.DB.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "ticker"}},                         // The column to check for conflicts.
-			DoUpdates: clause.AssignmentColumns([]string{"current_price_cents"}), // The column to update.
+			Columns: []clause.Column{{Name: "ticker"}}, // The column to check for conflicts.
+			// The columns to update.
+			DoUpdates: clause.AssignmentColumns([]string{"current_price_cents", "bid_price_cents", "ask_price_cents"}),
 		}).Create(&stocks).Error
 
 		if err != nil {
 			fmt.Println(err)
 		}
 
+		// Trigger the stop orders crossed by the new prices, match the crossing orders of the book,
+		// then fill the pending orders against the generator's quote
+		for _, generator := range generators {
+			stock := stocksMap[generator.Ticker]
+
+			// Stops only trigger during the regular session
+			if session == util.MarketSessionRegular {
+				notifications := service.ProcessStopOrders(*stock, generator.CurrentPrice)
+				for _, notification := range notifications {
+					WsHub.Notify <- notification
+				}
+			}
+
+			service.MatchOrderBook(*stock)
+			service.ProcessPendingOrders(*stock)
+
+			// Watchlist alerts fire in every session the price moves, their trades wait for the regular session
+			for _, notification := range service.ProcessWatchlistAlerts(*stock, previousPrices[stock.Ticker], time.Now()) {
+				WsHub.Notify <- notification
+			}
+		}
+
+		// Option orders fill at the premiums of the new prices, and the scheduled rebalances, recurring
+		// investments and watch trades deferred to the regular session trade at them
+		if session == util.MarketSessionRegular {
+			service.ProcessOptionOrders(time.Now())
+			for _, notification := range service.RunScheduledRebalances(time.Now()) {
+				WsHub.Notify <- notification
+			}
+			for _, notification := range service.RunRecurringInvestments(time.Now()) {
+				WsHub.Notify <- notification
+			}
+			for _, notification := range service.RunTriggeredWatchActions(time.Now()) {
+				WsHub.Notify <- notification
+			}
+		}
+
+		// Margin calls and forced liquidations at the new prices
+		for _, notification := range service.CheckMarginAccounts(time.Now()) {
+			WsHub.Notify <- notification
+		}
+
 		// Broadcast to both dashboard and market WebSocket hubs
 		WsHub.Broadcast <- ""
 		MarketWsHub.Broadcast <- ""
@@ -73,7 +165,9 @@ func startGeneratorLoop() {
 	}
 }
 
-func NewStockPriceGenerator(ticker string, openingPrice, minPrice, maxPrice, maxChange int64) *StockPriceGenerator {
+func NewStockPriceGenerator(ticker string, openingPrice, minPrice, maxPrice, maxChange int64, spreadBps float64) *StockPriceGenerator {
+
+	bid, ask := util.GetBidAskCents(openingPrice, spreadBps)
 
 	return &StockPriceGenerator{
 		Ticker:       ticker,
@@ -81,9 +175,23 @@ func NewStockPriceGenerator(ticker string, openingPrice, minPrice, maxPrice, max
 		MinPrice:     minPrice,
 		MaxPrice:     maxPrice,
 		MaxChange:    maxChange,
+		SpreadBps:    spreadBps,
+		Bid:          bid,
+		Ask:          ask,
 	}
 }
 
+// Reset moves the generator to a new price range, used when a split changes the price of the stock.
+func (s *StockPriceGenerator) Reset(currentPrice, minPrice, maxPrice int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.CurrentPrice = currentPrice
+	s.MinPrice = minPrice
+	s.MaxPrice = maxPrice
+	s.Bid, s.Ask = util.GetBidAskCents(currentPrice, s.SpreadBps)
+}
+
 func (s *StockPriceGenerator) GenerateNewPrice() int64 {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -127,6 +235,7 @@ func (s *StockPriceGenerator) GenerateNewPrice() int64 {
 	}
 
 	s.CurrentPrice = newPrice // No need for explicit rounding since it's int64
+	s.Bid, s.Ask = util.GetBidAskCents(newPrice, s.SpreadBps)
 
 	return s.CurrentPrice
 }
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 	"sync"
+	"trading_platform_backend/model"
 	"trading_platform_backend/service"
 
 	"github.com/gorilla/websocket"
@@ -32,6 +33,7 @@ type Client struct {
 type Hub struct {
 	clients    map[int64]*websocket.Conn
 	Broadcast  chan string
+	Notify     chan model.NotificationModel
 	register   chan Client
 	unregister chan int64
 	mutex      sync.Mutex
@@ -91,6 +93,21 @@ func (h *Hub) Run() {
 				}
 			}
 			h.mutex.Unlock()
+
+		case notification := <-h.Notify:
+			// Push a notification to its owner only, if connected.
+			h.mutex.Lock()
+
+			if conn, ok := h.clients[notification.UserID]; ok {
+				data, err := json.Marshal(notification)
+				if err != nil {
+					log.Printf("Error marshalling notification: %v", err)
+				} else if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+					log.Printf("Write error: %v. Unregistering client.", err)
+					go func(u int64) { h.unregister <- u }(notification.UserID)
+				}
+			}
+			h.mutex.Unlock()
 		}
 	}
 }
@@ -151,6 +168,7 @@ func initWebSocket() {
 	// Create and run the WebSocket hub.
 	WsHub = Hub{
 		Broadcast:  make(chan string),
+		Notify:     make(chan model.NotificationModel),
 		register:   make(chan Client),
 		unregister: make(chan int64),
 		clients:    make(map[int64]*websocket.Conn),
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+
+	"gorm.io/gorm"
+)
+
+// basketLeg is a validated leg of a basket order, Index is its position in the request.
+type basketLeg struct {
+	Index   int
+	Request model.OrderRequest
+	Stock   orm.Stocks
+}
+
+// PlaceBasketOrder places a list of market buy and sell legs for one user. The sells go first so their proceeds fund
+// the buys, and every leg is checked against the excess equity projected after the legs before it, at the quotes
+// with slippage and fees. ALL_OR_NONE baskets execute in one transaction and any failed leg rejects the whole basket,
+// BEST_EFFORT baskets execute the legs one by one and skip the ones that fail. The result of every leg is returned.
+func PlaceBasketOrder(basketRequest model.BasketOrderRequest) (model.BasketOrderModel, error) {
+
+	if basketRequest.ExecutionMode != util.BasketModeAllOrNone && basketRequest.ExecutionMode != util.BasketModeBestEffort {
+		return model.BasketOrderModel{}, errors.New("unknown execution mode " + basketRequest.ExecutionMode)
+	}
+	if len(basketRequest.Legs) == 0 || len(basketRequest.Legs) > util.MaxBasketLegs {
+		return model.BasketOrderModel{}, fmt.Errorf("a basket needs 1 to %d legs", util.MaxBasketLegs)
+	}
+
+	//the legs fill right away, so baskets are not queued outside the sessions accepting market orders
+	if session := GetCurrentMarketSession(); !isOrderTypeAccepted(session, util.OrderTypeMarket) {
+		return model.BasketOrderModel{}, errors.New("basket orders are not accepted during the " + session + " session")
+	}
+
+	user := db.GetUserById(basketRequest.UserID)
+	if user.UserID == 0 {
+		return model.BasketOrderModel{}, errors.New("user does not exist")
+	}
+
+	basket := model.BasketOrderModel{
+		ExecutionMode: basketRequest.ExecutionMode,
+		Legs:          make([]model.BasketLegModel, len(basketRequest.Legs)),
+	}
+
+	legs := make([]basketLeg, 0, len(basketRequest.Legs))
+	tickers := make(map[string]bool)
+	for i, legRequest := range basketRequest.Legs {
+		basket.Legs[i] = model.BasketLegModel{
+			Ticker:    legRequest.Ticker,
+			TradeType: legRequest.TradeType,
+		}
+
+		//one leg per stock, the buying power check of a sell does not see the other legs
+		if tickers[legRequest.Ticker] {
+			failBasketLeg(&basket, i, errors.New("the basket already has a leg for "+legRequest.Ticker))
+			continue
+		}
+		tickers[legRequest.Ticker] = true
+
+		legRequest.UserID = user.UserID
+		leg, err := validateBasketLeg(i, legRequest)
+		if err != nil {
+			failBasketLeg(&basket, i, err)
+			continue
+		}
+		basket.Legs[i].Quantity = util.ConvertQuantityToShares(leg.Request.Quantity)
+		legs = append(legs, leg)
+	}
+
+	//the sells first, in the order of the request
+	sort.SliceStable(legs, func(i, j int) bool {
+		return legs[i].Request.TradeType == util.TradeTypeSell && legs[j].Request.TradeType != util.TradeTypeSell
+	})
+
+	if basketRequest.ExecutionMode == util.BasketModeAllOrNone {
+		legs = checkBasketBuyingPower(user, legs, &basket)
+		if basket.FailedLegs > 0 {
+			rejectBasket(&basket, "not executed, the basket was rejected")
+			return basket, nil
+		}
+		placeAllOrNoneBasket(user, legs, &basket)
+	} else {
+		placeBestEffortBasket(user, legs, &basket)
+	}
+
+	return basket, nil
+}
+
+// validateBasketLeg checks a leg and converts its amount to a quantity, then runs the risk checks.
+func validateBasketLeg(index int, legRequest model.OrderRequest) (basketLeg, error) {
+
+	if legRequest.TradeType != util.TradeTypeBuy && legRequest.TradeType != util.TradeTypeSell {
+		return basketLeg{}, errors.New("unknown trade type " + legRequest.TradeType)
+	}
+	if legRequest.OrderType != "" && legRequest.OrderType != util.OrderTypeMarket {
+		return basketLeg{}, errors.New("basket legs must be market orders")
+	}
+	if (legRequest.Quantity == 0) == (legRequest.AmountCents == 0) || legRequest.Quantity < 0 {
+		return basketLeg{}, errors.New("a positive quantity or an amount is required")
+	}
+
+	stock := db.GetStockByTicker(legRequest.Ticker)
+	if stock.StockID == 0 {
+		return basketLeg{}, errors.New("stock " + legRequest.Ticker + " not found")
+	}
+
+	legRequest.OrderType = util.OrderTypeMarket
+	legRequest.TimeInForce = util.TimeInForceDay
+
+	if legRequest.AmountCents != 0 {
+		var err error
+		if legRequest, err = convertAmountToQuantity(legRequest); err != nil {
+			return basketLeg{}, err
+		}
+	}
+
+	if err := checkOrderRisk(legRequest); err != nil {
+		return basketLeg{}, err
+	}
+
+	return basketLeg{Index: index, Request: legRequest, Stock: stock}, nil
+}
+
+// checkBasketBuyingPower projects the user's excess equity, in USD, through the legs at the current quotes and
+// fails the legs it does not cover. A leg closing a position releases its initial margin, one opening or growing a
+// position needs it, and every leg costs its fee and its spread and slippage from the current price. It returns the
+// legs left.
+func checkBasketBuyingPower(user orm.Users, legs []basketLeg, basket *model.BasketOrderModel) []basketLeg {
+
+	fxRates := getFxRates()
+	feeSchedule := getFeeSchedule(user)
+	excessEquityCents := getMarginAccount(db.DB, user, getStocksById()).getExcessEquityCents()
+
+	checkedLegs := make([]basketLeg, 0, len(legs))
+	for _, leg := range legs {
+		tradeType, quantity, stock := leg.Request.TradeType, leg.Request.Quantity, leg.Stock
+
+		fillPriceCents := getFillPriceCents(stock, tradeType, quantity, 0)
+		valueCents := getUsdValueCents(fxRates, stock, quantity, fillPriceCents)
+		marketValueCents := getUsdValueCents(fxRates, stock, quantity, stock.CurrentPriceCents)
+		feeCents := getFeeCents(feeSchedule, tradeType, quantity, valueCents)
+
+		//the quantity closing a position, one leg per stock so the holding is the current one
+		holding := db.GetHoldingByUserIdAndStockId(user.UserID, stock.StockID)
+		closingQuantity := -holding.Quantity
+		costCents := valueCents - marketValueCents + feeCents
+		if tradeType == util.TradeTypeSell {
+			closingQuantity = holding.Quantity - db.GetReservedSellQuantityByUserIdAndStockIdTx(db.DB, user.UserID, stock.StockID)
+			costCents = marketValueCents - valueCents + feeCents
+		}
+		closingQuantity = min(max(closingQuantity, 0), quantity)
+
+		marginPercent := getInitialMarginPercent(stock)
+		releasedCents := getMarginCents(getUsdValueCents(fxRates, stock, closingQuantity, stock.CurrentPriceCents), marginPercent)
+		requiredCents := getMarginCents(getUsdValueCents(fxRates, stock, quantity-closingQuantity, fillPriceCents), marginPercent)
+
+		//a leg freeing up equity always goes through
+		projectedCents := excessEquityCents + releasedCents - requiredCents - costCents
+		if projectedCents < 0 && projectedCents < excessEquityCents {
+			failBasketLeg(basket, leg.Index, fmt.Errorf("not enough buying power, $%.2f of margin and costs needed and $%.2f projected after the legs before it",
+				util.ConvertCentsToDollars(requiredCents+costCents-releasedCents), util.ConvertCentsToDollars(max(excessEquityCents, 0))))
+			continue
+		}
+		excessEquityCents = projectedCents
+		checkedLegs = append(checkedLegs, leg)
+	}
+
+	return checkedLegs
+}
+
+func placeAllOrNoneBasket(user orm.Users, legs []basketLeg, basket *model.BasketOrderModel) {
+
+	failedIndex := -1
+	orders := make([]orm.Orders, len(legs))
+
+	err := bookTransaction(func(tx *gorm.DB) error {
+
+		group, err := createBasketGroup(tx, user.UserID)
+		if err != nil {
+			return err
+		}
+		basket.OrderGroupID = group.OrderGroupID
+
+		for i, leg := range legs {
+			if orders[i], err = executeBasketLeg(tx, &user, leg, group.OrderGroupID); err != nil {
+				failedIndex = leg.Index
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		basket.OrderGroupID = 0
+		if failedIndex >= 0 {
+			failBasketLeg(basket, failedIndex, err)
+		} else {
+			basket.ErrorMessage = err.Error()
+		}
+		rejectBasket(basket, "not executed, the basket was rolled back")
+		return
+	}
+
+	for i, leg := range legs {
+		setBasketLegOrder(basket, leg.Index, orders[i])
+	}
+}
+
+func placeBestEffortBasket(user orm.Users, legs []basketLeg, basket *model.BasketOrderModel) {
+
+	group, err := createBasketGroup(db.DB, user.UserID)
+	if err != nil {
+		basket.ErrorMessage = err.Error()
+		rejectBasket(basket, "not executed")
+		return
+	}
+	basket.OrderGroupID = group.OrderGroupID
+
+	executeLegs := func(legs []basketLeg) {
+		for _, leg := range legs {
+			var order orm.Orders
+			err := bookTransaction(func(tx *gorm.DB) error {
+				user := db.GetUserByIdTx(tx, user.UserID)
+				var err error
+				order, err = executeBasketLeg(tx, &user, leg, group.OrderGroupID)
+				return err
+			})
+			if err != nil {
+				failBasketLeg(basket, leg.Index, err)
+				continue
+			}
+			setBasketLegOrder(basket, leg.Index, order)
+		}
+	}
+
+	buyIndex := sort.Search(len(legs), func(i int) bool {
+		return legs[i].Request.TradeType != util.TradeTypeSell
+	})
+
+	//the buys are checked against the buying power the sells actually left
+	executeLegs(legs[:buyIndex])
+	executeLegs(checkBasketBuyingPower(db.GetUserById(user.UserID), legs[buyIndex:], basket))
+}
+
+func createBasketGroup(tx *gorm.DB, userId int64) (orm.OrderGroups, error) {
+
+	group := orm.OrderGroups{
+		UserID:    userId,
+		GroupType: util.OrderGroupTypeBasket,
+		CreatedAt: time.Now(),
+	}
+	if err := tx.Create(&group).Error; err != nil {
+		fmt.Println("Failed to save order group:", err)
+		return group, errors.New("failed to save order group")
+	}
+
+	return group, nil
+}
+
+// executeBasketLeg executes a leg in tx as an order of the basket's group and returns the order.
+func executeBasketLeg(tx *gorm.DB, user *orm.Users, leg basketLeg, orderGroupId int64) (orm.Orders, error) {
+
+	orderTemplate := newMarketOrder()
+	orderTemplate.OrderGroupID = &orderGroupId
+	orderTemplate.Notes = fmt.Sprintf("basket order %d", orderGroupId)
+
+	//the buying power check reads through tx, so the buys see the sells of the basket executed before them
+	if leg.Request.TradeType == util.TradeTypeSell {
+		return sellStocks(tx, user, leg.Stock, leg.Request.Quantity, orderTemplate)
+	}
+	return buyStocks(tx, user, leg.Stock, leg.Request.Quantity, orderTemplate)
+}
+
+func setBasketLegOrder(basket *model.BasketOrderModel, index int, order orm.Orders) {
+	leg := &basket.Legs[index]
+	leg.OrderID = order.OrderID
+	leg.OrderStatus = order.OrderStatus
+	leg.PricePerShareDollars = util.ConvertCentsToDollars(order.AverageFillPriceCents)
+	leg.FeeDollars = util.ConvertCentsToDollars(order.FeeCents)
+	basket.ExecutedLegs++
+}
+
+func failBasketLeg(basket *model.BasketOrderModel, index int, err error) {
+	leg := &basket.Legs[index]
+	leg.OrderStatus = util.OrderStatusFailed
+	leg.ErrorMessage = err.Error()
+	var rejection *RiskRejection
+	if errors.As(err, &rejection) {
+		leg.ErrorCode = rejection.Code
+	}
+	basket.FailedLegs++
+}
+
+// rejectBasket fails the legs not failed already.
+func rejectBasket(basket *model.BasketOrderModel, message string) {
+	for i := range basket.Legs {
+		if basket.Legs[i].OrderStatus == "" {
+			failBasketLeg(basket, i, errors.New(message))
+		}
+	}
+}
@@ -0,0 +1,486 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+
+	"gorm.io/gorm"
+)
+
+func IsAdmin(userId int64) bool {
+	return db.GetUserById(userId).IsAdmin
+}
+
+func GetCorporateActions(stockId int64) []model.CorporateActionModel {
+
+	stocksById := getStocksById()
+	corporateActions := db.GetCorporateActions(stockId)
+	corporateActionModels := make([]model.CorporateActionModel, len(corporateActions))
+
+	for i, corporateAction := range corporateActions {
+		corporateActionModels[i] = getCorporateActionModel(corporateAction, stocksById[corporateAction.StockID])
+	}
+
+	return corporateActionModels
+}
+
+func getCorporateActionModel(corporateAction orm.CorporateActions, stock orm.Stocks) model.CorporateActionModel {
+
+	corporateActionModel := model.CorporateActionModel{
+		CorporateActionID:       corporateAction.CorporateActionID,
+		StockTicker:             stock.Ticker,
+		ActionType:              corporateAction.ActionType,
+		SplitFrom:               corporateAction.SplitFrom,
+		SplitTo:                 corporateAction.SplitTo,
+		DividendPerShareDollars: util.ConvertCentsToDollars(corporateAction.DividendPerShareCents),
+		ExDate:                  util.GetDateTimeString(corporateAction.ExDate),
+		Status:                  corporateAction.Status,
+		CreatedAt:               util.GetDateTimeString(corporateAction.CreatedAt),
+	}
+	if corporateAction.PayDate != nil {
+		corporateActionModel.PayDate = util.GetDateTimeString(*corporateAction.PayDate)
+	}
+	if corporateAction.ProcessedAt != nil {
+		corporateActionModel.ProcessedAt = util.GetDateTimeString(*corporateAction.ProcessedAt)
+	}
+
+	return corporateActionModel
+}
+
+// ScheduleCorporateAction saves a split, reverse split or cash dividend, processed by the price routine once its
+// ex date (and pay date for dividends) is reached.
+func ScheduleCorporateAction(request model.CorporateActionRequest) (model.CorporateActionModel, error) {
+
+	stock := db.GetStockByTicker(request.Ticker)
+	if stock.StockID == 0 {
+		return model.CorporateActionModel{}, errors.New("stock " + request.Ticker + " not found")
+	}
+
+	exDate, err := time.Parse(time.RFC3339, request.ExDate)
+	if err != nil {
+		return model.CorporateActionModel{}, errors.New("exDate must be an RFC 3339 date time")
+	}
+
+	corporateAction := orm.CorporateActions{
+		StockID:    stock.StockID,
+		ActionType: request.ActionType,
+		SplitFrom:  1,
+		SplitTo:    1,
+		ExDate:     exDate,
+		Status:     util.CorporateActionStatusScheduled,
+		CreatedBy:  &request.CreatedBy,
+		CreatedAt:  time.Now(),
+	}
+
+	switch request.ActionType {
+	case util.CorporateActionSplit, util.CorporateActionReverseSplit:
+		if request.SplitFrom <= 0 || request.SplitTo <= 0 {
+			return model.CorporateActionModel{}, errors.New("splitFrom and splitTo must be greater than 0")
+		}
+		if request.ActionType == util.CorporateActionSplit && request.SplitTo <= request.SplitFrom {
+			return model.CorporateActionModel{}, errors.New("a split needs splitTo greater than splitFrom")
+		}
+		if request.ActionType == util.CorporateActionReverseSplit && request.SplitTo >= request.SplitFrom {
+			return model.CorporateActionModel{}, errors.New("a reverse split needs splitTo less than splitFrom")
+		}
+		corporateAction.SplitFrom = request.SplitFrom
+		corporateAction.SplitTo = request.SplitTo
+
+	case util.CorporateActionCashDividend:
+		if request.DividendPerShareCents <= 0 {
+			return model.CorporateActionModel{}, errors.New("dividend per share must be greater than 0")
+		}
+		payDate, err := time.Parse(time.RFC3339, request.PayDate)
+		if err != nil {
+			return model.CorporateActionModel{}, errors.New("payDate must be an RFC 3339 date time")
+		}
+		if payDate.Before(exDate) {
+			return model.CorporateActionModel{}, errors.New("payDate cannot be before exDate")
+		}
+		corporateAction.DividendPerShareCents = request.DividendPerShareCents
+		corporateAction.PayDate = &payDate
+
+	default:
+		return model.CorporateActionModel{}, errors.New("unknown corporate action type " + request.ActionType)
+	}
+
+	if err := db.DB.Create(&corporateAction).Error; err != nil {
+		fmt.Println("Failed to save corporate action:", err)
+		return model.CorporateActionModel{}, errors.New("failed to save corporate action")
+	}
+
+	return getCorporateActionModel(corporateAction, stock), nil
+}
+
+func CancelCorporateAction(corporateActionId int64) error {
+
+	result := db.DB.Model(&orm.CorporateActions{}).
+		Where("corporate_action_id = ? and status = ?", corporateActionId, util.CorporateActionStatusScheduled).
+		Update("status", util.CorporateActionStatusCanceled)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("only scheduled corporate actions can be canceled")
+	}
+
+	return nil
+}
+
+// ProcessCorporateActions applies the splits past their ex date, records the holders of the dividends past their
+// ex date and pays the dividends past their pay date. It returns the notifications for the holders and the ids of
+// the stocks whose prices were split, so the price generators can follow.
+func ProcessCorporateActions(now time.Time) ([]model.NotificationModel, []int64) {
+
+	notifications := make([]model.NotificationModel, 0)
+	splitStockIds := make([]int64, 0)
+
+	for _, corporateAction := range db.GetDueCorporateActions(now) {
+
+		var actionNotifications []model.NotificationModel
+
+		err := bookTransaction(func(tx *gorm.DB) error {
+
+			//moving the status first skips actions already processed by a concurrent run
+			nextStatus := util.CorporateActionStatusCompleted
+			if corporateAction.ActionType == util.CorporateActionCashDividend && corporateAction.Status == util.CorporateActionStatusScheduled {
+				nextStatus = util.CorporateActionStatusRecorded
+			}
+			result := tx.Model(&orm.CorporateActions{}).
+				Where("corporate_action_id = ? and status = ?", corporateAction.CorporateActionID, corporateAction.Status).
+				Updates(map[string]interface{}{
+					"status":       nextStatus,
+					"processed_at": now,
+				})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			stock := db.GetStockById(corporateAction.StockID)
+
+			var err error
+			switch {
+			case corporateAction.ActionType != util.CorporateActionCashDividend:
+				actionNotifications, err = applySplit(tx, corporateAction, stock, now)
+			case nextStatus == util.CorporateActionStatusRecorded:
+				err = recordDividendHolders(tx, corporateAction)
+			default:
+				actionNotifications, err = payDividends(tx, corporateAction, stock, now)
+			}
+			return err
+		})
+
+		if err != nil {
+			fmt.Printf("Failed to process corporate action %d, %s\n", corporateAction.CorporateActionID, err.Error())
+			continue
+		}
+
+		notifications = append(notifications, actionNotifications...)
+		if corporateAction.ActionType != util.CorporateActionCashDividend {
+			splitStockIds = append(splitStockIds, corporateAction.StockID)
+			loadOrderBook(corporateAction.StockID)
+		}
+	}
+
+	return notifications, splitStockIds
+}
+
+// splitQuantity is the quantity after the split, rounded toward zero to the millionth of a share, and the
+// fraction of a millionth left over in 1/SplitFrom units.
+func splitQuantity(corporateAction orm.CorporateActions, quantity int64) (int64, int64) {
+	scaledQuantity := quantity * corporateAction.SplitTo
+	newQuantity := scaledQuantity / corporateAction.SplitFrom
+	return newQuantity, scaledQuantity - newQuantity*corporateAction.SplitFrom
+}
+
+func splitPriceCents(corporateAction orm.CorporateActions, priceCents int64) int64 {
+	return int64(math.Round(float64(priceCents*corporateAction.SplitFrom) / float64(corporateAction.SplitTo)))
+}
+
+// applySplit multiplies the quantities of the stock's holdings, tax lots and open orders by SplitTo / SplitFrom and
+// divides the prices by it, then adjusts the stock's option contracts. Fractions of a millionth of a share left by the split are paid out in cash at the current price.
+func applySplit(tx *gorm.DB, corporateAction orm.CorporateActions, stock orm.Stocks, now time.Time) ([]model.NotificationModel, error) {
+
+	description := fmt.Sprintf("%d-for-%d split of %s", corporateAction.SplitTo, corporateAction.SplitFrom, stock.Ticker)
+	if corporateAction.ActionType == util.CorporateActionReverseSplit {
+		description = fmt.Sprintf("%d-for-%d reverse split of %s", corporateAction.SplitTo, corporateAction.SplitFrom, stock.Ticker)
+	}
+
+	err := tx.Model(&orm.Stocks{}).Where("stock_id = ?", stock.StockID).
+		Updates(map[string]interface{}{
+			"opening_price_cents":       splitPriceCents(corporateAction, stock.OpeningPriceCents),
+			"current_price_cents":       splitPriceCents(corporateAction, stock.CurrentPriceCents),
+			"bid_price_cents":           splitPriceCents(corporateAction, stock.BidPriceCents),
+			"ask_price_cents":           splitPriceCents(corporateAction, stock.AskPriceCents),
+			"min_price_generator_cents": max(splitPriceCents(corporateAction, stock.MinPriceGeneratorCents), 1),
+			"max_price_generator_cents": max(splitPriceCents(corporateAction, stock.MaxPriceGeneratorCents), 1),
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	taxLotsByUserId := make(map[int64][]orm.TaxLots)
+	for _, taxLot := range db.GetOpenTaxLotsByStockIdTx(tx, stock.StockID) {
+		taxLot.Quantity, _ = splitQuantity(corporateAction, taxLot.Quantity)
+		taxLot.RemainingQuantity, _ = splitQuantity(corporateAction, taxLot.RemainingQuantity)
+		taxLot.CostPerShareCents = splitPriceCents(corporateAction, taxLot.CostPerShareCents)
+		taxLotsByUserId[taxLot.UserID] = append(taxLotsByUserId[taxLot.UserID], taxLot)
+	}
+
+	notifications := make([]model.NotificationModel, 0)
+
+	for _, holding := range db.GetActiveHoldingsByStockIdTx(tx, stock.StockID) {
+
+		newQuantity, fraction := splitQuantity(corporateAction, holding.Quantity)
+		cashInLieuCents := int64(math.Round(float64(fraction*stock.CurrentPriceCents) / float64(corporateAction.SplitTo*util.ShareScale)))
+
+		//the lots are rounded down one by one, the newest lot gets the quantity the rounding dropped
+		taxLots := taxLotsByUserId[holding.UserID]
+		var lotQuantity int64
+		for _, taxLot := range taxLots {
+			lotQuantity += taxLot.RemainingQuantity
+		}
+		if len(taxLots) > 0 {
+			newest := &taxLots[len(taxLots)-1]
+			newest.RemainingQuantity += abs(newQuantity) - lotQuantity
+			newest.Quantity = max(newest.Quantity, newest.RemainingQuantity)
+		}
+		for _, taxLot := range taxLots {
+			if taxLot.RemainingQuantity == 0 {
+				taxLot.ClosedAt = &now
+			}
+			if err := tx.Save(&taxLot).Error; err != nil {
+				fmt.Println("Failed to save tax lot:", err)
+				return nil, errors.New("failed to save tax lot")
+			}
+		}
+
+		averageCostCents := splitPriceCents(corporateAction, holding.AverageCostPerShareCents)
+		if len(taxLots) > 0 {
+			averageCostCents = getAverageLotCostCents(taxLots)
+		}
+
+		err := tx.Model(&orm.Holdings{}).Where("holding_id = ?", holding.HoldingID).
+			Updates(map[string]interface{}{
+				"quantity":                     newQuantity,
+				"average_cost_per_share_cents": averageCostCents,
+			}).Error
+		if err != nil {
+			return nil, err
+		}
+
+		entries := []orm.JournalEntries{
+			newSecuritiesEntry(util.LedgerAccountUserSecurities, stock.StockID, newQuantity-holding.Quantity),
+			newSecuritiesEntry(util.LedgerAccountCorporateActions, stock.StockID, holding.Quantity-newQuantity),
+		}
+
+		message := fmt.Sprintf("%s, your position is now %g shares", description, util.ConvertQuantityToShares(newQuantity))
+
+		if cashInLieuCents != 0 {
+			if err := adjustCash(tx, holding.UserID, getStockCurrency(stock), cashInLieuCents, now); err != nil {
+				return nil, err
+			}
+			entries = append(entries,
+				newCashEntry(util.LedgerAccountUserCash, getStockCurrency(stock), cashInLieuCents),
+				newCashEntry(util.LedgerAccountCorporateActions, getStockCurrency(stock), -cashInLieuCents))
+			message += fmt.Sprintf(", $%.2f cash in lieu of the fractional share", util.ConvertCentsToDollars(cashInLieuCents))
+		}
+
+		journal := orm.Journals{
+			UserID:      holding.UserID,
+			EventType:   util.JournalEventCorporateAction,
+			Description: description,
+			CreatedAt:   now,
+		}
+		if err := postJournal(tx, journal, entries); err != nil {
+			return nil, err
+		}
+
+		notification := model.NotificationModel{
+			UserID:    holding.UserID,
+			EventType: util.NotificationEventStockSplit,
+			Message:   message,
+		}
+		if err := createNotification(tx, &notification, now); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+
+	for _, order := range db.GetOrdersByStockIdAndStatusesTx(tx, stock.StockID, openOrderStatuses) {
+		if err := splitOrder(tx, corporateAction, order, description, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := splitOptionContracts(tx, corporateAction, stock); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// splitOrder adjusts an open order to the split and records the change as an amendment, an order left with
+// nothing to fill is canceled.
+func splitOrder(tx *gorm.DB, corporateAction orm.CorporateActions, order orm.Orders, description string, now time.Time) error {
+
+	newQuantity, _ := splitQuantity(corporateAction, order.Quantity)
+	newFilledQuantity, _ := splitQuantity(corporateAction, order.FilledQuantity)
+
+	if newQuantity-newFilledQuantity <= 0 {
+		err := tx.Model(&orm.Orders{}).Where("order_id = ?", order.OrderID).
+			Updates(map[string]interface{}{
+				"order_status": util.OrderStatusCanceled,
+				"notes":        "canceled by the " + description + ", nothing left to fill",
+			}).Error
+		if err != nil {
+			return err
+		}
+		return cancelLinkedOrders(tx, order, fmt.Sprintf("canceled because order %d was canceled", order.OrderID))
+	}
+
+	pricePerShareCents := splitPriceCents(corporateAction, order.PricePerShareCents)
+	newLimitPriceCents := splitPriceCents(corporateAction, order.LimitPriceCents)
+
+	err := tx.Model(&orm.Orders{}).Where("order_id = ?", order.OrderID).
+		Updates(map[string]interface{}{
+			"quantity":                 newQuantity,
+			"filled_quantity":          newFilledQuantity,
+			"limit_price_cents":        newLimitPriceCents,
+			"stop_price_cents":         splitPriceCents(corporateAction, order.StopPriceCents),
+			"trail_amount_cents":       splitPriceCents(corporateAction, order.TrailAmountCents),
+			"trail_anchor_cents":       splitPriceCents(corporateAction, order.TrailAnchorCents),
+			"average_fill_price_cents": splitPriceCents(corporateAction, order.AverageFillPriceCents),
+			"price_per_share_cents":    pricePerShareCents,
+			"total_order_value_cents":  util.GetValueCents(newQuantity-newFilledQuantity, pricePerShareCents),
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	amendment := orm.OrderAmendments{
+		OrderID:            order.OrderID,
+		UserID:             order.UserID,
+		OldQuantity:        order.Quantity,
+		NewQuantity:        newQuantity,
+		OldLimitPriceCents: order.LimitPriceCents,
+		NewLimitPriceCents: newLimitPriceCents,
+		CreatedAt:          now,
+	}
+
+	return tx.Create(&amendment).Error
+}
+
+// recordDividendHolders saves the dividend owed to every holder of the stock on the ex date.
+func recordDividendHolders(tx *gorm.DB, corporateAction orm.CorporateActions) error {
+
+	for _, holding := range db.GetActiveHoldingsByStockIdTx(tx, corporateAction.StockID) {
+		dividendPayment := orm.DividendPayments{
+			CorporateActionID: corporateAction.CorporateActionID,
+			UserID:            holding.UserID,
+			Quantity:          holding.Quantity,
+			AmountCents:       util.GetValueCents(holding.Quantity, corporateAction.DividendPerShareCents),
+			CreatedAt:         corporateAction.ExDate,
+		}
+		if err := tx.Create(&dividendPayment).Error; err != nil {
+			fmt.Println("Failed to save dividend payment:", err)
+			return errors.New("failed to save dividend payment")
+		}
+	}
+
+	return nil
+}
+
+// payDividends credits the recorded long holders and debits the short holders, reinvesting the dividend
+// in the stock for users with DRIP on.
+func payDividends(tx *gorm.DB, corporateAction orm.CorporateActions, stock orm.Stocks, now time.Time) ([]model.NotificationModel, error) {
+
+	notifications := make([]model.NotificationModel, 0)
+	dividendPerShareDollars := util.ConvertCentsToDollars(corporateAction.DividendPerShareCents)
+
+	for _, dividendPayment := range db.GetUnpaidDividendPaymentsTx(tx, corporateAction.CorporateActionID) {
+
+		//paid in the stock's currency
+		if err := adjustCash(tx, dividendPayment.UserID, getStockCurrency(stock), dividendPayment.AmountCents, now); err != nil {
+			return nil, err
+		}
+
+		err := tx.Model(&orm.DividendPayments{}).Where("dividend_payment_id = ?", dividendPayment.DividendPaymentID).
+			Update("paid_at", now).Error
+		if err != nil {
+			return nil, err
+		}
+
+		description := fmt.Sprintf("$%.2f dividend per share on %g shares of %s", dividendPerShareDollars, util.ConvertQuantityToShares(dividendPayment.Quantity), stock.Ticker)
+		journal := orm.Journals{
+			UserID:      dividendPayment.UserID,
+			EventType:   util.JournalEventCorporateAction,
+			Description: description,
+			CreatedAt:   now,
+		}
+		err = postJournal(tx, journal, []orm.JournalEntries{
+			newCashEntry(util.LedgerAccountUserCash, getStockCurrency(stock), dividendPayment.AmountCents),
+			newCashEntry(util.LedgerAccountCorporateActions, getStockCurrency(stock), -dividendPayment.AmountCents),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		message := fmt.Sprintf("Received $%.2f, the %s", util.ConvertCentsToDollars(dividendPayment.AmountCents), description)
+		if dividendPayment.AmountCents < 0 {
+			message = fmt.Sprintf("Charged $%.2f for the short position, the %s", util.ConvertCentsToDollars(-dividendPayment.AmountCents), description)
+		}
+
+		user := db.GetUserByIdTx(tx, dividendPayment.UserID)
+		if user.DripEnabled && dividendPayment.AmountCents > 0 {
+			reinvestedQuantity, err := reinvestDividend(tx, &user, stock, dividendPayment.AmountCents, corporateAction)
+			if err != nil {
+				return nil, err
+			}
+			if reinvestedQuantity > 0 {
+				message += fmt.Sprintf(", reinvested in %g shares", util.ConvertQuantityToShares(reinvestedQuantity))
+			}
+		}
+
+		notification := model.NotificationModel{
+			UserID:    dividendPayment.UserID,
+			EventType: util.NotificationEventDividend,
+			Message:   message,
+		}
+		if err := createNotification(tx, &notification, now); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+// reinvestDividend buys the fractional quantity the dividend pays for at the ask, rounded down to the millionth
+// of a share, the rest stays in cash.
+func reinvestDividend(tx *gorm.DB, user *orm.Users, stock orm.Stocks, amountCents int64, corporateAction orm.CorporateActions) (int64, error) {
+
+	//priced with the slippage of the quantity the amount buys at the ask, the smaller quantity
+	//it buys at that price stays within the amount
+	quantity := util.GetQuantityForAmount(amountCents, getQuotePriceCents(stock, util.TradeTypeBuy))
+	pricePerShareCents := getFillPriceCents(stock, util.TradeTypeBuy, quantity, 0)
+	quantity = util.GetQuantityForAmount(amountCents, pricePerShareCents)
+	if quantity == 0 {
+		return 0, nil
+	}
+
+	orderTemplate := newMarketOrder()
+	orderTemplate.Notes = fmt.Sprintf("dividend reinvestment of corporate action %d", corporateAction.CorporateActionID)
+
+	if _, err := executeOrder(tx, user, stock, util.TradeTypeBuy, quantity, pricePerShareCents, orderTemplate); err != nil {
+		return 0, err
+	}
+
+	return quantity, nil
+}
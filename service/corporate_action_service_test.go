@@ -0,0 +1,33 @@
+package service
+
+import (
+	"testing"
+	"trading_platform_backend/orm"
+)
+
+func TestSplitQuantity(t *testing.T) {
+	tests := []struct {
+		name          string
+		splitTo       int64
+		splitFrom     int64
+		quantity      int64
+		wantQuantity  int64
+		wantRemainder int64
+	}{
+		{"2-for-1", 2, 1, 1500000, 3000000, 0},
+		{"3-for-2 leaves a fraction", 3, 2, 1000001, 1500001, 1},
+		{"1-for-10 reverse split", 1, 10, 25000005, 2500000, 5},
+		{"short position rounds toward zero", 3, 2, -1000001, -1500001, -1},
+		{"nothing to split", 3, 2, 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			corporateAction := orm.CorporateActions{SplitTo: test.splitTo, SplitFrom: test.splitFrom}
+			gotQuantity, gotRemainder := splitQuantity(corporateAction, test.quantity)
+			if gotQuantity != test.wantQuantity || gotRemainder != test.wantRemainder {
+				t.Errorf("splitQuantity(%d) = %d, %d, want %d, %d", test.quantity, gotQuantity, gotRemainder, test.wantQuantity, test.wantRemainder)
+			}
+		})
+	}
+}
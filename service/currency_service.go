@@ -0,0 +1,258 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func getFxRates() map[string]orm.FxRates {
+	fxRates := make(map[string]orm.FxRates)
+	for _, fxRate := range db.GetFxRates() {
+		fxRates[fxRate.Currency] = fxRate
+	}
+	return fxRates
+}
+
+// getUsdRateMicros is the mid rate of the currency, USD and currencies without a rate are worth one dollar.
+func getUsdRateMicros(fxRates map[string]orm.FxRates, currency string) int64 {
+	if fxRate, ok := fxRates[currency]; ok && fxRate.UsdRateMicros > 0 {
+		return fxRate.UsdRateMicros
+	}
+	return util.FxRateScale
+}
+
+func getStockCurrency(stock orm.Stocks) string {
+	if stock.Currency == "" {
+		return util.CurrencyUsd
+	}
+	return stock.Currency
+}
+
+// convertCents converts amountCents between currencies at the mid rates, for valuations only.
+func convertCents(fxRates map[string]orm.FxRates, amountCents int64, fromCurrency string, toCurrency string) int64 {
+	return util.ConvertCurrencyCents(amountCents, getUsdRateMicros(fxRates, fromCurrency), getUsdRateMicros(fxRates, toCurrency))
+}
+
+func toUsdCents(fxRates map[string]orm.FxRates, currency string, amountCents int64) int64 {
+	return convertCents(fxRates, amountCents, currency, util.CurrencyUsd)
+}
+
+// getUsdValueCents is the USD value of quantity (millionths of a share) of the stock at a price in its listing currency.
+func getUsdValueCents(fxRates map[string]orm.FxRates, stock orm.Stocks, quantity int64, pricePerShareCents int64) int64 {
+	return toUsdCents(fxRates, getStockCurrency(stock), util.GetValueCents(quantity, pricePerShareCents))
+}
+
+// getFxBidAskMicros is the rate a currency is sold (bid) and bought (ask) at against USD.
+func getFxBidAskMicros(fxRate orm.FxRates) (int64, int64) {
+	if fxRate.Currency == util.CurrencyUsd || fxRate.SpreadBps <= 0 {
+		return fxRate.UsdRateMicros, fxRate.UsdRateMicros
+	}
+	return util.GetBidAskCents(fxRate.UsdRateMicros, fxRate.SpreadBps)
+}
+
+// getForeignCashUsdCents is the USD value of the user's cash in currencies other than USD, read through tx.
+func getForeignCashUsdCents(tx *gorm.DB, userId int64, fxRates map[string]orm.FxRates) int64 {
+	var totalCents int64
+	for _, cashBalance := range db.GetCashBalancesByUserIdTx(tx, userId) {
+		totalCents += toUsdCents(fxRates, cashBalance.Currency, cashBalance.BalanceCents)
+	}
+	return totalCents
+}
+
+// adjustCash adds amountCents of currency to the user's cash in tx, a negative amount takes it out. A foreign
+// currency balance short of the amount is topped up from the USD balance first, so trades in foreign-listed
+// stocks convert automatically.
+func adjustCash(tx *gorm.DB, userId int64, currency string, amountCents int64, now time.Time) error {
+
+	if currency != util.CurrencyUsd && amountCents < 0 {
+		cashBalance := db.GetCashBalanceByUserIdAndCurrencyTx(tx, userId, currency)
+		if shortfallCents := -amountCents - max(cashBalance.BalanceCents, 0); shortfallCents > 0 {
+			if err := fundCurrency(tx, userId, currency, shortfallCents, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	return updateCashBalance(tx, userId, currency, amountCents, now)
+}
+
+// updateCashBalance adds amountCents to the user's balance in the currency, without any conversion.
+func updateCashBalance(tx *gorm.DB, userId int64, currency string, amountCents int64, now time.Time) error {
+
+	if currency == util.CurrencyUsd {
+		return tx.Model(&orm.Users{}).Where("user_id = ?", userId).
+			Update("cash_balance_cents", gorm.Expr("cash_balance_cents + ?", amountCents)).Error
+	}
+
+	cashBalance := orm.CashBalances{
+		UserID:       userId,
+		Currency:     currency,
+		BalanceCents: amountCents,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "currency"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"balance_cents": gorm.Expr("cash_balances.balance_cents + ?", amountCents),
+			"updated_at":    now,
+		}),
+	}).Create(&cashBalance).Error
+}
+
+// fundCurrency buys amountCents of a foreign currency with USD at the ask, the USD side rounded up to the cent.
+func fundCurrency(tx *gorm.DB, userId int64, currency string, amountCents int64, now time.Time) error {
+
+	fxRate, ok := getFxRates()[currency]
+	if !ok {
+		return errors.New("no FX rate for " + currency)
+	}
+
+	_, askMicros := getFxBidAskMicros(fxRate)
+	usdCents := int64(math.Ceil(float64(amountCents) * float64(askMicros) / util.FxRateScale))
+
+	return postFxConversion(tx, userId, util.CurrencyUsd, usdCents, currency, amountCents, now)
+}
+
+// postFxConversion takes fromCents of one currency out of the user's cash and puts toCents of another in, both legs
+// posted against the FX account.
+func postFxConversion(tx *gorm.DB, userId int64, fromCurrency string, fromCents int64, toCurrency string, toCents int64, now time.Time) error {
+
+	if err := updateCashBalance(tx, userId, fromCurrency, -fromCents, now); err != nil {
+		return err
+	}
+	if err := updateCashBalance(tx, userId, toCurrency, toCents, now); err != nil {
+		return err
+	}
+
+	journal := orm.Journals{
+		UserID:    userId,
+		EventType: util.JournalEventFxConversion,
+		Description: fmt.Sprintf("Converted %.2f %s to %.2f %s", util.ConvertCentsToDollars(fromCents), fromCurrency,
+			util.ConvertCentsToDollars(toCents), toCurrency),
+		CreatedAt: now,
+	}
+
+	return postJournal(tx, journal, []orm.JournalEntries{
+		newCashEntry(util.LedgerAccountUserCash, fromCurrency, -fromCents),
+		newCashEntry(util.LedgerAccountFx, fromCurrency, fromCents),
+		newCashEntry(util.LedgerAccountUserCash, toCurrency, toCents),
+		newCashEntry(util.LedgerAccountFx, toCurrency, -toCents),
+	})
+}
+
+// ConvertCurrency converts amountCents of the user's cash to another currency, selling the source currency at its bid
+// and buying the target currency at its ask. Only cash the user has can be converted.
+func ConvertCurrency(userId int64, fromCurrency string, toCurrency string, amountCents int64) (model.CurrencyConversionModel, error) {
+
+	if amountCents <= 0 {
+		return model.CurrencyConversionModel{}, errors.New("amount must be greater than 0")
+	}
+	if fromCurrency == toCurrency {
+		return model.CurrencyConversionModel{}, errors.New("the currencies must be different")
+	}
+
+	fxRates := getFxRates()
+	fromRate, fromOk := fxRates[fromCurrency]
+	toRate, toOk := fxRates[toCurrency]
+	if !fromOk || !toOk {
+		return model.CurrencyConversionModel{}, errors.New("unknown currency")
+	}
+
+	bidMicros, _ := getFxBidAskMicros(fromRate)
+	_, askMicros := getFxBidAskMicros(toRate)
+	toCents := int64(math.Floor(float64(amountCents) * float64(bidMicros) / float64(askMicros)))
+	if toCents <= 0 {
+		return model.CurrencyConversionModel{}, errors.New("amount is too small to convert")
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+
+		user := db.GetUserByIdTx(tx, userId)
+		if user.UserID == 0 {
+			return errors.New("user does not exist")
+		}
+
+		availableCents := user.CashBalanceCents
+		if fromCurrency != util.CurrencyUsd {
+			availableCents = db.GetCashBalanceByUserIdAndCurrencyTx(tx, userId, fromCurrency).BalanceCents
+		}
+		if amountCents > availableCents {
+			return fmt.Errorf("insufficient %s balance, %.2f available", fromCurrency, util.ConvertCentsToDollars(max(availableCents, 0)))
+		}
+
+		return postFxConversion(tx, userId, fromCurrency, amountCents, toCurrency, toCents, time.Now())
+	})
+	if err != nil {
+		return model.CurrencyConversionModel{}, err
+	}
+
+	return model.CurrencyConversionModel{
+		FromCurrency: fromCurrency,
+		FromAmount:   util.ConvertCentsToDollars(amountCents),
+		ToCurrency:   toCurrency,
+		ToAmount:     util.ConvertCentsToDollars(toCents),
+		Rate:         float64(bidMicros) / float64(askMicros),
+	}, nil
+}
+
+func GetFxRates() []model.FxRateModel {
+
+	fxRates := db.GetFxRates()
+	fxRateModels := make([]model.FxRateModel, len(fxRates))
+
+	for i, fxRate := range fxRates {
+		bidMicros, askMicros := getFxBidAskMicros(fxRate)
+		fxRateModels[i] = model.FxRateModel{
+			Currency:  fxRate.Currency,
+			Name:      fxRate.Name,
+			UsdRate:   float64(fxRate.UsdRateMicros) / util.FxRateScale,
+			UsdBid:    float64(bidMicros) / util.FxRateScale,
+			UsdAsk:    float64(askMicros) / util.FxRateScale,
+			UpdatedAt: util.GetDateTimeString(fxRate.UpdatedAt),
+		}
+	}
+
+	return fxRateModels
+}
+
+// getCashBalanceModels lists the user's cash per currency, USD first, with its value in the base currency.
+func getCashBalanceModels(user orm.Users, fxRates map[string]orm.FxRates, baseCurrency string) ([]model.CashBalanceModel, int64) {
+
+	cashBalances := append([]orm.CashBalances{{Currency: util.CurrencyUsd, BalanceCents: user.CashBalanceCents}},
+		db.GetCashBalancesByUserId(user.UserID)...)
+
+	cashBalanceModels := make([]model.CashBalanceModel, 0, len(cashBalances))
+	var totalBaseCents int64
+	for _, cashBalance := range cashBalances {
+		if cashBalance.Currency != util.CurrencyUsd && cashBalance.BalanceCents == 0 {
+			continue
+		}
+		baseCents := convertCents(fxRates, cashBalance.BalanceCents, cashBalance.Currency, baseCurrency)
+		cashBalanceModels = append(cashBalanceModels, model.CashBalanceModel{
+			Currency:  cashBalance.Currency,
+			Balance:   util.ConvertCentsToDollars(cashBalance.BalanceCents),
+			BaseValue: util.ConvertCentsToDollars(baseCents),
+		})
+		totalBaseCents += baseCents
+	}
+
+	return cashBalanceModels, totalBaseCents
+}
+
+func getBaseCurrency(user orm.Users) string {
+	if user.BaseCurrency == "" {
+		return util.CurrencyUsd
+	}
+	return user.BaseCurrency
+}
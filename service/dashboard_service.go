@@ -19,16 +19,26 @@ func GetDashboardData(userId int64) model.DashboardModel {
 		return model.DashboardModel{}
 	}
 
+	fxRates := getFxRates()
+	baseCurrency := getBaseCurrency(user)
+	toBaseCents := func(stock orm.Stocks, amountCents int64) int64 {
+		return convertCents(fxRates, amountCents, getStockCurrency(stock), baseCurrency)
+	}
+
 	stocks := db.GetAllStocks()
 	stockModels := make([]model.StockModel, 0)
 	stockMap := make(map[int32]orm.Stocks)
+	stocksById := make(map[int64]orm.Stocks)
 	for _, stock := range stocks {
 		stockModel := model.StockModel{
 			StockID:             stock.StockID,
 			Ticker:              stock.Ticker,
 			Name:                stock.Name,
+			Currency:            getStockCurrency(stock),
 			OpeningPriceDollars: util.ConvertCentsToDollars(stock.OpeningPriceCents),
 			CurrentPriceDollars: util.ConvertCentsToDollars(stock.CurrentPriceCents),
+			BidDollars:          util.ConvertCentsToDollars(stock.BidPriceCents),
+			AskDollars:          util.ConvertCentsToDollars(stock.AskPriceCents),
 			UpdatedAt:           util.GetDateTimeString(stock.UpdatedAt),
 		}
 		stockModel.ChangedPriceDollars = stockModel.GetChangedPriceDollars()
@@ -36,13 +46,13 @@ func GetDashboardData(userId int64) model.DashboardModel {
 
 		stockModels = append(stockModels, stockModel)
 		stockMap[int32(stock.StockID)] = stock
+		stocksById[stock.StockID] = stock
 	}
 
 	holdings := db.GetActiveHoldingsByUserID(userId)
 	holdingModels := make([]model.HoldingModel, 0)
 
 	var totalHoldingValueCents int64
-	var totalPnlCents int64
 
 	for _, holding := range holdings {
 
@@ -53,12 +63,13 @@ func GetDashboardData(userId int64) model.DashboardModel {
 			pnlCents = holding.AverageCostPerShareCents - stockMap[int32(holding.StockID)].CurrentPriceCents
 		}
 
-		holdingValueCents := holding.Quantity * holding.AverageCostPerShareCents
+		holdingValueCents := util.GetValueCents(holding.Quantity, holding.AverageCostPerShareCents)
 
 		holdingModels = append(holdingModels, model.HoldingModel{
 			HoldingID:                  holding.HoldingID,
 			StockTicker:                stockMap[int32(holding.StockID)].Ticker,
-			Quantity:                   holding.Quantity,
+			Currency:                   getStockCurrency(stockMap[int32(holding.StockID)]),
+			Quantity:                   util.ConvertQuantityToShares(holding.Quantity),
 			AverageCostPerShareDollars: util.ConvertCentsToDollars(holding.AverageCostPerShareCents),
 			TotalValueDollars:          util.ConvertCentsToDollars(holdingValueCents),
 			UpdatedAt:                  util.GetDateTimeString(holding.UpdatedAt),
@@ -66,18 +77,22 @@ func GetDashboardData(userId int64) model.DashboardModel {
 			PnLPercent:                 (float64(pnlCents) / math.Abs(float64(holdingValueCents))) * 100,
 		})
 
-		totalPnlCents += pnlCents
-		totalHoldingValueCents += holdingValueCents
+		totalHoldingValueCents += toBaseCents(stockMap[int32(holding.StockID)], holdingValueCents)
 	}
 
+	feeSchedule := getFeeSchedule(user)
+
 	userModel := model.UserModel{
 		UserID:             user.UserID,
 		Username:           user.Username,
 		Email:              user.Email,
 		CashBalanceDollars: util.ConvertCentsToDollars(user.CashBalanceCents),
+		BaseCurrency:       baseCurrency,
 		CreatedAt:          util.GetDateTimeString(user.CreatedAt),
 		UpdatedAt:          util.GetDateTimeString(user.UpdatedAt),
 		NotificationsOn:    user.NotificationsOn,
+		FeeSchedule:        feeSchedule.Name,
+		CostBasisMethod:    getCostBasisMethod(user),
 	}
 
 	watchlist := db.GetStockWatchlistByUserId(int32(userId))
@@ -99,22 +114,79 @@ func GetDashboardData(userId int64) model.DashboardModel {
 			DiffPercent:        diffPercent,
 			IsActive:           watch.IsActive,
 			CreatedAt:          util.GetDateTimeString(watch.CreatedAt),
+			AlertPolicy:        watch.AlertPolicy,
+			ActionType:         watch.ActionType,
+			ActionQuantity:     util.ConvertQuantityToShares(watch.ActionQuantity),
+			ActionStatus:       watch.ActionStatus,
+			ActionMessage:      watch.ActionMessage,
+		}
+		if watch.LastAlertedAt != nil {
+			stockWatchlist[i].LastAlertedAt = util.GetDateTimeString(*watch.LastAlertedAt)
+		}
+		if watch.ActionOrderID != nil {
+			stockWatchlist[i].ActionOrderID = *watch.ActionOrderID
+		}
+		if watch.ActionExecutedAt != nil {
+			stockWatchlist[i].ActionExecutedAt = util.GetDateTimeString(*watch.ActionExecutedAt)
 		}
 	}
 
+	optionHoldings, optionGreeks, totalOptionValueCents := getOptionHoldingModels(userId, stocksById, time.Now())
+	totalOptionValueCents = convertCents(fxRates, totalOptionValueCents, util.CurrencyUsd, baseCurrency)
+
+	cashBalances, totalCashValueCents := getCashBalanceModels(user, fxRates, baseCurrency)
+
+	//valued in USD
+	marginAccount := getMarginAccount(db.DB, user, stocksById)
+	fromUsdCents := func(amountCents int64) int64 {
+		return convertCents(fxRates, amountCents, util.CurrencyUsd, baseCurrency)
+	}
+
+	//fees are already out of the cash balance, so the return reflects them too
+	totalFeeCents := fromUsdCents(db.GetTotalFeeCentsByUserId(userId))
+
+	var realizedPnlCents, unrealizedPnlCents int64
+	for stockId, stockRealizedPnlCents := range db.GetRealizedPnlCentsByUserId(userId) {
+		realizedPnlCents += toBaseCents(stocksById[stockId], stockRealizedPnlCents)
+	}
+	for _, taxLot := range db.GetOpenTaxLotsByUserId(userId) {
+		unrealizedPnlCents += toBaseCents(stocksById[taxLot.StockID], getUnrealizedPnlCents(taxLot, stocksById[taxLot.StockID]))
+	}
+
+	portfolioValueCents := totalCashValueCents + totalHoldingValueCents + totalOptionValueCents
+	initialInvestmentCents := fromUsdCents(util.InitialInvestmentCents)
+
 	return model.DashboardModel{
 		User:                     userModel,
 		Stocks:                   stockModels,
 		Holdings:                 holdingModels,
 		StockWatchlist:           stockWatchlist,
+		OptionHoldings:           optionHoldings,
+		OptionGreeks:             optionGreeks,
+		CashBalances:             cashBalances,
+		TotalCashValueDollars:    util.ConvertCentsToDollars(totalCashValueCents),
 		TotalHoldingValueDollars: util.ConvertCentsToDollars(totalHoldingValueCents),
-		PortfolioValueDollars:    util.ConvertCentsToDollars(user.CashBalanceCents + totalHoldingValueCents),
-		TotalPnLDollars:          util.ConvertCentsToDollars(totalPnlCents),
-		TotalReturnPercent:       (float64(user.CashBalanceCents+totalHoldingValueCents-util.InitialInvestmentCents) / util.InitialInvestmentCents) * 100,
+		TotalOptionValueDollars:  util.ConvertCentsToDollars(totalOptionValueCents),
+		BuyingPowerDollars:       util.ConvertCentsToDollars(fromUsdCents(marginAccount.getBuyingPowerCents(util.DefaultInitialMarginPercent))),
+		EquityDollars:            util.ConvertCentsToDollars(fromUsdCents(marginAccount.EquityCents)),
+		MaintenanceMarginDollars: util.ConvertCentsToDollars(fromUsdCents(marginAccount.MaintenanceRequirementCents)),
+		MarginCall:               user.MarginCallAt != nil,
+		PortfolioValueDollars:    util.ConvertCentsToDollars(portfolioValueCents),
+		TotalPnLDollars:          util.ConvertCentsToDollars(realizedPnlCents + unrealizedPnlCents - totalFeeCents),
+		RealizedPnLDollars:       util.ConvertCentsToDollars(realizedPnlCents),
+		UnrealizedPnLDollars:     util.ConvertCentsToDollars(unrealizedPnlCents),
+		TotalFeesDollars:         util.ConvertCentsToDollars(totalFeeCents),
+		TotalReturnPercent:       (float64(portfolioValueCents-initialInvestmentCents) / float64(initialInvestmentCents)) * 100,
 	}
 }
 
-func BuyStocks(userId int64, ticker string, quantity int64) string {
+// BuyStocks executes a market buy of whole shares, taxLotIds are the short lots to cover first for the SPECIFIC_LOT cost basis.
+func BuyStocks(userId int64, ticker string, shares int64, taxLotIds []int64) (orm.Orders, string) {
+	return BuyStocksFractional(userId, ticker, shares*util.ShareScale, taxLotIds)
+}
+
+// BuyStocksFractional executes a market buy of quantity millionths of a share and returns the executed order.
+func BuyStocksFractional(userId int64, ticker string, quantity int64, taxLotIds []int64) (orm.Orders, string) {
 
 	//get stock using ticker
 	//if stock is not present, err
@@ -128,7 +200,9 @@ func BuyStocks(userId int64, ticker string, quantity int64) string {
 	//save the holding
 	//update the user balance
 
-	err := db.DB.Transaction(func(tx *gorm.DB) error {
+	var order orm.Orders
+
+	err := bookTransaction(func(tx *gorm.DB) error {
 
 		stock := db.GetStockByTicker(ticker)
 		if stock.StockID == 0 {
@@ -140,60 +214,75 @@ func BuyStocks(userId int64, ticker string, quantity int64) string {
 			return errors.New("user does not exist")
 		}
 
-		totalOrderValueCents := quantity * stock.CurrentPriceCents
-		if totalOrderValueCents > user.CashBalanceCents {
-			return errors.New("user don't have enough balance")
-		}
+		orderTemplate := newMarketOrder()
+		orderTemplate.TaxLotIDs = taxLotIds
 
-		holding := db.GetHoldingByUserIdAndStockId(userId, stock.StockID)
-
-		buyQuantity := quantity
-		if holding.HoldingID > 0 && holding.Quantity < 0 {
-			buyQuantity = int64(math.Min(math.Abs(float64(holding.Quantity)), float64(quantity))) //to make holding from -ve to 0
-		}
+		var err error
+		order, err = buyStocks(tx, &user, stock, quantity, orderTemplate)
+		return err
+	})
 
-		result := buyOrder(tx, &user, stock, buyQuantity, &holding)
-		if result != "" {
-			return errors.New("Failed to buy stock, " + result)
-		}
+	if err != nil {
+		return orm.Orders{}, "Failed to buy stock, " + err.Error()
+	}
 
-		//extra quantity for long trade
-		if quantity > buyQuantity {
-			longQuantity := quantity - buyQuantity
-			result = buyOrder(tx, &user, stock, longQuantity, &holding)
-			if result != "" {
-				return errors.New("Failed to buy stock, " + result)
-			}
-		}
+	return order, ""
+}
 
-		return nil
-	})
+// buyStocks executes a buy at the ask plus slippage, resting sell orders of other users at that price or better fill first.
+// orderTemplate carries the order type, time in force and notes to record.
+func buyStocks(tx *gorm.DB, user *orm.Users, stock orm.Stocks, quantity int64, orderTemplate orm.Orders) (orm.Orders, error) {
 
-	if err != nil {
-		return "Failed to buy stock, " + err.Error()
+	pricePerShareCents := getFillPriceCents(stock, util.TradeTypeBuy, quantity, orderTemplate.LimitPriceCents)
+	if err := checkBuyingPower(tx, *user, stock, util.TradeTypeBuy, quantity, pricePerShareCents, nil); err != nil {
+		return orm.Orders{}, err
 	}
 
-	return ""
+	return executeOrder(tx, user, stock, util.TradeTypeBuy, quantity, pricePerShareCents, orderTemplate)
 }
 
-func buyOrder(tx *gorm.DB, user *orm.Users, stock orm.Stocks, quantity int64, holding *orm.Holdings) string {
-
-	order := orm.Orders{
-		UserID:               user.UserID,
-		StockID:              stock.StockID,
-		TradeType:            util.TradeTypeBuy,
-		OrderStatus:          util.OrderStatusExecuted,
-		Quantity:             quantity,
-		PricePerShareCents:   stock.CurrentPriceCents,
-		TotalOrderValueCents: quantity * stock.CurrentPriceCents,
-		CreatedAt:            time.Now(),
+// executeOrder saves an order, fills it completely, against the order book at pricePerShareCents or better
+// first and the rest at pricePerShareCents, and returns it filled. user is reloaded with the resulting cash balance.
+func executeOrder(tx *gorm.DB, user *orm.Users, stock orm.Stocks, tradeType string, quantity int64, pricePerShareCents int64, orderTemplate orm.Orders) (orm.Orders, error) {
+
+	order := orderTemplate
+	order.UserID = user.UserID
+	order.StockID = stock.StockID
+	order.TradeType = tradeType
+	order.OrderStatus = util.OrderStatusPending
+	order.Quantity = quantity
+	order.PricePerShareCents = pricePerShareCents
+	order.TotalOrderValueCents = util.GetValueCents(quantity, pricePerShareCents)
+	order.CreatedAt = time.Now()
+
+	if err := savePlacedOrder(tx, &order); err != nil {
+		return orm.Orders{}, err
 	}
 
-	if err := tx.Create(&order).Error; err != nil {
-		fmt.Println("Failed to save order:", err)
-		return "failed to save order"
+	order, err := takeBookLiquidity(tx, order, stock, pricePerShareCents)
+	if err != nil {
+		return orm.Orders{}, err
+	}
+
+	if remainingQuantity := order.Quantity - order.FilledQuantity; remainingQuantity > 0 {
+		var filled bool
+		order, filled, err = fillOrderQuantity(tx, order, stock, remainingQuantity, pricePerShareCents, nil)
+		if err != nil {
+			return orm.Orders{}, err
+		}
+		if !filled {
+			return orm.Orders{}, errors.New("failed to fill order")
+		}
 	}
 
+	*user = db.GetUserByIdTx(tx, user.UserID)
+	return order, nil
+}
+
+// updatePosition applies an already recorded fill of quantity (millionths of a share) worth totalValueCents, in the stock's currency, to the user's holding
+// and cash balance, averageCostCents is the average cost of the holding's open tax lots after the fill.
+func updatePosition(tx *gorm.DB, user *orm.Users, stock orm.Stocks, tradeType string, quantity int64, totalValueCents int64, averageCostCents int64, holding *orm.Holdings) string {
+
 	if holding.HoldingID == 0 {
 		*holding = orm.Holdings{
 			StockID: stock.StockID,
@@ -201,28 +290,38 @@ func buyOrder(tx *gorm.DB, user *orm.Users, stock orm.Stocks, quantity int64, ho
 		}
 	}
 
-	oldTotalCents := holding.AverageCostPerShareCents * int64(math.Abs(float64(holding.Quantity)))
-	holding.Quantity += quantity
-	if holding.Quantity != 0 {
-		holding.AverageCostPerShareCents = int64(math.Abs(float64((oldTotalCents + order.TotalOrderValueCents) / holding.Quantity)))
+	cashCents := totalValueCents
+	if tradeType == util.TradeTypeBuy {
+		holding.Quantity += quantity
+		cashCents = -totalValueCents
+	} else {
+		holding.Quantity -= quantity
 	}
+	holding.AverageCostPerShareCents = averageCostCents
 
 	if err := tx.Save(&holding).Error; err != nil {
 		fmt.Println("Failed to save holding:", err)
 		return "failed to save holding"
 	}
 
-	user.CashBalanceCents -= order.TotalOrderValueCents
-
-	if err := tx.Save(&user).Error; err != nil {
+	//settled in the stock's currency, converted from USD when the balance is short
+	if err := adjustCash(tx, user.UserID, getStockCurrency(stock), cashCents, time.Now()); err != nil {
 		fmt.Println("Failed to save account data:", err)
 		return "failed to save account data"
 	}
 
+	*user = db.GetUserByIdTx(tx, user.UserID)
+
 	return ""
 }
 
-func SellStocks(userId int64, ticker string, quantity int64) string {
+// SellStocks executes a market sell of whole shares, taxLotIds are the long lots to close first for the SPECIFIC_LOT cost basis.
+func SellStocks(userId int64, ticker string, shares int64, taxLotIds []int64) (orm.Orders, string) {
+	return SellStocksFractional(userId, ticker, shares*util.ShareScale, taxLotIds)
+}
+
+// SellStocksFractional executes a market sell of quantity millionths of a share and returns the executed order.
+func SellStocksFractional(userId int64, ticker string, quantity int64, taxLotIds []int64) (orm.Orders, string) {
 
 	//get stock using ticker
 	//if stock is not present, err
@@ -236,7 +335,9 @@ func SellStocks(userId int64, ticker string, quantity int64) string {
 	//save the holding
 	//update the user balance
 
-	err := db.DB.Transaction(func(tx *gorm.DB) error {
+	var order orm.Orders
+
+	err := bookTransaction(func(tx *gorm.DB) error {
 
 		stock := db.GetStockByTicker(ticker)
 		if stock.StockID == 0 {
@@ -248,88 +349,66 @@ func SellStocks(userId int64, ticker string, quantity int64) string {
 			return errors.New("user does not exist")
 		}
 
-		totalOrderValueCents := quantity * stock.CurrentPriceCents
-		if totalOrderValueCents > user.CashBalanceCents {
-			return errors.New("user don't have enough balance")
-		}
-
-		holding := db.GetHoldingByUserIdAndStockId(user.UserID, stock.StockID)
-		sellQuantity := quantity
-		if holding.HoldingID > 0 && holding.Quantity > 0 {
-			sellQuantity = int64(math.Min(math.Abs(float64(holding.Quantity)), float64(quantity))) //to make the holding from +ve to 0
-		}
-
-		result := sellOrder(tx, &user, stock, sellQuantity, &holding)
-		if result != "" {
-			return errors.New("failed to sell order, " + result)
-		}
-
-		//extra quantity short trade
-		if quantity > sellQuantity {
-			shortQuantity := quantity - sellQuantity
-			result = sellOrder(tx, &user, stock, shortQuantity, &holding)
-			if result != "" {
-				return errors.New("failed to sell order, " + result)
-			}
-		}
+		orderTemplate := newMarketOrder()
+		orderTemplate.TaxLotIDs = taxLotIds
 
-		return nil
+		var err error
+		order, err = sellStocks(tx, &user, stock, quantity, orderTemplate)
+		return err
 	})
 
 	if err != nil {
-		return "Failed to sell stock, " + err.Error()
+		return orm.Orders{}, "Failed to sell stock, " + err.Error()
 	}
 
-	return ""
+	return order, ""
 }
 
-func sellOrder(tx *gorm.DB, user *orm.Users, stock orm.Stocks, quantity int64, holding *orm.Holdings) string {
-
-	order := orm.Orders{
-		UserID:               user.UserID,
-		StockID:              stock.StockID,
-		TradeType:            util.TradeTypeSell,
-		OrderStatus:          util.OrderStatusExecuted,
-		Quantity:             quantity,
-		PricePerShareCents:   stock.CurrentPriceCents,
-		TotalOrderValueCents: quantity * stock.CurrentPriceCents,
-		CreatedAt:            time.Now(),
-	}
-
-	if err := tx.Create(&order).Error; err != nil {
-		fmt.Println("Failed to save order:", err)
-		return "failed to save order"
-	}
+// sellStocks executes a sell at the bid minus slippage, resting buy orders of other users at that price or better fill first.
+// orderTemplate carries the order type, time in force and notes to record.
+func sellStocks(tx *gorm.DB, user *orm.Users, stock orm.Stocks, quantity int64, orderTemplate orm.Orders) (orm.Orders, error) {
 
-	if holding.HoldingID == 0 {
-		*holding = orm.Holdings{
-			StockID: stock.StockID,
-			UserID:  user.UserID,
-		}
+	pricePerShareCents := getFillPriceCents(stock, util.TradeTypeSell, quantity, orderTemplate.LimitPriceCents)
+	if err := checkBuyingPower(tx, *user, stock, util.TradeTypeSell, quantity, pricePerShareCents, nil); err != nil {
+		return orm.Orders{}, err
 	}
 
-	oldTotalCents := holding.AverageCostPerShareCents * int64(math.Abs(float64(holding.Quantity)))
-	holding.Quantity -= quantity
-	if holding.Quantity != 0 {
-		holding.AverageCostPerShareCents = int64(math.Abs(float64((oldTotalCents - order.TotalOrderValueCents) / holding.Quantity)))
-	}
+	return executeOrder(tx, user, stock, util.TradeTypeSell, quantity, pricePerShareCents, orderTemplate)
+}
 
-	if err := tx.Save(&holding).Error; err != nil {
-		fmt.Println("Failed to save holding:", err)
-		return "failed to save holding"
+func newMarketOrder() orm.Orders {
+	return orm.Orders{
+		OrderType:   util.OrderTypeMarket,
+		TimeInForce: util.TimeInForceDay,
 	}
+}
 
-	user.CashBalanceCents += order.TotalOrderValueCents
+// AddStockToWatchlist watches a stock for its price crossing targetPrice. actionType optionally trades when it does,
+// actionQuantity is the millionths of a share a BUY action buys.
+func AddStockToWatchlist(userId int32, stockId int32, targetPrice float64, alertPolicy string, actionType string, actionQuantity int64) error {
 
-	if err := tx.Save(&user).Error; err != nil {
-		fmt.Println("Failed to save account data:", err)
-		return "failed to save account data"
+	if alertPolicy == "" {
+		alertPolicy = util.WatchAlertPolicyOnce
+	}
+	if alertPolicy != util.WatchAlertPolicyOnce && alertPolicy != util.WatchAlertPolicyRearm {
+		return errors.New("unknown alert policy " + alertPolicy)
 	}
 
-	return ""
-}
-
-func AddStockToWatchlist(userId int32, stockId int32, targetPrice float64) error {
+	actionStatus := ""
+	switch actionType {
+	case "":
+		actionQuantity = 0
+	case util.WatchActionBuy:
+		if actionQuantity <= 0 {
+			return errors.New("a buy action needs a quantity greater than 0")
+		}
+		actionStatus = util.WatchActionStatusPending
+	case util.WatchActionSellAll:
+		actionQuantity = 0
+		actionStatus = util.WatchActionStatusPending
+	default:
+		return errors.New("unknown action " + actionType)
+	}
 
 	stockWatch := db.GetStockWatchlistByUserIdAndStockId(userId, stockId)
 	if stockWatch.StockWatchlistID > 0 {
@@ -342,6 +421,10 @@ func AddStockToWatchlist(userId int32, stockId int32, targetPrice float64) error
 		TargetPriceCents: int64(targetPrice * 100),
 		IsActive:         true,
 		CreatedAt:        time.Now(),
+		AlertPolicy:      alertPolicy,
+		ActionType:       actionType,
+		ActionQuantity:   actionQuantity,
+		ActionStatus:     actionStatus,
 	}
 
 	if err := db.DB.Create(&stockWatch).Error; err != nil {
@@ -351,14 +434,15 @@ func AddStockToWatchlist(userId int32, stockId int32, targetPrice float64) error
 	return nil
 }
 
+// DeleteFromWatchlist removes the user's watches of the stock, including those deactivated by their alert.
 func DeleteFromWatchlist(userId int32, stockId int32) error {
-	stockWatch := db.GetStockWatchlistByUserIdAndStockId(userId, stockId)
-	if stockWatch.StockWatchlistID == 0 {
-		return errors.New("stock is already deleted")
-	}
 
-	if err := db.DB.Delete(&stockWatch).Error; err != nil {
-		return err
+	result := db.DB.Where("user_id = ? and stock_id = ?", userId, stockId).Delete(&orm.StockWatchlist{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("stock is already deleted")
 	}
 
 	return nil
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+)
+
+func GetFeeSchedules() []model.FeeScheduleModel {
+
+	feeSchedules := db.GetAllFeeSchedules()
+	feeScheduleModels := make([]model.FeeScheduleModel, len(feeSchedules))
+
+	for i, feeSchedule := range feeSchedules {
+		feeScheduleModels[i] = model.FeeScheduleModel{
+			FeeScheduleID:   feeSchedule.FeeScheduleID,
+			Name:            feeSchedule.Name,
+			PerTradeDollars: util.ConvertCentsToDollars(feeSchedule.PerTradeCents),
+			PerShareDollars: feeSchedule.PerShareCents / 100,
+			Percent:         feeSchedule.Percent,
+			MinFeeDollars:   util.ConvertCentsToDollars(feeSchedule.MinFeeCents),
+			SellFeePercent:  feeSchedule.SellFeePercent,
+			IsDefault:       feeSchedule.IsDefault,
+		}
+	}
+
+	return feeScheduleModels
+}
+
+// SetFeeSchedule assigns the fee schedule named feeScheduleName to the user, only admins can.
+func SetFeeSchedule(userId int64, feeScheduleName string) error {
+
+	feeSchedule := db.GetFeeScheduleByName(feeScheduleName)
+	if feeSchedule.FeeScheduleID == 0 {
+		return errors.New("fee schedule not found")
+	}
+
+	result := db.DB.Model(&orm.Users{}).Where("user_id = ?", userId).Update("fee_schedule_id", feeSchedule.FeeScheduleID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// getFeeSchedule returns the user's fee schedule, or the default one if the user has not picked any.
+func getFeeSchedule(user orm.Users) orm.FeeSchedules {
+	if user.FeeScheduleID != nil {
+		feeSchedule := db.GetFeeScheduleById(*user.FeeScheduleID)
+		if feeSchedule.FeeScheduleID > 0 {
+			return feeSchedule
+		}
+	}
+	return db.GetDefaultFeeSchedule()
+}
+
+// getFeeCents is the fee charged on a fill of quantity (millionths of a share) worth totalValueCents. The flat, per share and
+// percent fees are raised to the minimum, the sell fee is charged on top of it.
+func getFeeCents(feeSchedule orm.FeeSchedules, tradeType string, quantity int64, totalValueCents int64) int64 {
+
+	if quantity <= 0 {
+		return 0
+	}
+
+	feeCents := feeSchedule.PerTradeCents +
+		int64(math.Round(feeSchedule.PerShareCents*util.ConvertQuantityToShares(quantity))) +
+		int64(math.Round(float64(totalValueCents)*feeSchedule.Percent/100))
+
+	if feeCents < feeSchedule.MinFeeCents {
+		feeCents = feeSchedule.MinFeeCents
+	}
+
+	if tradeType == util.TradeTypeSell {
+		feeCents += int64(math.Ceil(float64(totalValueCents) * feeSchedule.SellFeePercent / 100))
+	}
+
+	return feeCents
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+)
+
+// BeginIdempotentRequest reserves the user's idempotency key for a request. If the key was already used by
+// the same request it returns the saved response, which the caller sends back instead of executing again.
+func BeginIdempotentRequest(userId int64, idempotencyKey string, requestPath string, requestHash string, now time.Time) (string, bool, error) {
+
+	if len(idempotencyKey) > util.IdempotencyKeyMaxLength {
+		return "", false, fmt.Errorf("%s must be at most %d characters", util.IdempotencyKeyHeader, util.IdempotencyKeyMaxLength)
+	}
+
+	record := db.GetIdempotencyKey(userId, idempotencyKey)
+
+	if record.IdempotencyKeyID > 0 && record.ExpiresAt.After(now) {
+		if record.RequestPath != requestPath || record.RequestHash != requestHash {
+			return "", false, errors.New(util.IdempotencyKeyHeader + " was already used for a different request")
+		}
+		if record.Response == nil {
+			return "", false, errors.New("a request with this " + util.IdempotencyKeyHeader + " is still being processed")
+		}
+		return *record.Response, true, nil
+	}
+
+	if record.IdempotencyKeyID > 0 {
+		//expired, the key can be used again
+		db.DB.Where("idempotency_key_id = ? and expires_at <= ?", record.IdempotencyKeyID, now).Delete(&orm.IdempotencyKeys{})
+	}
+
+	record = orm.IdempotencyKeys{
+		UserID:         userId,
+		IdempotencyKey: idempotencyKey,
+		RequestPath:    requestPath,
+		RequestHash:    requestHash,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(util.IdempotencyKeyTTL),
+	}
+
+	//the unique key rejects a retry racing the first request
+	if err := db.DB.Create(&record).Error; err != nil {
+		fmt.Println("Failed to save idempotency key:", err)
+		return "", false, errors.New("a request with this " + util.IdempotencyKeyHeader + " is still being processed")
+	}
+
+	return "", false, nil
+}
+
+// CompleteIdempotentRequest saves the response sent for the request holding the idempotency key.
+func CompleteIdempotentRequest(userId int64, idempotencyKey string, response string) {
+
+	err := db.DB.Model(&orm.IdempotencyKeys{}).
+		Where("user_id = ? and idempotency_key = ? and response is null", userId, idempotencyKey).
+		Update("response", response).Error
+	if err != nil {
+		fmt.Println("Failed to save idempotent response, " + err.Error())
+	}
+}
+
+// ReleaseIdempotencyKey frees a key whose request did not complete, so it can be retried.
+func ReleaseIdempotencyKey(userId int64, idempotencyKey string) {
+
+	err := db.DB.Where("user_id = ? and idempotency_key = ? and response is null", userId, idempotencyKey).
+		Delete(&orm.IdempotencyKeys{}).Error
+	if err != nil {
+		fmt.Println("Failed to release idempotency key, " + err.Error())
+	}
+}
+
+func DeleteExpiredIdempotencyKeys(now time.Time) int64 {
+
+	result := db.DB.Where("expires_at <= ?", now).Delete(&orm.IdempotencyKeys{})
+	if result.Error != nil {
+		fmt.Println("Failed to delete expired idempotency keys, " + result.Error.Error())
+		return 0
+	}
+
+	return result.RowsAffected
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+
+	"gorm.io/gorm"
+)
+
+// newJournalEntry is a journal entry of amount, a debit when positive and a credit when negative.
+func newJournalEntry(account string, amount int64) orm.JournalEntries {
+	entry := orm.JournalEntries{Account: account}
+	if amount >= 0 {
+		entry.Debit = amount
+	} else {
+		entry.Credit = -amount
+	}
+	return entry
+}
+
+// newCashEntry is a cash journal entry of amountCents in the currency, a debit when positive and a credit when negative.
+func newCashEntry(account string, currency string, amountCents int64) orm.JournalEntries {
+	entry := newJournalEntry(account, amountCents)
+	entry.Currency = &currency
+	return entry
+}
+
+// newSecuritiesEntry is a securities journal entry of quantity millionths of a share, a debit when positive and a credit when negative.
+func newSecuritiesEntry(account string, stockId int64, quantity int64) orm.JournalEntries {
+	entry := newJournalEntry(account, quantity)
+	entry.StockID = &stockId
+	return entry
+}
+
+// postJournal appends a journal with its entries in tx, the debits and credits of every asset (each stock and each
+// currency) must balance.
+func postJournal(tx *gorm.DB, journal orm.Journals, entries []orm.JournalEntries) error {
+
+	balances := make(map[string]int64)
+	for _, entry := range entries {
+		asset := util.CurrencyUsd
+		if entry.StockID != nil {
+			asset = fmt.Sprintf("stock %d", *entry.StockID)
+		} else if entry.Currency != nil {
+			asset = *entry.Currency
+		}
+		balances[asset] += entry.Debit - entry.Credit
+	}
+	for asset, balance := range balances {
+		if balance != 0 {
+			return fmt.Errorf("unbalanced %s journal for %s, off by %d", journal.EventType, asset, balance)
+		}
+	}
+
+	if err := tx.Create(&journal).Error; err != nil {
+		fmt.Println("Failed to save journal:", err)
+		return errors.New("failed to save journal")
+	}
+
+	for i := range entries {
+		entries[i].JournalID = journal.JournalID
+		entries[i].UserID = journal.UserID
+	}
+	if len(entries) > 0 {
+		if err := tx.Create(&entries).Error; err != nil {
+			fmt.Println("Failed to save journal entries:", err)
+			return errors.New("failed to save journal entries")
+		}
+	}
+
+	return nil
+}
+
+// postTradeJournal records an execution: the shares against the cash with the market in the stock's currency, and the
+// fee in USD.
+func postTradeJournal(tx *gorm.DB, execution orm.Executions, stock orm.Stocks) error {
+
+	quantity, valueCents := execution.Quantity, util.GetValueCents(execution.Quantity, execution.PricePerShareCents)
+	if execution.TradeType == util.TradeTypeSell {
+		quantity, valueCents = -quantity, -valueCents
+	}
+
+	entries := []orm.JournalEntries{
+		newSecuritiesEntry(util.LedgerAccountUserSecurities, execution.StockID, quantity),
+		newSecuritiesEntry(util.LedgerAccountMarket, execution.StockID, -quantity),
+		newCashEntry(util.LedgerAccountMarket, getStockCurrency(stock), valueCents),
+		newCashEntry(util.LedgerAccountUserCash, getStockCurrency(stock), -valueCents),
+	}
+	if execution.FeeCents > 0 {
+		entries = append(entries,
+			newCashEntry(util.LedgerAccountFees, util.CurrencyUsd, execution.FeeCents),
+			newCashEntry(util.LedgerAccountUserCash, util.CurrencyUsd, -execution.FeeCents))
+	}
+
+	journal := orm.Journals{
+		UserID:      execution.UserID,
+		EventType:   util.JournalEventTrade,
+		ExecutionID: &execution.ExecutionID,
+		Description: fmt.Sprintf("%s %g %s at %.2f", execution.TradeType, util.ConvertQuantityToShares(execution.Quantity), stock.Ticker,
+			util.ConvertCentsToDollars(execution.PricePerShareCents)),
+		CreatedAt: execution.CreatedAt,
+	}
+
+	return postJournal(tx, journal, entries)
+}
+
+// postDepositJournal records cash paid into the user's account.
+func postDepositJournal(tx *gorm.DB, userId int64, amountCents int64, description string, createdAt time.Time) error {
+
+	journal := orm.Journals{
+		UserID:      userId,
+		EventType:   util.JournalEventDeposit,
+		Description: description,
+		CreatedAt:   createdAt,
+	}
+
+	return postJournal(tx, journal, []orm.JournalEntries{
+		newCashEntry(util.LedgerAccountUserCash, util.CurrencyUsd, amountCents),
+		newCashEntry(util.LedgerAccountDeposits, util.CurrencyUsd, -amountCents),
+	})
+}
+
+func GetLedger(userId int64) []model.JournalModel {
+
+	stocksById := getStocksById()
+	journals := db.GetJournalsByUserId(userId, 100)
+
+	journalIds := make([]int64, len(journals))
+	for i, journal := range journals {
+		journalIds[i] = journal.JournalID
+	}
+
+	entriesMap := make(map[int64][]model.JournalEntryModel)
+	for _, entry := range db.GetJournalEntriesByJournalIds(journalIds) {
+		entryModel := model.JournalEntryModel{Account: entry.Account}
+		if entry.StockID != nil {
+			entryModel.StockTicker = stocksById[*entry.StockID].Ticker
+			entryModel.DebitQuantity = util.ConvertQuantityToShares(entry.Debit)
+			entryModel.CreditQuantity = util.ConvertQuantityToShares(entry.Credit)
+		} else {
+			entryModel.Currency = util.CurrencyUsd
+			if entry.Currency != nil {
+				entryModel.Currency = *entry.Currency
+			}
+			entryModel.DebitDollars = util.ConvertCentsToDollars(entry.Debit)
+			entryModel.CreditDollars = util.ConvertCentsToDollars(entry.Credit)
+		}
+		entriesMap[entry.JournalID] = append(entriesMap[entry.JournalID], entryModel)
+	}
+
+	journalModels := make([]model.JournalModel, len(journals))
+	for i, journal := range journals {
+		journalModels[i] = model.JournalModel{
+			JournalID:   journal.JournalID,
+			EventType:   journal.EventType,
+			Description: journal.Description,
+			CreatedAt:   util.GetDateTimeString(journal.CreatedAt),
+			Entries:     entriesMap[journal.JournalID],
+		}
+		if journal.ExecutionID != nil {
+			journalModels[i].ExecutionID = *journal.ExecutionID
+		}
+	}
+
+	return journalModels
+}
+
+// ReconcileLedger compares the balances derived from the journal with the user's cash balances and holdings.
+func ReconcileLedger(userId int64) (model.ReconciliationModel, error) {
+
+	user := db.GetUserById(userId)
+	if user.UserID == 0 {
+		return model.ReconciliationModel{}, errors.New("user not found")
+	}
+
+	stocksById := getStocksById()
+	ledgerCashBalances := db.GetLedgerCashBalancesByUserId(userId)
+	ledgerCashCents := ledgerCashBalances[util.CurrencyUsd]
+	ledgerPositions := db.GetLedgerPositionsByUserId(userId)
+
+	holdingPositions := make(map[int64]int64)
+	for _, holding := range db.GetHoldingsByUserID(userId) {
+		holdingPositions[holding.StockID] += holding.Quantity
+	}
+
+	positionBreaks := make([]model.PositionBreakModel, 0)
+	for stockId, stock := range stocksById {
+		if ledgerPositions[stockId] != holdingPositions[stockId] {
+			positionBreaks = append(positionBreaks, model.PositionBreakModel{
+				StockTicker:     stock.Ticker,
+				LedgerQuantity:  util.ConvertQuantityToShares(ledgerPositions[stockId]),
+				HoldingQuantity: util.ConvertQuantityToShares(holdingPositions[stockId]),
+			})
+		}
+	}
+
+	sort.Slice(positionBreaks, func(i, j int) bool {
+		return positionBreaks[i].StockTicker < positionBreaks[j].StockTicker
+	})
+
+	cashBalances := make(map[string]int64)
+	for _, cashBalance := range db.GetCashBalancesByUserId(userId) {
+		cashBalances[cashBalance.Currency] = cashBalance.BalanceCents
+	}
+
+	cashBreaks := make([]model.CashBreakModel, 0)
+	for currency := range getFxRates() {
+		if currency != util.CurrencyUsd && ledgerCashBalances[currency] != cashBalances[currency] {
+			cashBreaks = append(cashBreaks, model.CashBreakModel{
+				Currency:      currency,
+				LedgerBalance: util.ConvertCentsToDollars(ledgerCashBalances[currency]),
+				CashBalance:   util.ConvertCentsToDollars(cashBalances[currency]),
+			})
+		}
+	}
+
+	sort.Slice(cashBreaks, func(i, j int) bool {
+		return cashBreaks[i].Currency < cashBreaks[j].Currency
+	})
+
+	return model.ReconciliationModel{
+		UserID:                userId,
+		IsReconciled:          ledgerCashCents == user.CashBalanceCents && len(cashBreaks) == 0 && len(positionBreaks) == 0,
+		LedgerCashDollars:     util.ConvertCentsToDollars(ledgerCashCents),
+		CashBalanceDollars:    util.ConvertCentsToDollars(user.CashBalanceCents),
+		CashDifferenceDollars: util.ConvertCentsToDollars(user.CashBalanceCents - ledgerCashCents),
+		CashBreaks:            cashBreaks,
+		PositionBreaks:        positionBreaks,
+	}, nil
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+
+	"gorm.io/gorm"
+)
+
+// marginAccount is a user's account valued in USD at the current stock prices and FX rates. Short sale proceeds sit
+// in cash, so the equity is the cash in every currency plus the longs' market value minus the shorts' market value.
+type marginAccount struct {
+	EquityCents                 int64
+	InitialRequirementCents     int64
+	MaintenanceRequirementCents int64
+	ReservedCents               int64 // initial margin held by pending buys and the short opening part of pending sells
+}
+
+// getMarginAccount values the account through tx, seeing the trades made earlier in the same transaction.
+func getMarginAccount(tx *gorm.DB, user orm.Users, stocksById map[int64]orm.Stocks) marginAccount {
+
+	fxRates := getFxRates()
+	account := marginAccount{
+		EquityCents:   user.CashBalanceCents + getForeignCashUsdCents(tx, user.UserID, fxRates),
+		ReservedCents: db.GetReservedMarginCentsByUserIdTx(tx, user.UserID),
+	}
+
+	for _, holding := range db.GetActiveHoldingsByUserIDTx(tx, user.UserID) {
+		stock := stocksById[holding.StockID]
+		marketValueCents := getUsdValueCents(fxRates, stock, holding.Quantity, stock.CurrentPriceCents)
+
+		account.EquityCents += marketValueCents
+		account.InitialRequirementCents += getMarginCents(marketValueCents, getInitialMarginPercent(stock))
+		account.MaintenanceRequirementCents += getMarginCents(marketValueCents, getMaintenanceMarginPercent(stock))
+	}
+
+	return account
+}
+
+// getExcessEquityCents is the equity left over the initial requirement of the positions and pending orders.
+func (account marginAccount) getExcessEquityCents() int64 {
+	return account.EquityCents - account.InitialRequirementCents - account.ReservedCents
+}
+
+// getBuyingPowerCents is the position value the excess equity can open at the given initial margin.
+func (account marginAccount) getBuyingPowerCents(initialMarginPercent float64) int64 {
+	excessEquityCents := account.getExcessEquityCents()
+	if excessEquityCents <= 0 {
+		return 0
+	}
+	return int64(float64(excessEquityCents) * 100 / initialMarginPercent)
+}
+
+func (account marginAccount) isBelowMaintenance() bool {
+	return account.MaintenanceRequirementCents > 0 && account.EquityCents < account.MaintenanceRequirementCents
+}
+
+func getMarginCents(valueCents int64, marginPercent float64) int64 {
+	return int64(math.Round(math.Abs(float64(valueCents)) * marginPercent / 100))
+}
+
+func getInitialMarginPercent(stock orm.Stocks) float64 {
+	if stock.InitialMarginPercent > 0 {
+		return stock.InitialMarginPercent
+	}
+	return util.DefaultInitialMarginPercent
+}
+
+func getMaintenanceMarginPercent(stock orm.Stocks) float64 {
+	if stock.MaintenanceMarginPercent > 0 {
+		return stock.MaintenanceMarginPercent
+	}
+	return util.DefaultMaintenanceMarginPercent
+}
+
+func getStocksById() map[int64]orm.Stocks {
+	stocksById := make(map[int64]orm.Stocks)
+	for _, stock := range db.GetAllStocks() {
+		stocksById[stock.StockID] = stock
+	}
+	return stocksById
+}
+
+// checkBuyingPower checks that the user has the initial margin for the part of a trade that opens or grows
+// a position, closing a long or covering a short needs none. pendingOrder is the order being filled or
+// amended, if any, whose own reservation is released. The account is read through tx.
+func checkBuyingPower(tx *gorm.DB, user orm.Users, stock orm.Stocks, tradeType string, quantity int64, pricePerShareCents int64, pendingOrder *orm.Orders) error {
+
+	holding := db.GetHoldingByUserIdAndStockIdTx(tx, user.UserID, stock.StockID)
+	fxRates := getFxRates()
+
+	var releasedCents, closingQuantity int64
+	isPending := pendingOrder != nil && isOrderFillable(*pendingOrder)
+
+	if tradeType == util.TradeTypeBuy {
+		closingQuantity = -holding.Quantity
+		if isPending {
+			releasedCents = getMarginCents(toUsdCents(fxRates, getStockCurrency(stock), pendingOrder.TotalOrderValueCents), getInitialMarginPercent(stock))
+		}
+	} else {
+		//shares reserved by other pending sell orders are not available to close the long position
+		reservedQuantity := db.GetReservedSellQuantityByUserIdAndStockIdTx(tx, user.UserID, stock.StockID)
+		closingQuantity = holding.Quantity - reservedQuantity
+		if isPending {
+			remainingQuantity := pendingOrder.Quantity - pendingOrder.FilledQuantity
+			closingQuantity += remainingQuantity
+			//the part of the order opening a short holds a reservation
+			if reservedOpeningQuantity := min(remainingQuantity, reservedQuantity-max(holding.Quantity, 0)); reservedOpeningQuantity > 0 {
+				releasedCents = getMarginCents(getUsdValueCents(fxRates, stock, reservedOpeningQuantity, pendingOrder.PricePerShareCents), getInitialMarginPercent(stock))
+			}
+		}
+	}
+
+	openingQuantity := quantity - max(closingQuantity, 0)
+	if openingQuantity <= 0 {
+		return nil
+	}
+
+	requiredCents := getMarginCents(getUsdValueCents(fxRates, stock, openingQuantity, pricePerShareCents), getInitialMarginPercent(stock))
+	if requiredCents > getMarginAccount(tx, user, getStocksById()).getExcessEquityCents()+releasedCents {
+		return errors.New("user don't have enough buying power")
+	}
+
+	return nil
+}
+
+// CheckMarginAccounts issues a margin call to every account whose equity fell below its maintenance requirement,
+// and liquidates the positions of the ones still below it once the grace period is over.
+// It returns the notifications to push to the users.
+func CheckMarginAccounts(now time.Time) []model.NotificationModel {
+
+	notifications := make([]model.NotificationModel, 0)
+	stocksById := getStocksById()
+
+	for _, user := range db.GetMarginAccountUsers() {
+
+		account := getMarginAccount(db.DB, user, stocksById)
+
+		var notification model.NotificationModel
+		var err error
+
+		switch {
+		case !account.isBelowMaintenance():
+			if user.MarginCallAt != nil {
+				err = db.DB.Model(&orm.Users{}).Where("user_id = ?", user.UserID).Update("margin_call_at", nil).Error
+			}
+		case user.MarginCallAt == nil:
+			notification, err = issueMarginCall(user, account, now)
+		case now.Sub(*user.MarginCallAt) >= util.MarginCallGracePeriod:
+			notification, err = forceLiquidate(user, account, stocksById, now)
+		}
+
+		if err != nil {
+			fmt.Printf("Failed to check margin account of user %d, %s\n", user.UserID, err.Error())
+			continue
+		}
+		if notification.UserID > 0 {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	return notifications
+}
+
+func issueMarginCall(user orm.Users, account marginAccount, now time.Time) (model.NotificationModel, error) {
+
+	notification := model.NotificationModel{
+		UserID:    user.UserID,
+		EventType: util.NotificationEventMarginCall,
+		Message: fmt.Sprintf("Margin call: equity $%.2f is below the maintenance requirement of $%.2f, "+
+			"deposit cash or reduce positions within %s to avoid a forced liquidation",
+			util.ConvertCentsToDollars(account.EquityCents),
+			util.ConvertCentsToDollars(account.MaintenanceRequirementCents),
+			util.MarginCallGracePeriod),
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+
+		err := tx.Model(&orm.Users{}).Where("user_id = ?", user.UserID).Update("margin_call_at", now).Error
+		if err != nil {
+			return err
+		}
+
+		return createNotification(tx, &notification, now)
+	})
+
+	return notification, err
+}
+
+// forceLiquidate cancels the user's open orders and closes positions at the current price, the ones with the
+// largest maintenance requirement first, until the equity covers the requirement of what is left.
+func forceLiquidate(user orm.Users, account marginAccount, stocksById map[int64]orm.Stocks, now time.Time) (model.NotificationModel, error) {
+
+	var notification model.NotificationModel
+
+	err := bookTransaction(func(tx *gorm.DB) error {
+
+		orderIds := db.GetOpenOrderIdsByUserIdTx(tx, user.UserID, openOrderStatuses)
+		err := tx.Model(&orm.Orders{}).
+			Where("order_id in ? and order_status in ?", orderIds, openOrderStatuses).
+			Updates(map[string]interface{}{
+				"order_status": util.OrderStatusCanceled,
+				"notes":        "canceled by forced liquidation",
+			}).Error
+		if err != nil {
+			return err
+		}
+		recordBookChange(tx, orderIds...)
+
+		holdings := db.GetActiveHoldingsByUserID(user.UserID)
+		fxRates := getFxRates()
+		getRequirementCents := func(holding orm.Holdings) int64 {
+			stock := stocksById[holding.StockID]
+			return getMarginCents(getUsdValueCents(fxRates, stock, holding.Quantity, stock.CurrentPriceCents), getMaintenanceMarginPercent(stock))
+		}
+		sort.Slice(holdings, func(i, j int) bool {
+			return getRequirementCents(holdings[i]) > getRequirementCents(holdings[j])
+		})
+
+		//closing near the current price leaves the equity about unchanged, only the requirement goes down
+		requirementCents := account.MaintenanceRequirementCents
+		liquidatedTickers := make([]string, 0)
+
+		orderTemplate := newMarketOrder()
+		orderTemplate.Notes = "forced liquidation after margin call"
+
+		for i := range holdings {
+			if account.EquityCents >= requirementCents {
+				break
+			}
+
+			holding := holdings[i]
+			stock := stocksById[holding.StockID]
+			positionRequirementCents := getRequirementCents(holding)
+
+			tradeType, quantity := util.TradeTypeSell, holding.Quantity
+			if holding.Quantity < 0 {
+				tradeType, quantity = util.TradeTypeBuy, -holding.Quantity
+			}
+
+			pricePerShareCents := getFillPriceCents(stock, tradeType, quantity, 0)
+			if _, err := executeOrder(tx, &user, stock, tradeType, quantity, pricePerShareCents, orderTemplate); err != nil {
+				return err
+			}
+
+			requirementCents -= positionRequirementCents
+			liquidatedTickers = append(liquidatedTickers, stock.Ticker)
+		}
+
+		err = tx.Model(&orm.Users{}).Where("user_id = ?", user.UserID).Update("margin_call_at", nil).Error
+		if err != nil {
+			return err
+		}
+
+		notification = model.NotificationModel{
+			UserID:    user.UserID,
+			EventType: util.NotificationEventForcedLiquidation,
+			Message: fmt.Sprintf("Margin call not met, positions in %s were liquidated and open orders canceled",
+				strings.Join(liquidatedTickers, ", ")),
+		}
+
+		return createNotification(tx, &notification, now)
+	})
+
+	return notification, err
+}
@@ -17,8 +17,11 @@ func GetMarketData(stockID int64) model.MarketModel {
 		StockID:               stock.StockID,
 		Ticker:                stock.Ticker,
 		Name:                  stock.Name,
+		Currency:              getStockCurrency(stock),
 		OpeningPriceDollars:   util.ConvertCentsToDollars(stock.OpeningPriceCents),
 		CurrentPriceDollars:   util.ConvertCentsToDollars(stock.CurrentPriceCents),
+		BidDollars:            util.ConvertCentsToDollars(stock.BidPriceCents),
+		AskDollars:            util.ConvertCentsToDollars(stock.AskPriceCents),
 		UpdatedAt:             util.GetDateTimeString(stock.UpdatedAt),
 		OverallSentimentScore: stock.OverallSentimentScore,
 	}
@@ -45,6 +48,7 @@ func GetMarketData(stockID int64) model.MarketModel {
 	return model.MarketModel{
 		Stock: stockModel,
 		News:  newsModels,
+		Depth: GetOrderBookDepth(stock.StockID, util.OrderBookDepthLevels),
 	}
 }
 
@@ -57,8 +61,11 @@ func GetAllStocksData() []model.StockModel {
 			StockID:             stock.StockID,
 			Ticker:              stock.Ticker,
 			Name:                stock.Name,
+			Currency:            getStockCurrency(stock),
 			OpeningPriceDollars: util.ConvertCentsToDollars(stock.OpeningPriceCents),
 			CurrentPriceDollars: util.ConvertCentsToDollars(stock.CurrentPriceCents),
+			BidDollars:          util.ConvertCentsToDollars(stock.BidPriceCents),
+			AskDollars:          util.ConvertCentsToDollars(stock.AskPriceCents),
 			UpdatedAt:           util.GetDateTimeString(stock.UpdatedAt),
 		}
 		stockModel.ChangedPriceDollars = stockModel.GetChangedPriceDollars()
@@ -0,0 +1,411 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+
+	"gorm.io/gorm"
+)
+
+// marketCalendar holds the upcoming exchange holidays by date, reloaded every trading date.
+var marketCalendar = struct {
+	mu       sync.RWMutex
+	holidays map[string]orm.MarketHolidays
+}{holidays: make(map[string]orm.MarketHolidays)}
+
+// marketSessionState is the authoritative session, only moved by AdvanceMarketSession.
+var marketSessionState = struct {
+	mu          sync.RWMutex
+	session     string
+	tradingDate time.Time
+}{session: util.MarketSessionClosed}
+
+// marketSessionTransitions is the session that follows each session.
+var marketSessionTransitions = map[string]string{
+	util.MarketSessionClosed:     util.MarketSessionPreMarket,
+	util.MarketSessionPreMarket:  util.MarketSessionRegular,
+	util.MarketSessionRegular:    util.MarketSessionAfterHours,
+	util.MarketSessionAfterHours: util.MarketSessionClosed,
+}
+
+func loadMarketHolidays(now time.Time) {
+
+	holidays := make(map[string]orm.MarketHolidays)
+	for _, holiday := range db.GetMarketHolidays(util.GetMarketDate(now)) {
+		holidays[util.GetDateString(holiday.HolidayDate)] = holiday
+	}
+
+	marketCalendar.mu.Lock()
+	marketCalendar.holidays = holidays
+	marketCalendar.mu.Unlock()
+}
+
+func getMarketHoliday(date time.Time) (orm.MarketHolidays, bool) {
+	marketCalendar.mu.RLock()
+	defer marketCalendar.mu.RUnlock()
+	holiday, ok := marketCalendar.holidays[util.GetDateString(util.GetMarketDate(date))]
+	return holiday, ok
+}
+
+// getRegularCloseMinute returns when the regular session of the market day closes, 0 when the market is closed all day.
+func getRegularCloseMinute(date time.Time) int {
+
+	weekday := util.GetMarketDate(date).Weekday()
+	if weekday == time.Saturday || weekday == time.Sunday {
+		return 0
+	}
+
+	if holiday, ok := getMarketHoliday(date); ok {
+		if holiday.EarlyClose {
+			return util.EarlyCloseMinute
+		}
+		return 0
+	}
+
+	return util.RegularCloseMinute
+}
+
+// getMarketSessionAt returns the session the calendar schedules at t.
+func getMarketSessionAt(t time.Time) string {
+
+	closeMinute := getRegularCloseMinute(t)
+	if closeMinute == 0 {
+		return util.MarketSessionClosed
+	}
+
+	minute := util.GetMarketMinute(t)
+	switch {
+	case minute < util.PreMarketOpenMinute:
+		return util.MarketSessionClosed
+	case minute < util.RegularOpenMinute:
+		return util.MarketSessionPreMarket
+	case minute < closeMinute:
+		return util.MarketSessionRegular
+	case minute < util.AfterHoursCloseMinute:
+		return util.MarketSessionAfterHours
+	}
+
+	return util.MarketSessionClosed
+}
+
+// getNextMarketSession returns the next session after the one scheduled at t and when it starts.
+func getNextMarketSession(t time.Time) (string, time.Time) {
+
+	currentSession := getMarketSessionAt(t)
+
+	for day := 0; day < 14; day++ {
+		date := util.GetMarketDate(t).AddDate(0, 0, day)
+		closeMinute := getRegularCloseMinute(date)
+		if closeMinute == 0 {
+			continue
+		}
+
+		for _, minute := range []int{util.PreMarketOpenMinute, util.RegularOpenMinute, closeMinute, util.AfterHoursCloseMinute} {
+			startsAt := util.GetMarketTimeAt(date, minute)
+			if !startsAt.After(t) {
+				continue
+			}
+			if session := getMarketSessionAt(startsAt); session != currentSession {
+				return session, startsAt
+			}
+		}
+	}
+
+	return currentSession, time.Time{}
+}
+
+// isOrderTypeAccepted reports whether orders of the type are placed during the session, the others are queued.
+func isOrderTypeAccepted(session string, orderType string) bool {
+	switch session {
+	case util.MarketSessionRegular:
+		return true
+	case util.MarketSessionPreMarket, util.MarketSessionAfterHours:
+		return orderType == util.OrderTypeLimit
+	}
+	return false
+}
+
+// IsMarketSessionTicking reports whether prices move during the session.
+func IsMarketSessionTicking(session string) bool {
+	return session != util.MarketSessionClosed
+}
+
+func GetCurrentMarketSession() string {
+	marketSessionState.mu.RLock()
+	defer marketSessionState.mu.RUnlock()
+	return marketSessionState.session
+}
+
+func GetMarketSession() model.MarketSessionModel {
+
+	now := time.Now()
+	session := GetCurrentMarketSession()
+
+	marketSession := model.MarketSessionModel{
+		Session:            session,
+		TradingDate:        util.GetDateString(util.GetMarketDate(now)),
+		AcceptedOrderTypes: make([]string, 0),
+		UpcomingHolidays:   make([]model.MarketHolidayModel, 0),
+	}
+
+	if holiday, ok := getMarketHoliday(now); ok {
+		marketSession.Holiday = holiday.Name
+	}
+
+	if closeMinute := getRegularCloseMinute(now); closeMinute > 0 {
+		marketSession.IsTradingDay = true
+		marketSession.RegularOpen = util.GetDateTimeString(util.GetMarketTimeAt(now, util.RegularOpenMinute))
+		marketSession.RegularClose = util.GetDateTimeString(util.GetMarketTimeAt(now, closeMinute))
+	}
+
+	nextSession, nextSessionAt := getNextMarketSession(now)
+	if !nextSessionAt.IsZero() {
+		marketSession.NextSession = nextSession
+		marketSession.NextSessionAt = util.GetDateTimeString(nextSessionAt)
+	}
+
+	for _, orderType := range []string{util.OrderTypeMarket, util.OrderTypeLimit, util.OrderTypeStop, util.OrderTypeStopLimit, util.OrderTypeTrailingStop} {
+		if isOrderTypeAccepted(session, orderType) {
+			marketSession.AcceptedOrderTypes = append(marketSession.AcceptedOrderTypes, orderType)
+		}
+	}
+
+	marketCalendar.mu.RLock()
+	for _, holiday := range marketCalendar.holidays {
+		marketSession.UpcomingHolidays = append(marketSession.UpcomingHolidays, model.MarketHolidayModel{
+			Date:       util.GetDateString(holiday.HolidayDate),
+			Name:       holiday.Name,
+			EarlyClose: holiday.EarlyClose,
+		})
+	}
+	marketCalendar.mu.RUnlock()
+
+	sort.Slice(marketSession.UpcomingHolidays, func(i, j int) bool {
+		return marketSession.UpcomingHolidays[i].Date < marketSession.UpcomingHolidays[j].Date
+	})
+
+	return marketSession
+}
+
+// AdvanceMarketSession moves the session state machine to the session the calendar schedules at now, going through
+// every session in between and running its start actions, and returns the current session with the notifications
+// of the start actions. The state is restored from the last market_session_events row on the first call, so
+// transitions missed while the server was down still run.
+func AdvanceMarketSession(now time.Time) (string, []model.NotificationModel) {
+
+	tradingDate := util.GetMarketDate(now)
+
+	marketSessionState.mu.Lock()
+	lastTradingDate := marketSessionState.tradingDate
+	if lastTradingDate.IsZero() {
+		lastEvent := db.GetLatestMarketSessionEvent()
+		if lastEvent.MarketSessionEventID > 0 {
+			marketSessionState.session = lastEvent.Session
+			lastTradingDate = util.GetMarketDate(lastEvent.StartedAt)
+		}
+	}
+	marketSessionState.tradingDate = tradingDate
+	session := marketSessionState.session
+	marketSessionState.mu.Unlock()
+
+	if !lastTradingDate.Equal(tradingDate) {
+		loadMarketHolidays(now)
+	}
+
+	targetSession := getMarketSessionAt(now)
+
+	//the same open session on a new day means a whole day was missed, go around the cycle once
+	fullCycle := session == targetSession && session != util.MarketSessionClosed && !lastTradingDate.Equal(tradingDate)
+
+	notifications := make([]model.NotificationModel, 0)
+
+	for session != targetSession || fullCycle {
+		fullCycle = false
+		session = marketSessionTransitions[session]
+
+		//the state moves before the start actions, so the orders they place see the new session
+		marketSessionState.mu.Lock()
+		marketSessionState.session = session
+		marketSessionState.mu.Unlock()
+
+		err := db.DB.Create(&orm.MarketSessionEvents{Session: session, TradingDate: tradingDate, StartedAt: now}).Error
+		if err != nil {
+			fmt.Println("Failed to save market session event:", err)
+		}
+		fmt.Printf("[MarketSession] %s started\n", session)
+
+		notifications = append(notifications, startMarketSession(session, now)...)
+	}
+
+	return session, notifications
+}
+
+// startMarketSession runs the actions of a session start: the regular open rolls the opening prices over and lists
+// the new option contracts, the regular close expires the DAY orders and settles the expiring options, and queued
+// orders are placed once their type is accepted. It returns the notifications for the users.
+func startMarketSession(session string, now time.Time) []model.NotificationModel {
+
+	notifications := make([]model.NotificationModel, 0)
+
+	switch session {
+	case util.MarketSessionRegular:
+		db.UpdateStocksRollOpeningPrice()
+		GenerateOptionChains(now)
+	case util.MarketSessionAfterHours:
+		if expiredCount := ExpireDayOrders(now); expiredCount > 0 {
+			fmt.Printf("[MarketSession] Expired %d DAY orders\n", expiredCount)
+		}
+		notifications = append(notifications, ExpireOptionContracts(now)...)
+	}
+
+	releaseQueuedOrders(session)
+
+	return notifications
+}
+
+// queueOrder saves an order placed while its type is not accepted, it is placed when a session accepts it.
+func queueOrder(orderRequest model.OrderRequest, session string) error {
+
+	stock := db.GetStockByTicker(orderRequest.Ticker)
+	if stock.StockID == 0 {
+		return errors.New("stock " + orderRequest.Ticker + " not found")
+	}
+
+	if orderRequest.OrderType == "" {
+		orderRequest.OrderType = util.OrderTypeMarket
+	}
+
+	//estimate only, the order is priced again when released
+	pricePerShareCents := orderRequest.LimitPriceCents
+	if pricePerShareCents == 0 {
+		pricePerShareCents = getQuotePriceCents(stock, orderRequest.TradeType)
+	}
+
+	order := orm.Orders{
+		UserID:               orderRequest.UserID,
+		StockID:              stock.StockID,
+		TradeType:            orderRequest.TradeType,
+		OrderType:            orderRequest.OrderType,
+		TimeInForce:          orderRequest.TimeInForce,
+		OrderStatus:          util.OrderStatusQueued,
+		Quantity:             orderRequest.Quantity,
+		LimitPriceCents:      orderRequest.LimitPriceCents,
+		StopPriceCents:       orderRequest.StopPriceCents,
+		TrailAmountCents:     orderRequest.TrailAmountCents,
+		TrailPercent:         orderRequest.TrailPercent,
+		PricePerShareCents:   pricePerShareCents,
+		TotalOrderValueCents: util.GetValueCents(orderRequest.Quantity, pricePerShareCents),
+		CreatedAt:            time.Now(),
+		TaxLotIDs:            orderRequest.TaxLotIDs,
+		Notes:                fmt.Sprintf("queued, %s orders are not accepted during the %s session", orderRequest.OrderType, session),
+	}
+
+	if err := db.DB.Create(&order).Error; err != nil {
+		fmt.Println("Failed to save order:", err)
+		return errors.New("failed to save order")
+	}
+
+	return nil
+}
+
+// errQueuedOrderGone is returned when a queued order being released was canceled or released meanwhile.
+var errQueuedOrderGone = errors.New("order is no longer queued")
+
+// releaseQueuedOrders places the queued orders the session accepts, oldest first. A released order keeps its id and
+// becomes the order it places, or FAILED with the reason.
+func releaseQueuedOrders(session string) {
+
+	stocksById := getStocksById()
+
+	for _, order := range db.GetQueuedOrders() {
+
+		if !isOrderTypeAccepted(session, order.OrderType) {
+			continue
+		}
+
+		err := releaseQueuedOrder(order, stocksById[order.StockID])
+		if err == nil || errors.Is(err, errQueuedOrderGone) {
+			continue
+		}
+
+		//the placement rolled back, the order stays with the reason it failed
+		result := db.DB.Model(&orm.Orders{}).
+			Where("order_id = ? and order_status = ?", order.OrderID, util.OrderStatusQueued).
+			Updates(map[string]interface{}{
+				"order_status": util.OrderStatusFailed,
+				"notes":        fmt.Sprintf("failed when released at the %s session start, %s", session, err.Error()),
+			})
+		if result.Error != nil {
+			fmt.Println("Failed to save order:", result.Error)
+		}
+	}
+}
+
+// releaseQueuedOrder runs the risk checks on a queued order and places it in one transaction, under its own id.
+func releaseQueuedOrder(order orm.Orders, stock orm.Stocks) error {
+
+	orderRequest := model.OrderRequest{
+		UserID:           order.UserID,
+		Ticker:           stock.Ticker,
+		TradeType:        order.TradeType,
+		OrderType:        order.OrderType,
+		TimeInForce:      order.TimeInForce,
+		Quantity:         order.Quantity,
+		LimitPriceCents:  order.LimitPriceCents,
+		StopPriceCents:   order.StopPriceCents,
+		TrailAmountCents: order.TrailAmountCents,
+		TrailPercent:     order.TrailPercent,
+		TaxLotIDs:        order.TaxLotIDs,
+	}
+
+	if rejection, _ := getRiskRejection(orderRequest); rejection != nil {
+		return rejection
+	}
+
+	return bookTransaction(func(tx *gorm.DB) error {
+
+		if stock.StockID == 0 {
+			return errors.New("stock not found")
+		}
+
+		user := db.GetUserByIdTx(tx, order.UserID)
+		if user.UserID == 0 {
+			return errors.New("user does not exist")
+		}
+
+		switch order.OrderType {
+		case util.OrderTypeMarket:
+			orderTemplate := newMarketOrder()
+			orderTemplate.OrderID = order.OrderID
+			orderTemplate.TimeInForce = order.TimeInForce
+			orderTemplate.TaxLotIDs = order.TaxLotIDs
+
+			var err error
+			if order.TradeType == util.TradeTypeBuy {
+				_, err = buyStocks(tx, &user, stock, order.Quantity, orderTemplate)
+			} else {
+				_, err = sellStocks(tx, &user, stock, order.Quantity, orderTemplate)
+			}
+			return err
+
+		case util.OrderTypeLimit:
+			_, err := placeLimitOrderTx(tx, user, stock, orderRequest, order.OrderID)
+			return err
+		}
+
+		restingOrder, err := buildRestingOrder(tx, user, stock, orderRequest)
+		if err != nil {
+			return err
+		}
+		restingOrder.OrderID = order.OrderID
+
+		return savePlacedOrder(tx, &restingOrder)
+	})
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+
+	"gorm.io/gorm"
+)
+
+func GetNotifications(userId int64) []model.NotificationModel {
+
+	notifications := db.GetNotificationsByUserId(userId, 50)
+	notificationModels := make([]model.NotificationModel, len(notifications))
+
+	for i, notification := range notifications {
+		notificationModels[i] = model.NotificationModel{
+			NotificationID: notification.NotificationID,
+			UserID:         notification.UserID,
+			EventType:      notification.EventType,
+			Message:        notification.Message,
+			IsRead:         notification.IsRead,
+			CreatedAt:      util.GetDateTimeString(notification.CreatedAt),
+		}
+		if notification.OrderID != nil {
+			notificationModels[i].OrderID = *notification.OrderID
+		}
+	}
+
+	return notificationModels
+}
+
+// createNotification saves the notification so it can be listed later, and fills in its id and creation time.
+func createNotification(tx *gorm.DB, notification *model.NotificationModel, createdAt time.Time) error {
+
+	record := orm.Notifications{
+		UserID:    notification.UserID,
+		EventType: notification.EventType,
+		Message:   notification.Message,
+		CreatedAt: createdAt,
+	}
+	if notification.OrderID > 0 {
+		record.OrderID = &notification.OrderID
+	}
+
+	if err := tx.Create(&record).Error; err != nil {
+		return err
+	}
+
+	notification.NotificationID = record.NotificationID
+	notification.CreatedAt = util.GetDateTimeString(createdAt)
+	return nil
+}
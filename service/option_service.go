@@ -0,0 +1,691 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// optionQuote is the Black-Scholes premium of a contract per share of the underlying, at the current stock price.
+type optionQuote struct {
+	MarkCents int64
+	BidCents  int64
+	AskCents  int64
+	Greeks    util.OptionGreeks
+}
+
+func getOptionQuote(contract orm.OptionContracts, stock orm.Stocks, now time.Time) optionQuote {
+
+	yearsToExpiry := contract.ExpiresAt.Sub(now).Hours() / 24 / 365
+	markCents, greeks := util.GetBlackScholesPrice(contract.OptionType == util.OptionTypeCall, stock.CurrentPriceCents,
+		contract.StrikePriceCents, yearsToExpiry, stock.ImpliedVolatility)
+
+	quote := optionQuote{
+		MarkCents: int64(math.Round(markCents)),
+		Greeks:    greeks,
+	}
+	quote.BidCents, quote.AskCents = util.GetBidAskCents(quote.MarkCents, util.OptionSpreadBps)
+	quote.BidCents = max(quote.BidCents, 0)
+
+	return quote
+}
+
+// getOptionValueCents is the value of a number of contracts at a premium per share of the underlying.
+func getOptionValueCents(contract orm.OptionContracts, contracts int64, premiumCents int64) int64 {
+	return util.GetValueCents(contracts*contract.DeliverableQuantity, premiumCents)
+}
+
+// getOptionSymbol builds an OCC style symbol: the root, the expiration date as YYMMDD, C or P and the strike in
+// thousandths of a dollar on 8 digits.
+func getOptionSymbol(root string, optionType string, expiresAt time.Time, strikePriceCents int64) string {
+	return fmt.Sprintf("%s%s%s%08d", root, util.GetMarketDate(expiresAt).Format("060102"), optionType[:1], strikePriceCents*10)
+}
+
+// getOptionExpirations returns the regular session close of the listed expiration dates after now: the next weekly
+// Fridays and the third Fridays of the following months, moved back a day at a time while the market is closed.
+func getOptionExpirations(now time.Time) []time.Time {
+
+	today := util.GetMarketDate(now)
+	dates := make([]time.Time, 0)
+
+	nextFriday := today.AddDate(0, 0, (int(time.Friday)-int(today.Weekday())+7)%7)
+	for week := 0; week < util.OptionWeeklyExpirations; week++ {
+		dates = append(dates, nextFriday.AddDate(0, 0, 7*week))
+	}
+	for month := 1; month <= util.OptionMonthlyExpirations; month++ {
+		firstDay := time.Date(today.Year(), today.Month()+time.Month(month), 1, 0, 0, 0, 0, today.Location())
+		dates = append(dates, firstDay.AddDate(0, 0, (int(time.Friday)-int(firstDay.Weekday())+7)%7+14))
+	}
+
+	expirations := make([]time.Time, 0, len(dates))
+	seen := make(map[string]bool)
+	for _, date := range dates {
+		for getRegularCloseMinute(date) == 0 {
+			date = date.AddDate(0, 0, -1)
+		}
+		expiresAt := util.GetMarketTimeAt(date, getRegularCloseMinute(date))
+		if !expiresAt.After(now) || seen[util.GetDateString(date)] {
+			continue
+		}
+		seen[util.GetDateString(date)] = true
+		expirations = append(expirations, expiresAt)
+	}
+
+	return expirations
+}
+
+// getStrikeStepCents is the distance between listed strikes for a stock at priceCents.
+func getStrikeStepCents(priceCents int64) int64 {
+	switch {
+	case priceCents < 5000:
+		return 100
+	case priceCents < 20000:
+		return 250
+	}
+	return 500
+}
+
+// GenerateOptionChains lists the missing calls and puts of every stock for each expiration, at the strikes around
+// the current price. Contracts already listed keep their strikes, so a chain widens as the price moves.
+func GenerateOptionChains(now time.Time) {
+
+	expirations := getOptionExpirations(now)
+
+	for _, stock := range db.GetAllStocks() {
+
+		stepCents := getStrikeStepCents(stock.CurrentPriceCents)
+		atTheMoneyCents := int64(math.Round(float64(stock.CurrentPriceCents)/float64(stepCents))) * stepCents
+
+		contracts := make([]orm.OptionContracts, 0)
+		for _, expiresAt := range expirations {
+			for step := int64(-util.OptionStrikesPerSide); step <= util.OptionStrikesPerSide; step++ {
+				strikeCents := atTheMoneyCents + step*stepCents
+				if strikeCents <= 0 {
+					continue
+				}
+				for _, optionType := range []string{util.OptionTypeCall, util.OptionTypePut} {
+					contracts = append(contracts, orm.OptionContracts{
+						StockID:             stock.StockID,
+						Symbol:              getOptionSymbol(stock.Ticker, optionType, expiresAt, strikeCents),
+						OptionType:          optionType,
+						StrikePriceCents:    strikeCents,
+						DeliverableQuantity: util.OptionContractMultiplier * util.ShareScale,
+						ExpiresAt:           expiresAt,
+						Status:              util.OptionContractStatusActive,
+						CreatedAt:           now,
+					})
+				}
+			}
+		}
+		if len(contracts) == 0 {
+			continue
+		}
+
+		err := db.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "symbol"}},
+			DoNothing: true,
+		}).Create(&contracts).Error
+		if err != nil {
+			fmt.Printf("Failed to list options of %s, %s\n", stock.Ticker, err.Error())
+		}
+	}
+}
+
+func GetOptionChain(stockId int64) (model.OptionChainModel, error) {
+
+	stock := db.GetStockById(stockId)
+	if stock.StockID == 0 {
+		return model.OptionChainModel{}, errors.New("stock not found")
+	}
+
+	now := time.Now()
+	contracts := db.GetActiveOptionContractsByStockId(stockId)
+
+	chain := model.OptionChainModel{
+		StockTicker:       stock.Ticker,
+		UnderlyingDollars: util.ConvertCentsToDollars(stock.CurrentPriceCents),
+		ImpliedVolatility: stock.ImpliedVolatility,
+		Contracts:         make([]model.OptionContractModel, len(contracts)),
+	}
+	for i, contract := range contracts {
+		chain.Contracts[i] = getOptionContractModel(contract, stock, now)
+	}
+
+	return chain, nil
+}
+
+func getOptionContractModel(contract orm.OptionContracts, stock orm.Stocks, now time.Time) model.OptionContractModel {
+
+	quote := getOptionQuote(contract, stock, now)
+
+	return model.OptionContractModel{
+		OptionContractID:   contract.OptionContractID,
+		Symbol:             contract.Symbol,
+		StockTicker:        stock.Ticker,
+		OptionType:         contract.OptionType,
+		StrikePriceDollars: util.ConvertCentsToDollars(contract.StrikePriceCents),
+		ExpiresAt:          util.GetDateTimeString(contract.ExpiresAt),
+		DaysToExpiry:       math.Max(contract.ExpiresAt.Sub(now).Hours()/24, 0),
+		SharesPerContract:  util.ConvertQuantityToShares(contract.DeliverableQuantity),
+		Status:             contract.Status,
+		UnderlyingDollars:  util.ConvertCentsToDollars(stock.CurrentPriceCents),
+		ImpliedVolatility:  stock.ImpliedVolatility,
+		MarkDollars:        util.ConvertCentsToDollars(quote.MarkCents),
+		BidDollars:         util.ConvertCentsToDollars(quote.BidCents),
+		AskDollars:         util.ConvertCentsToDollars(quote.AskCents),
+		Greeks:             getOptionGreeksModel(quote.Greeks, 1),
+	}
+}
+
+// getOptionGreeksModel scales the Greeks of one share of the underlying to a number of shares.
+func getOptionGreeksModel(greeks util.OptionGreeks, shares float64) model.OptionGreeksModel {
+	return model.OptionGreeksModel{
+		Delta: greeks.Delta * shares,
+		Gamma: greeks.Gamma * shares,
+		Theta: greeks.Theta * shares,
+		Vega:  greeks.Vega * shares,
+		Rho:   greeks.Rho * shares,
+	}
+}
+
+// PlaceOptionOrder buys contracts, or sells contracts held, during the regular session. Market orders fill at the
+// quote, limit orders fill once the quote reaches their limit and expire at the regular close.
+func PlaceOptionOrder(request model.OptionOrderRequest) error {
+
+	if session := GetCurrentMarketSession(); session != util.MarketSessionRegular {
+		return errors.New("option orders are only accepted during the REGULAR session, the market is " + session)
+	}
+
+	if request.Quantity <= 0 {
+		return errors.New("quantity must be at least 1 contract")
+	}
+
+	if request.TradeType != util.TradeTypeBuy && request.TradeType != util.TradeTypeSell {
+		return errors.New("trade type must be BUY or SELL")
+	}
+
+	switch request.OrderType {
+	case "", util.OrderTypeMarket:
+		request.OrderType = util.OrderTypeMarket
+		request.LimitPriceCents = 0
+	case util.OrderTypeLimit:
+		if request.LimitPriceCents <= 0 {
+			return errors.New("limit price must be greater than 0")
+		}
+	default:
+		return errors.New("option orders must be MARKET or LIMIT")
+	}
+
+	now := time.Now()
+
+	contract := db.GetOptionContractById(request.OptionContractID)
+	if contract.OptionContractID == 0 || contract.Status != util.OptionContractStatusActive || !contract.ExpiresAt.After(now) {
+		return errors.New("option contract not found or expired")
+	}
+
+	//contracts are only sold to close, writing options is not supported
+	if request.TradeType == util.TradeTypeSell {
+		holding := db.GetOptionHoldingByUserIdAndContractIdTx(db.DB, request.UserID, contract.OptionContractID)
+		available := holding.Quantity - db.GetPendingOptionSellQuantityByUserIdAndContractId(request.UserID, contract.OptionContractID)
+		if request.Quantity > available {
+			return fmt.Errorf("only long option positions can be sold, %d contracts of %s are available", max(available, 0), contract.Symbol)
+		}
+	}
+
+	order := orm.OptionOrders{
+		UserID:           request.UserID,
+		OptionContractID: contract.OptionContractID,
+		TradeType:        request.TradeType,
+		OrderType:        request.OrderType,
+		OrderStatus:      util.OrderStatusPending,
+		Quantity:         request.Quantity,
+		LimitPriceCents:  request.LimitPriceCents,
+		CreatedAt:        now,
+	}
+
+	quote := getOptionQuote(contract, db.GetStockById(contract.StockID), now)
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+
+		if err := tx.Create(&order).Error; err != nil {
+			fmt.Println("Failed to save option order:", err)
+			return errors.New("failed to save option order")
+		}
+
+		premiumCents, marketable := getOptionFillPriceCents(order, quote)
+		if !marketable {
+			return nil
+		}
+
+		return fillOptionOrder(tx, order, contract, premiumCents, now)
+	})
+}
+
+// getOptionFillPriceCents returns the premium an option order fills at, buys at the ask and sells at the bid, and
+// whether the quote reaches its limit price.
+func getOptionFillPriceCents(order orm.OptionOrders, quote optionQuote) (int64, bool) {
+	if order.TradeType == util.TradeTypeBuy {
+		return quote.AskCents, order.OrderType == util.OrderTypeMarket || quote.AskCents <= order.LimitPriceCents
+	}
+	return quote.BidCents, order.OrderType == util.OrderTypeMarket || quote.BidCents >= order.LimitPriceCents
+}
+
+// fillOptionOrder executes a pending option order at premiumCents per share of the underlying, in the underlying's
+// currency. Long options carry no margin, so a buy is paid for, premium and fee, out of the excess equity.
+func fillOptionOrder(tx *gorm.DB, order orm.OptionOrders, contract orm.OptionContracts, premiumCents int64, now time.Time) error {
+
+	user := db.GetUserByIdTx(tx, order.UserID)
+	if user.UserID == 0 {
+		return errors.New("user does not exist")
+	}
+
+	stocksById := getStocksById()
+	currency := getStockCurrency(stocksById[contract.StockID])
+	valueCents := getOptionValueCents(contract, order.Quantity, premiumCents)
+	usdValueCents := toUsdCents(getFxRates(), currency, valueCents)
+	//charged like a share per contract, in USD
+	feeCents := getFeeCents(getFeeSchedule(user), order.TradeType, order.Quantity*util.ShareScale, usdValueCents)
+
+	holding := db.GetOptionHoldingByUserIdAndContractIdTx(tx, user.UserID, contract.OptionContractID)
+	if holding.OptionHoldingID == 0 {
+		holding = orm.OptionHoldings{
+			UserID:           user.UserID,
+			OptionContractID: contract.OptionContractID,
+			CreatedAt:        now,
+		}
+	}
+
+	premiumFlowCents := valueCents
+	if order.TradeType == util.TradeTypeBuy {
+		premiumFlowCents = -valueCents
+		if usdValueCents+feeCents > getMarginAccount(tx, user, stocksById).getExcessEquityCents() {
+			return errors.New("user don't have enough buying power")
+		}
+		holding.AverageCostPerShareCents = int64(math.Round(float64(holding.AverageCostPerShareCents*holding.Quantity+premiumCents*order.Quantity) /
+			float64(holding.Quantity+order.Quantity)))
+		holding.Quantity += order.Quantity
+	} else {
+		if holding.Quantity < order.Quantity {
+			return fmt.Errorf("only long option positions can be sold, %d contracts of %s are held", holding.Quantity, contract.Symbol)
+		}
+		holding.Quantity -= order.Quantity
+	}
+	holding.UpdatedAt = now
+
+	//the status check skips orders canceled or filled since they were loaded
+	result := tx.Model(&orm.OptionOrders{}).
+		Where("option_order_id = ? and order_status = ?", order.OptionOrderID, util.OrderStatusPending).
+		Updates(map[string]interface{}{
+			"order_status":            util.OrderStatusExecuted,
+			"price_per_share_cents":   premiumCents,
+			"total_order_value_cents": valueCents,
+			"fee_cents":               feeCents,
+			"executed_at":             now,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	if err := tx.Save(&holding).Error; err != nil {
+		fmt.Println("Failed to save option holding:", err)
+		return errors.New("failed to save option holding")
+	}
+
+	if err := adjustCash(tx, user.UserID, currency, premiumFlowCents, now); err != nil {
+		return err
+	}
+	if err := adjustCash(tx, user.UserID, util.CurrencyUsd, -feeCents, now); err != nil {
+		return err
+	}
+
+	entries := []orm.JournalEntries{
+		newCashEntry(util.LedgerAccountUserCash, currency, premiumFlowCents),
+		newCashEntry(util.LedgerAccountMarket, currency, -premiumFlowCents),
+	}
+	if feeCents > 0 {
+		entries = append(entries,
+			newCashEntry(util.LedgerAccountFees, util.CurrencyUsd, feeCents),
+			newCashEntry(util.LedgerAccountUserCash, util.CurrencyUsd, -feeCents))
+	}
+
+	journal := orm.Journals{
+		UserID:      user.UserID,
+		EventType:   util.JournalEventOptionTrade,
+		Description: fmt.Sprintf("%s %d %s at %.2f", order.TradeType, order.Quantity, contract.Symbol, util.ConvertCentsToDollars(premiumCents)),
+		CreatedAt:   now,
+	}
+
+	return postJournal(tx, journal, entries)
+}
+
+// ProcessOptionOrders fills the pending option orders the current quotes reach, an order the account can no longer
+// pay for, or whose contracts are no longer held, fails.
+func ProcessOptionOrders(now time.Time) {
+
+	orders := db.GetPendingOptionOrders()
+	if len(orders) == 0 {
+		return
+	}
+
+	contractIds := make([]int64, len(orders))
+	for i, order := range orders {
+		contractIds[i] = order.OptionContractID
+	}
+	contractsById := make(map[int64]orm.OptionContracts)
+	for _, contract := range db.GetOptionContractsByIds(contractIds) {
+		contractsById[contract.OptionContractID] = contract
+	}
+	stocksById := getStocksById()
+
+	for _, order := range orders {
+
+		contract := contractsById[order.OptionContractID]
+		if contract.Status != util.OptionContractStatusActive {
+			continue
+		}
+
+		premiumCents, marketable := getOptionFillPriceCents(order, getOptionQuote(contract, stocksById[contract.StockID], now))
+		if !marketable {
+			continue
+		}
+
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			return fillOptionOrder(tx, order, contract, premiumCents, now)
+		})
+		if err != nil {
+			db.DB.Model(&orm.OptionOrders{}).
+				Where("option_order_id = ? and order_status = ?", order.OptionOrderID, util.OrderStatusPending).
+				Updates(map[string]interface{}{
+					"order_status": util.OrderStatusFailed,
+					"notes":        err.Error() + " at fill time",
+				})
+		}
+	}
+}
+
+func CancelOptionOrder(userId int64, optionOrderId int64) error {
+
+	order := db.GetOptionOrderById(optionOrderId)
+	if order.OptionOrderID == 0 || order.UserID != userId {
+		return errors.New("option order not found")
+	}
+
+	//the status check guards against the price routine filling the order meanwhile
+	result := db.DB.Model(&orm.OptionOrders{}).
+		Where("option_order_id = ? and order_status = ?", optionOrderId, util.OrderStatusPending).
+		Update("order_status", util.OrderStatusCanceled)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("only pending option orders can be canceled")
+	}
+
+	return nil
+}
+
+func GetOptionOrders(userId int64) []model.OptionOrderModel {
+
+	orders := db.GetOptionOrdersByUserId(userId)
+
+	contractIds := make([]int64, len(orders))
+	for i, order := range orders {
+		contractIds[i] = order.OptionContractID
+	}
+	symbols := make(map[int64]string)
+	for _, contract := range db.GetOptionContractsByIds(contractIds) {
+		symbols[contract.OptionContractID] = contract.Symbol
+	}
+
+	orderModels := make([]model.OptionOrderModel, len(orders))
+	for i, order := range orders {
+		orderModels[i] = model.OptionOrderModel{
+			OptionOrderID:          order.OptionOrderID,
+			Symbol:                 symbols[order.OptionContractID],
+			TradeType:              order.TradeType,
+			OrderType:              order.OrderType,
+			OrderStatus:            order.OrderStatus,
+			Quantity:               order.Quantity,
+			LimitPriceDollars:      util.ConvertCentsToDollars(order.LimitPriceCents),
+			PricePerShareDollars:   util.ConvertCentsToDollars(order.PricePerShareCents),
+			TotalOrderValueDollars: util.ConvertCentsToDollars(order.TotalOrderValueCents),
+			FeeDollars:             util.ConvertCentsToDollars(order.FeeCents),
+			CreatedAt:              util.GetDateTimeString(order.CreatedAt),
+			Notes:                  order.Notes,
+		}
+		if order.ExecutedAt != nil {
+			orderModels[i].ExecutedAt = util.GetDateTimeString(*order.ExecutedAt)
+		}
+	}
+
+	return orderModels
+}
+
+// getOptionHoldingModels values the user's option positions at the mark, and returns the Greeks of all the positions
+// together and their total value in USD.
+func getOptionHoldingModels(userId int64, stocksById map[int64]orm.Stocks, now time.Time) ([]model.OptionHoldingModel, model.OptionGreeksModel, int64) {
+
+	holdings := db.GetActiveOptionHoldingsByUserId(userId)
+
+	contractIds := make([]int64, len(holdings))
+	for i, holding := range holdings {
+		contractIds[i] = holding.OptionContractID
+	}
+	contractsById := make(map[int64]orm.OptionContracts)
+	for _, contract := range db.GetOptionContractsByIds(contractIds) {
+		contractsById[contract.OptionContractID] = contract
+	}
+
+	fxRates := getFxRates()
+	holdingModels := make([]model.OptionHoldingModel, len(holdings))
+	var totalGreeks model.OptionGreeksModel
+	var totalValueCents int64
+
+	for i, holding := range holdings {
+
+		contract := contractsById[holding.OptionContractID]
+		stock := stocksById[contract.StockID]
+		quote := getOptionQuote(contract, stock, now)
+
+		valueCents := getOptionValueCents(contract, holding.Quantity, quote.MarkCents)
+		costCents := getOptionValueCents(contract, holding.Quantity, holding.AverageCostPerShareCents)
+		greeks := getOptionGreeksModel(quote.Greeks, float64(holding.Quantity)*util.ConvertQuantityToShares(contract.DeliverableQuantity))
+
+		holdingModels[i] = model.OptionHoldingModel{
+			OptionHoldingID:            holding.OptionHoldingID,
+			Contract:                   getOptionContractModel(contract, stock, now),
+			Quantity:                   holding.Quantity,
+			AverageCostPerShareDollars: util.ConvertCentsToDollars(holding.AverageCostPerShareCents),
+			TotalValueDollars:          util.ConvertCentsToDollars(valueCents),
+			PnLDollars:                 util.ConvertCentsToDollars(valueCents - costCents),
+			Greeks:                     greeks,
+			UpdatedAt:                  util.GetDateTimeString(holding.UpdatedAt),
+		}
+		if costCents > 0 {
+			holdingModels[i].PnLPercent = float64(valueCents-costCents) / float64(costCents) * 100
+		}
+
+		totalGreeks.Delta += greeks.Delta
+		totalGreeks.Gamma += greeks.Gamma
+		totalGreeks.Theta += greeks.Theta
+		totalGreeks.Vega += greeks.Vega
+		totalGreeks.Rho += greeks.Rho
+		totalValueCents += toUsdCents(fxRates, getStockCurrency(stock), valueCents)
+	}
+
+	return holdingModels, totalGreeks, totalValueCents
+}
+
+// ExpireOptionContracts runs at the regular close: the pending option orders expire, and the contracts past their
+// expiration are settled at the current stock price. Long positions in the money are exercised at the strike, calls
+// buy and puts sell the shares delivered, the others expire worthless. It returns the notifications for the holders.
+func ExpireOptionContracts(now time.Time) []model.NotificationModel {
+
+	result := db.DB.Model(&orm.OptionOrders{}).
+		Where("order_status = ?", util.OrderStatusPending).
+		Updates(map[string]interface{}{
+			"order_status": util.OrderStatusExpired,
+			"notes":        "expired at the regular session close",
+		})
+	if result.Error != nil {
+		fmt.Println("Failed to expire option orders, " + result.Error.Error())
+	}
+
+	notifications := make([]model.NotificationModel, 0)
+	stocksById := getStocksById()
+
+	for _, contract := range db.GetDueOptionContracts(now) {
+
+		var contractNotifications []model.NotificationModel
+
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+
+			//moving the status first skips contracts already settled by a concurrent run
+			result := tx.Model(&orm.OptionContracts{}).
+				Where("option_contract_id = ? and status = ?", contract.OptionContractID, util.OptionContractStatusActive).
+				Update("status", util.OptionContractStatusExpired)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			var err error
+			contractNotifications, err = settleOptionContract(tx, contract, stocksById[contract.StockID], now)
+			return err
+		})
+
+		if err != nil {
+			fmt.Printf("Failed to settle option contract %s, %s\n", contract.Symbol, err.Error())
+			continue
+		}
+
+		notifications = append(notifications, contractNotifications...)
+	}
+
+	return notifications
+}
+
+// settleOptionContract exercises or expires the long positions of an expired contract.
+func settleOptionContract(tx *gorm.DB, contract orm.OptionContracts, stock orm.Stocks, now time.Time) ([]model.NotificationModel, error) {
+
+	tradeType := util.TradeTypeBuy
+	intrinsicCents := stock.CurrentPriceCents - contract.StrikePriceCents
+	if contract.OptionType == util.OptionTypePut {
+		tradeType = util.TradeTypeSell
+		intrinsicCents = -intrinsicCents
+	}
+
+	notifications := make([]model.NotificationModel, 0)
+
+	for _, holding := range db.GetActiveOptionHoldingsByContractIdTx(tx, contract.OptionContractID) {
+
+		notification := model.NotificationModel{
+			UserID:    holding.UserID,
+			EventType: util.NotificationEventOptionExpired,
+			Message: fmt.Sprintf("%d %s contracts expired worthless, %s closed at $%.2f", holding.Quantity, contract.Symbol,
+				stock.Ticker, util.ConvertCentsToDollars(stock.CurrentPriceCents)),
+		}
+
+		if intrinsicCents > 0 {
+			quantity := holding.Quantity * contract.DeliverableQuantity
+
+			//filled directly at the strike, the exercise does not go through the order book
+			order := newMarketOrder()
+			order.UserID = holding.UserID
+			order.StockID = stock.StockID
+			order.TradeType = tradeType
+			order.OrderStatus = util.OrderStatusPending
+			order.Quantity = quantity
+			order.PricePerShareCents = contract.StrikePriceCents
+			order.TotalOrderValueCents = util.GetValueCents(quantity, contract.StrikePriceCents)
+			order.CreatedAt = now
+			order.Notes = "exercise of " + contract.Symbol
+
+			if err := tx.Create(&order).Error; err != nil {
+				fmt.Println("Failed to save order:", err)
+				return nil, errors.New("failed to save order")
+			}
+
+			_, filled, err := fillOrderQuantity(tx, order, stock, quantity, contract.StrikePriceCents, nil)
+			if err != nil {
+				return nil, err
+			}
+			if !filled {
+				return nil, errors.New("failed to fill exercise order")
+			}
+
+			verb := "bought"
+			if tradeType == util.TradeTypeSell {
+				verb = "sold"
+			}
+			notification.EventType = util.NotificationEventOptionExercised
+			notification.OrderID = order.OrderID
+			notification.Message = fmt.Sprintf("%d %s contracts were exercised, %s %g shares of %s at $%.2f", holding.Quantity,
+				contract.Symbol, verb, util.ConvertQuantityToShares(quantity), stock.Ticker, util.ConvertCentsToDollars(contract.StrikePriceCents))
+		}
+
+		err := tx.Model(&orm.OptionHoldings{}).Where("option_holding_id = ?", holding.OptionHoldingID).
+			Updates(map[string]interface{}{
+				"quantity":   0,
+				"updated_at": now,
+			}).Error
+		if err != nil {
+			return nil, err
+		}
+
+		if err := createNotification(tx, &notification, now); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+// splitOptionContracts adjusts the stock's active contracts to a split so positions keep their value: the strike and
+// the premiums per share are divided by the ratio and a contract delivers the split shares. Adjusted contracts get the
+// corporate action id in their symbol root, leaving the plain symbols to the strikes listed after the split.
+func splitOptionContracts(tx *gorm.DB, corporateAction orm.CorporateActions, stock orm.Stocks) error {
+
+	root := fmt.Sprintf("%s%d", stock.Ticker, corporateAction.CorporateActionID)
+
+	for _, contract := range db.GetActiveOptionContractsByStockIdTx(tx, stock.StockID) {
+
+		strikePriceCents := splitPriceCents(corporateAction, contract.StrikePriceCents)
+		deliverableQuantity, _ := splitQuantity(corporateAction, contract.DeliverableQuantity)
+
+		err := tx.Model(&orm.OptionContracts{}).Where("option_contract_id = ?", contract.OptionContractID).
+			Updates(map[string]interface{}{
+				"symbol":               getOptionSymbol(root, contract.OptionType, contract.ExpiresAt, strikePriceCents),
+				"strike_price_cents":   strikePriceCents,
+				"deliverable_quantity": deliverableQuantity,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&orm.OptionHoldings{}).Where("option_contract_id = ?", contract.OptionContractID).
+			Update("average_cost_per_share_cents", gorm.Expr("round(average_cost_per_share_cents * ?::numeric / ?)",
+				corporateAction.SplitFrom, corporateAction.SplitTo)).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&orm.OptionOrders{}).
+			Where("option_contract_id = ? and order_status = ?", contract.OptionContractID, util.OrderStatusPending).
+			Update("limit_price_cents", gorm.Expr("round(limit_price_cents * ?::numeric / ?)",
+				corporateAction.SplitFrom, corporateAction.SplitTo)).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
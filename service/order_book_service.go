@@ -0,0 +1,410 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+
+	"gorm.io/gorm"
+)
+
+// bookOrder is a pending limit order resting in a stock's order book.
+type bookOrder struct {
+	OrderID         int64
+	UserID          int64
+	LimitPriceCents int64
+	Quantity        int64
+	CreatedAt       time.Time
+}
+
+// orderBook holds the pending limit orders of one stock in price-time priority, bids highest price first
+// and asks lowest price first, the oldest order first within a price. A book is never modified in place,
+// every change replaces it so the copies handed out stay consistent.
+type orderBook struct {
+	Bids []bookOrder
+	Asks []bookOrder
+}
+
+var (
+	orderBooks      = make(map[int64]*orderBook)
+	orderBooksMutex sync.Mutex
+)
+
+// bookChanges holds the orders changed by each open bookTransaction, keyed by its connection, they are applied
+// to the books once the transaction commits.
+var (
+	bookChanges      = make(map[gorm.ConnPool]map[int64]bool)
+	bookChangesMutex sync.Mutex
+)
+
+// InitOrderBooks loads the book of every stock from its pending limit orders, called on startup. The books are
+// then kept up to date by the transactions changing their orders.
+func InitOrderBooks() {
+	for _, stock := range db.GetAllStocks() {
+		loadOrderBook(stock.StockID)
+	}
+}
+
+// loadOrderBook builds the stock's book from the database, for changes to all its orders like a split.
+func loadOrderBook(stockId int64) {
+
+	orderBooksMutex.Lock()
+	defer orderBooksMutex.Unlock()
+
+	book := &orderBook{
+		Bids: make([]bookOrder, 0),
+		Asks: make([]bookOrder, 0),
+	}
+
+	for _, order := range db.GetPendingLimitOrdersByStockId(stockId) {
+		if order.TradeType == util.TradeTypeBuy {
+			book.Bids = append(book.Bids, newBookOrder(order))
+		} else {
+			book.Asks = append(book.Asks, newBookOrder(order))
+		}
+	}
+
+	sort.SliceStable(book.Bids, func(i, j int) bool {
+		return isBidBefore(book.Bids[i], book.Bids[j])
+	})
+	sort.SliceStable(book.Asks, func(i, j int) bool {
+		return isAskBefore(book.Asks[i], book.Asks[j])
+	})
+
+	orderBooks[stockId] = book
+}
+
+// bookTransaction runs fc in a transaction like db.DB.Transaction and, once it commits, applies the orders it
+// recorded with recordBookChange to the books.
+func bookTransaction(fc func(tx *gorm.DB) error) error {
+
+	var connPool gorm.ConnPool
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		connPool = tx.Statement.ConnPool
+		bookChangesMutex.Lock()
+		bookChanges[connPool] = make(map[int64]bool)
+		bookChangesMutex.Unlock()
+		return fc(tx)
+	})
+
+	bookChangesMutex.Lock()
+	orderIds := make([]int64, 0, len(bookChanges[connPool]))
+	for orderId := range bookChanges[connPool] {
+		orderIds = append(orderIds, orderId)
+	}
+	delete(bookChanges, connPool)
+	bookChangesMutex.Unlock()
+
+	if err == nil {
+		applyBookChanges(orderIds)
+	}
+
+	return err
+}
+
+// recordBookChange notes orders tx changed that may enter, move in or leave their book. Changes made outside a
+// bookTransaction are not recorded.
+func recordBookChange(tx *gorm.DB, orderIds ...int64) {
+	bookChangesMutex.Lock()
+	defer bookChangesMutex.Unlock()
+
+	if changes, ok := bookChanges[tx.Statement.ConnPool]; ok {
+		for _, orderId := range orderIds {
+			changes[orderId] = true
+		}
+	}
+}
+
+// applyBookChanges reads the committed orders and moves them in, within or out of their books.
+func applyBookChanges(orderIds []int64) {
+
+	if len(orderIds) == 0 {
+		return
+	}
+
+	//read under the lock, so the changes of concurrent transactions are applied in the order they were read
+	orderBooksMutex.Lock()
+	defer orderBooksMutex.Unlock()
+
+	for _, order := range db.GetOrdersByIds(orderIds) {
+		book := orderBooks[order.StockID]
+		if book == nil {
+			book = &orderBook{Bids: make([]bookOrder, 0), Asks: make([]bookOrder, 0)}
+		}
+
+		changed := &orderBook{
+			Bids: removeBookOrder(book.Bids, order.OrderID),
+			Asks: removeBookOrder(book.Asks, order.OrderID),
+		}
+		if isOrderFillable(order) && order.OrderType == util.OrderTypeLimit {
+			if order.TradeType == util.TradeTypeBuy {
+				changed.Bids = insertBookOrder(changed.Bids, newBookOrder(order), isBidBefore)
+			} else {
+				changed.Asks = insertBookOrder(changed.Asks, newBookOrder(order), isAskBefore)
+			}
+		}
+
+		orderBooks[order.StockID] = changed
+	}
+}
+
+func newBookOrder(order orm.Orders) bookOrder {
+	return bookOrder{
+		OrderID:         order.OrderID,
+		UserID:          order.UserID,
+		LimitPriceCents: order.LimitPriceCents,
+		Quantity:        order.Quantity - order.FilledQuantity,
+		CreatedAt:       order.CreatedAt,
+	}
+}
+
+// removeBookOrder returns a copy of orders without orderId, or orders itself if it is not there.
+func removeBookOrder(orders []bookOrder, orderId int64) []bookOrder {
+	for i, order := range orders {
+		if order.OrderID == orderId {
+			return append(append(make([]bookOrder, 0, len(orders)-1), orders[:i]...), orders[i+1:]...)
+		}
+	}
+	return orders
+}
+
+// insertBookOrder returns a copy of orders with entry at its priority.
+func insertBookOrder(orders []bookOrder, entry bookOrder, isBefore func(a bookOrder, b bookOrder) bool) []bookOrder {
+	i := sort.Search(len(orders), func(i int) bool {
+		return isBefore(entry, orders[i])
+	})
+	inserted := make([]bookOrder, 0, len(orders)+1)
+	inserted = append(inserted, orders[:i]...)
+	inserted = append(inserted, entry)
+	return append(inserted, orders[i:]...)
+}
+
+func getOrderBook(stockId int64) orderBook {
+	orderBooksMutex.Lock()
+	defer orderBooksMutex.Unlock()
+
+	if book, ok := orderBooks[stockId]; ok {
+		return *book
+	}
+	return orderBook{}
+}
+
+func isBidBefore(a bookOrder, b bookOrder) bool {
+	if a.LimitPriceCents != b.LimitPriceCents {
+		return a.LimitPriceCents > b.LimitPriceCents
+	}
+	return isOlderBookOrder(a, b)
+}
+
+func isAskBefore(a bookOrder, b bookOrder) bool {
+	if a.LimitPriceCents != b.LimitPriceCents {
+		return a.LimitPriceCents < b.LimitPriceCents
+	}
+	return isOlderBookOrder(a, b)
+}
+
+func isOlderBookOrder(a bookOrder, b bookOrder) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.OrderID < b.OrderID
+}
+
+// getRestingOrders is the side of the book an incoming order of tradeType trades against.
+func (book orderBook) getRestingOrders(tradeType string) []bookOrder {
+	if tradeType == util.TradeTypeBuy {
+		return book.Asks
+	}
+	return book.Bids
+}
+
+// isPriceWithin reports whether a resting order's price is at or better than priceLimitCents for an incoming order of tradeType.
+func isPriceWithin(tradeType string, restingPriceCents int64, priceLimitCents int64) bool {
+	if tradeType == util.TradeTypeBuy {
+		return restingPriceCents <= priceLimitCents
+	}
+	return restingPriceCents >= priceLimitCents
+}
+
+// getBookQuantity is the quantity other users' resting orders offer to an incoming order at priceLimitCents or better.
+func getBookQuantity(stockId int64, userId int64, tradeType string, priceLimitCents int64) int64 {
+
+	var quantity int64
+	for _, resting := range getOrderBook(stockId).getRestingOrders(tradeType) {
+		if !isPriceWithin(tradeType, resting.LimitPriceCents, priceLimitCents) {
+			break
+		}
+		if resting.UserID != userId {
+			quantity += resting.Quantity
+		}
+	}
+	return quantity
+}
+
+// takeBookLiquidity fills a saved incoming order against the resting orders of other users priced at priceLimitCents
+// or better, best price first, each at the resting order's limit price. It returns the order with its fills.
+func takeBookLiquidity(tx *gorm.DB, order orm.Orders, stock orm.Stocks, priceLimitCents int64) (orm.Orders, error) {
+
+	for _, resting := range getOrderBook(stock.StockID).getRestingOrders(order.TradeType) {
+		remainingQuantity := order.Quantity - order.FilledQuantity
+		if remainingQuantity == 0 || !isPriceWithin(order.TradeType, resting.LimitPriceCents, priceLimitCents) {
+			break
+		}
+		if resting.UserID == order.UserID {
+			continue
+		}
+
+		restingOrder := db.GetOrderByIdTx(tx, resting.OrderID)
+		if !isOrderFillable(restingOrder) || restingOrder.OrderType != util.OrderTypeLimit {
+			//changed since the book was read, it is read again once this transaction commits
+			recordBookChange(tx, resting.OrderID)
+			continue
+		}
+
+		matchQuantity := min(remainingQuantity, restingOrder.Quantity-restingOrder.FilledQuantity)
+		matchPriceCents := restingOrder.LimitPriceCents
+
+		restingUser := db.GetUserByIdTx(tx, restingOrder.UserID)
+		if checkBuyingPower(tx, restingUser, stock, restingOrder.TradeType, matchQuantity, matchPriceCents, &restingOrder) != nil {
+			//left for the price routine to fail
+			continue
+		}
+
+		_, filled, err := fillOrderQuantity(tx, restingOrder, stock, matchQuantity, matchPriceCents, &order.OrderID)
+		if err != nil {
+			return order, err
+		}
+		if !filled {
+			continue
+		}
+
+		order, filled, err = fillOrderQuantity(tx, order, stock, matchQuantity, matchPriceCents, &restingOrder.OrderID)
+		if err != nil {
+			return order, err
+		}
+		if !filled {
+			return order, fmt.Errorf("order %d changed while matching", order.OrderID)
+		}
+	}
+
+	return order, nil
+}
+
+// MatchOrderBook matches the stock's resting orders that cross, which happens when a stop-limit triggers, a bracket
+// exit activates or an order is amended. The older order of each pair sets the price and orders of the same user
+// are never matched against each other.
+func MatchOrderBook(stock orm.Stocks) {
+
+	for {
+		bid, ask, ok := getCrossingOrders(getOrderBook(stock.StockID))
+		if !ok {
+			return
+		}
+
+		if err := matchBookOrders(stock, bid, ask); err != nil {
+			fmt.Printf("Failed to match orders %d and %d, %s\n", bid.OrderID, ask.OrderID, err.Error())
+			//read again in case they were changed by a transaction still open when the book was read
+			applyBookChanges([]int64{bid.OrderID, ask.OrderID})
+			return
+		}
+	}
+}
+
+// getCrossingOrders returns the highest priority bid and ask of different users whose prices overlap.
+func getCrossingOrders(book orderBook) (bookOrder, bookOrder, bool) {
+	for _, bid := range book.Bids {
+		for _, ask := range book.Asks {
+			if ask.LimitPriceCents > bid.LimitPriceCents {
+				break
+			}
+			if ask.UserID != bid.UserID {
+				return bid, ask, true
+			}
+		}
+	}
+	return bookOrder{}, bookOrder{}, false
+}
+
+func matchBookOrders(stock orm.Stocks, bid bookOrder, ask bookOrder) error {
+
+	return bookTransaction(func(tx *gorm.DB) error {
+
+		bidOrder := db.GetOrderByIdTx(tx, bid.OrderID)
+		askOrder := db.GetOrderByIdTx(tx, ask.OrderID)
+		if !isOrderFillable(bidOrder) || !isOrderFillable(askOrder) {
+			return errors.New("order is no longer pending")
+		}
+
+		matchQuantity := min(bidOrder.Quantity-bidOrder.FilledQuantity, askOrder.Quantity-askOrder.FilledQuantity)
+		matchPriceCents := askOrder.LimitPriceCents
+		if isOlderBookOrder(bid, ask) {
+			matchPriceCents = bidOrder.LimitPriceCents
+		}
+
+		//an order without the buying power for the match fails, the other one keeps resting
+		for _, order := range []orm.Orders{bidOrder, askOrder} {
+			user := db.GetUserByIdTx(tx, order.UserID)
+			if err := checkBuyingPower(tx, user, stock, order.TradeType, matchQuantity, matchPriceCents, &order); err != nil {
+				return failOrder(tx, order, err.Error()+" at fill time")
+			}
+		}
+
+		for _, match := range [][2]orm.Orders{{bidOrder, askOrder}, {askOrder, bidOrder}} {
+			_, filled, err := fillOrderQuantity(tx, match[0], stock, matchQuantity, matchPriceCents, &match[1].OrderID)
+			if err != nil {
+				return err
+			}
+			if !filled {
+				return fmt.Errorf("order %d changed while matching", match[0].OrderID)
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetOrderBookDepth aggregates the stock's book by price, up to levels prices per side.
+func GetOrderBookDepth(stockId int64, levels int) model.OrderBookModel {
+
+	book := getOrderBook(stockId)
+
+	return model.OrderBookModel{
+		StockID: stockId,
+		Bids:    getDepthLevels(book.Bids, levels),
+		Asks:    getDepthLevels(book.Asks, levels),
+	}
+}
+
+func getDepthLevels(orders []bookOrder, levels int) []model.OrderBookLevelModel {
+
+	depthLevels := make([]model.OrderBookLevelModel, 0)
+
+	for _, order := range orders {
+		priceDollars := util.ConvertCentsToDollars(order.LimitPriceCents)
+		last := len(depthLevels) - 1
+
+		if last >= 0 && depthLevels[last].PriceDollars == priceDollars {
+			depthLevels[last].Quantity = util.ConvertQuantityToShares(util.ConvertSharesToQuantity(depthLevels[last].Quantity) + order.Quantity)
+			depthLevels[last].Orders++
+			continue
+		}
+		if len(depthLevels) == levels {
+			break
+		}
+
+		depthLevels = append(depthLevels, model.OrderBookLevelModel{
+			PriceDollars: priceDollars,
+			Quantity:     util.ConvertQuantityToShares(order.Quantity),
+			Orders:       1,
+		})
+	}
+
+	return depthLevels
+}
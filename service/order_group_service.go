@@ -0,0 +1,301 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+
+	"gorm.io/gorm"
+)
+
+// PlaceBracketOrder places a market or limit entry order with an attached take-profit limit order and
+// stop-loss stop order on the opposite side. The two exit orders stay INACTIVE until the entry fills,
+// then behave as an OCO pair.
+func PlaceBracketOrder(entryRequest model.OrderRequest, takeProfitPriceCents int64, stopLossPriceCents int64) error {
+
+	if entryRequest.OrderType == "" {
+		entryRequest.OrderType = util.OrderTypeMarket
+	}
+
+	//the entry can fill right away, so bracket orders are not queued outside the sessions accepting it
+	if session := GetCurrentMarketSession(); !isOrderTypeAccepted(session, entryRequest.OrderType) {
+		return errors.New("bracket orders with a " + entryRequest.OrderType + " entry are not accepted during the " + session + " session")
+	}
+
+	//the exits only ever reduce the entry position, so only the entry is risk checked
+	if err := checkOrderRisk(entryRequest); err != nil {
+		return err
+	}
+
+	err := bookTransaction(func(tx *gorm.DB) error {
+
+		if entryRequest.OrderType != util.OrderTypeMarket && entryRequest.OrderType != util.OrderTypeLimit {
+			return errors.New("entry order must be a market or limit order")
+		}
+
+		if entryRequest.TimeInForce == "" {
+			entryRequest.TimeInForce = util.TimeInForceDay
+		}
+		if entryRequest.TimeInForce != util.TimeInForceDay && entryRequest.TimeInForce != util.TimeInForceGTC {
+			return errors.New("bracket orders support DAY and GTC only")
+		}
+
+		if takeProfitPriceCents <= 0 || stopLossPriceCents <= 0 {
+			return errors.New("take profit and stop loss prices must be greater than 0")
+		}
+
+		stock := db.GetStockByTicker(entryRequest.Ticker)
+		if stock.StockID == 0 {
+			return errors.New("stock " + entryRequest.Ticker + " not found")
+		}
+
+		user := db.GetUserByIdTx(tx, entryRequest.UserID)
+		if user.UserID == 0 {
+			return errors.New("user does not exist")
+		}
+
+		//market entries are priced at the quote they fill at, with slippage
+		entryPriceCents := getFillPriceCents(stock, entryRequest.TradeType, entryRequest.Quantity, 0)
+		if entryRequest.OrderType == util.OrderTypeLimit {
+			if entryRequest.LimitPriceCents <= 0 {
+				return errors.New("limit price must be greater than 0")
+			}
+			entryPriceCents = entryRequest.LimitPriceCents
+		}
+
+		exitTradeType := util.TradeTypeSell
+		if entryRequest.TradeType == util.TradeTypeBuy {
+			if takeProfitPriceCents <= entryPriceCents || stopLossPriceCents >= entryPriceCents {
+				return errors.New("take profit must be above and stop loss below the entry price")
+			}
+		} else {
+			exitTradeType = util.TradeTypeBuy
+			if takeProfitPriceCents >= entryPriceCents || stopLossPriceCents <= entryPriceCents {
+				return errors.New("take profit must be below and stop loss above the entry price")
+			}
+		}
+
+		err := checkBuyingPower(tx, user, stock, entryRequest.TradeType, entryRequest.Quantity, entryPriceCents, nil)
+		if err != nil {
+			return err
+		}
+
+		group := orm.OrderGroups{
+			UserID:    user.UserID,
+			GroupType: util.OrderGroupTypeBracket,
+			CreatedAt: time.Now(),
+		}
+		if err := tx.Create(&group).Error; err != nil {
+			fmt.Println("Failed to save order group:", err)
+			return errors.New("failed to save order group")
+		}
+
+		parent := orm.Orders{
+			UserID:               user.UserID,
+			StockID:              stock.StockID,
+			TradeType:            entryRequest.TradeType,
+			OrderType:            entryRequest.OrderType,
+			TimeInForce:          entryRequest.TimeInForce,
+			OrderStatus:          util.OrderStatusPending,
+			Quantity:             entryRequest.Quantity,
+			LimitPriceCents:      entryRequest.LimitPriceCents,
+			PricePerShareCents:   entryPriceCents,
+			TotalOrderValueCents: util.GetValueCents(entryRequest.Quantity, entryPriceCents),
+			CreatedAt:            time.Now(),
+			OrderGroupID:         &group.OrderGroupID,
+		}
+		if err := savePlacedOrder(tx, &parent); err != nil {
+			return err
+		}
+
+		childRequests := []model.OrderRequest{
+			{
+				UserID:          user.UserID,
+				Ticker:          stock.Ticker,
+				TradeType:       exitTradeType,
+				OrderType:       util.OrderTypeLimit,
+				TimeInForce:     entryRequest.TimeInForce,
+				Quantity:        entryRequest.Quantity,
+				LimitPriceCents: takeProfitPriceCents,
+			},
+			{
+				UserID:         user.UserID,
+				Ticker:         stock.Ticker,
+				TradeType:      exitTradeType,
+				OrderType:      util.OrderTypeStop,
+				TimeInForce:    entryRequest.TimeInForce,
+				Quantity:       entryRequest.Quantity,
+				StopPriceCents: stopLossPriceCents,
+			},
+		}
+
+		for _, childRequest := range childRequests {
+			child, err := buildRestingOrder(tx, user, stock, childRequest)
+			if err != nil {
+				return err
+			}
+			child.OrderStatus = util.OrderStatusInactive
+			child.OrderGroupID = &group.OrderGroupID
+			child.ParentOrderID = &parent.OrderID
+
+			if err := savePlacedOrder(tx, &child); err != nil {
+				return err
+			}
+		}
+
+		if !isPendingOrderMarketable(parent, stock) {
+			return nil
+		}
+		if err := fillOrder(tx, parent, stock); err != nil {
+			return err
+		}
+
+		//an entry failing its fill rejects the whole bracket instead of leaving it FAILED
+		if entry := db.GetOrderByIdTx(tx, parent.OrderID); entry.OrderStatus == util.OrderStatusFailed {
+			return errors.New(entry.Notes)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return errors.New("Failed to place bracket order, " + err.Error())
+	}
+
+	return nil
+}
+
+// PlaceOcoOrder places two resting orders on the same stock, once either of them fills the other one is canceled.
+func PlaceOcoOrder(legRequests []model.OrderRequest) error {
+
+	for _, legRequest := range legRequests {
+		if err := checkOrderRisk(legRequest); err != nil {
+			return err
+		}
+	}
+
+	err := bookTransaction(func(tx *gorm.DB) error {
+
+		if len(legRequests) != 2 {
+			return errors.New("an OCO order needs exactly two legs")
+		}
+
+		if legRequests[0].UserID != legRequests[1].UserID || legRequests[0].Ticker != legRequests[1].Ticker {
+			return errors.New("both legs must be for the same user and stock")
+		}
+
+		stock := db.GetStockByTicker(legRequests[0].Ticker)
+		if stock.StockID == 0 {
+			return errors.New("stock " + legRequests[0].Ticker + " not found")
+		}
+
+		user := db.GetUserByIdTx(tx, legRequests[0].UserID)
+		if user.UserID == 0 {
+			return errors.New("user does not exist")
+		}
+
+		group := orm.OrderGroups{
+			UserID:    user.UserID,
+			GroupType: util.OrderGroupTypeOco,
+			CreatedAt: time.Now(),
+		}
+		if err := tx.Create(&group).Error; err != nil {
+			fmt.Println("Failed to save order group:", err)
+			return errors.New("failed to save order group")
+		}
+
+		for _, legRequest := range legRequests {
+			if legRequest.TimeInForce == "" {
+				legRequest.TimeInForce = util.TimeInForceDay
+			}
+			if legRequest.TimeInForce != util.TimeInForceDay && legRequest.TimeInForce != util.TimeInForceGTC {
+				return errors.New("OCO orders support DAY and GTC only")
+			}
+
+			leg, err := buildRestingOrder(tx, user, stock, legRequest)
+			if err != nil {
+				return err
+			}
+			leg.OrderGroupID = &group.OrderGroupID
+
+			if err := savePlacedOrder(tx, &leg); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return errors.New("Failed to place OCO order, " + err.Error())
+	}
+
+	return nil
+}
+
+// onOrderFilled activates the children of a filled bracket parent and cancels the OCO siblings of a filled order.
+func onOrderFilled(tx *gorm.DB, order orm.Orders) error {
+
+	if order.OrderGroupID == nil {
+		return nil
+	}
+
+	err := tx.Model(&orm.Orders{}).
+		Where("parent_order_id = ? and order_status = ?", order.OrderID, util.OrderStatusInactive).
+		Update("order_status", gorm.Expr("case when order_type = ? then ? else ? end",
+			util.OrderTypeLimit, util.OrderStatusPending, util.OrderStatusAwaitingTrigger)).Error
+	if err != nil {
+		return err
+	}
+
+	return cancelOcoSiblings(tx, order, fmt.Sprintf("canceled because OCO order %d filled", order.OrderID))
+}
+
+// cancelLinkedOrders cancels the open children and OCO siblings of an order that is canceled or failed.
+func cancelLinkedOrders(tx *gorm.DB, order orm.Orders, notes string) error {
+
+	if order.OrderGroupID == nil {
+		return nil
+	}
+
+	err := tx.Model(&orm.Orders{}).
+		Where("parent_order_id = ? and order_status in ?", order.OrderID, openOrderStatuses).
+		Updates(map[string]interface{}{
+			"order_status": util.OrderStatusCanceled,
+			"notes":        notes,
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	return cancelOcoSiblings(tx, order, notes)
+}
+
+// cancelOcoSiblings cancels the other open orders of the group sharing the same parent,
+// the two exits of a bracket or the two legs of an OCO group.
+func cancelOcoSiblings(tx *gorm.DB, order orm.Orders, notes string) error {
+
+	query := tx.Model(&orm.Orders{}).
+		Where("order_group_id = ? and order_id <> ? and order_status in ?", *order.OrderGroupID, order.OrderID, openOrderStatuses)
+
+	if order.ParentOrderID != nil {
+		query = query.Where("parent_order_id = ?", *order.ParentOrderID)
+	} else {
+		query = query.Where("parent_order_id is null")
+	}
+
+	err := query.Updates(map[string]interface{}{
+		"order_status": util.OrderStatusCanceled,
+		"notes":        notes,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	//the children activated or canceled before are in the group as well
+	recordBookChange(tx, db.GetOrderIdsByOrderGroupIdTx(tx, *order.OrderGroupID)...)
+	return nil
+}
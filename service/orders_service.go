@@ -1,32 +1,946 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"time"
 	"trading_platform_backend/db"
 	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
 	"trading_platform_backend/util"
+
+	"gorm.io/gorm"
 )
 
 func GetAllOrders(userId int64) []model.OrderModel {
 
 	ordersAndStocks := db.GetOrdersAndStocksByUserId(userId)
 
+	amendmentsMap := make(map[int64][]model.OrderAmendmentModel)
+	for _, amendment := range db.GetOrderAmendmentsByUserId(userId) {
+		amendmentsMap[amendment.OrderID] = append(amendmentsMap[amendment.OrderID], model.OrderAmendmentModel{
+			OrderAmendmentID:     amendment.OrderAmendmentID,
+			OldQuantity:          util.ConvertQuantityToShares(amendment.OldQuantity),
+			NewQuantity:          util.ConvertQuantityToShares(amendment.NewQuantity),
+			OldLimitPriceDollars: util.ConvertCentsToDollars(amendment.OldLimitPriceCents),
+			NewLimitPriceDollars: util.ConvertCentsToDollars(amendment.NewLimitPriceCents),
+			CreatedAt:            util.GetDateTimeString(amendment.CreatedAt),
+		})
+	}
+
+	executionsMap := make(map[int64][]model.ExecutionModel)
+	for _, execution := range db.GetExecutionsByUserId(userId) {
+		var counterpartyOrderId int64
+		if execution.CounterpartyOrderID != nil {
+			counterpartyOrderId = *execution.CounterpartyOrderID
+		}
+		executionsMap[execution.OrderID] = append(executionsMap[execution.OrderID], model.ExecutionModel{
+			ExecutionID:          execution.ExecutionID,
+			Quantity:             util.ConvertQuantityToShares(execution.Quantity),
+			PricePerShareDollars: util.ConvertCentsToDollars(execution.PricePerShareCents),
+			FeeDollars:           util.ConvertCentsToDollars(execution.FeeCents),
+			CounterpartyOrderID:  counterpartyOrderId,
+			CreatedAt:            util.GetDateTimeString(execution.CreatedAt),
+		})
+	}
+
 	orderModels := make([]model.OrderModel, len(ordersAndStocks))
 
 	for i, order := range ordersAndStocks {
+
+		triggeredAt := ""
+		if triggeredAtTime, ok := order["triggered_at"].(time.Time); ok {
+			triggeredAt = util.GetDateTimeString(triggeredAtTime)
+		}
+
+		orderId := int64(order["order_id"].(int32))
+
 		orderModels[i] = model.OrderModel{
-			OrderID:                int64(order["order_id"].(int32)),
-			StockTicker:            order["ticker"].(string),
-			StockName:              order["name"].(string),
-			TradeType:              order["trade_type"].(string),
-			OrderStatus:            order["order_status"].(string),
-			Quantity:               order["quantity"].(int64),
-			PricePerShareDollars:   util.ConvertCentsToDollars(order["price_per_share_cents"].(int64)),
-			TotalOrderValueDollars: util.ConvertCentsToDollars(order["total_order_value_cents"].(int64)),
-			CreatedAt:              util.GetDateTimeString(order["created_at"].(time.Time)),
-			Notes:                  order["notes"].(string),
+			OrderID:                 orderId,
+			StockTicker:             order["ticker"].(string),
+			StockName:               order["name"].(string),
+			TradeType:               order["trade_type"].(string),
+			OrderType:               order["order_type"].(string),
+			TimeInForce:             order["time_in_force"].(string),
+			OrderStatus:             order["order_status"].(string),
+			Quantity:                util.ConvertQuantityToShares(order["quantity"].(int64)),
+			FilledQuantity:          util.ConvertQuantityToShares(order["filled_quantity"].(int64)),
+			RemainingQuantity:       util.ConvertQuantityToShares(order["quantity"].(int64) - order["filled_quantity"].(int64)),
+			LimitPriceDollars:       util.ConvertCentsToDollars(order["limit_price_cents"].(int64)),
+			StopPriceDollars:        util.ConvertCentsToDollars(order["stop_price_cents"].(int64)),
+			TrailAmountDollars:      util.ConvertCentsToDollars(order["trail_amount_cents"].(int64)),
+			TrailPercent:            order["trail_percent"].(float64),
+			PricePerShareDollars:    util.ConvertCentsToDollars(order["price_per_share_cents"].(int64)),
+			TotalOrderValueDollars:  util.ConvertCentsToDollars(order["total_order_value_cents"].(int64)),
+			FeeDollars:              util.ConvertCentsToDollars(order["fee_cents"].(int64)),
+			AverageFillPriceDollars: util.ConvertCentsToDollars(order["average_fill_price_cents"].(int64)),
+			CreatedAt:               util.GetDateTimeString(order["created_at"].(time.Time)),
+			TriggeredAt:             triggeredAt,
+			Notes:                   order["notes"].(string),
+			Amendments:              amendmentsMap[orderId],
+			Executions:              executionsMap[orderId],
+		}
+
+		if orderGroupId, ok := order["order_group_id"].(int32); ok {
+			orderModels[i].OrderGroupID = int64(orderGroupId)
+		}
+		if parentOrderId, ok := order["parent_order_id"].(int32); ok {
+			orderModels[i].ParentOrderID = int64(parentOrderId)
 		}
 	}
 
 	return orderModels
 }
+
+// PlaceOrder runs the pre-trade risk checks and places the order, a *RiskRejection is returned for rejected orders.
+func PlaceOrder(orderRequest model.OrderRequest) error {
+
+	if orderRequest.TimeInForce == "" {
+		orderRequest.TimeInForce = util.TimeInForceDay
+	}
+
+	switch orderRequest.TimeInForce {
+	case util.TimeInForceDay, util.TimeInForceGTC, util.TimeInForceIOC, util.TimeInForceFOK:
+	default:
+		return errors.New("Failed to place order, unknown time in force " + orderRequest.TimeInForce)
+	}
+
+	if orderRequest.AmountCents != 0 {
+		var err error
+		if orderRequest, err = convertAmountToQuantity(orderRequest); err != nil {
+			return err
+		}
+	}
+
+	//after the conversion, so a quantity rounding to 0 is rejected as well
+	if orderRequest.Quantity <= 0 {
+		return errors.New("Failed to place order, quantity must be greater than 0")
+	}
+
+	if err := validateTaxLotIds(orderRequest); err != nil {
+		return err
+	}
+
+	if err := checkOrderRisk(orderRequest); err != nil {
+		return err
+	}
+
+	if session := GetCurrentMarketSession(); !isOrderTypeAccepted(session, orderRequest.OrderType) {
+		return queueOrder(orderRequest, session)
+	}
+
+	if result := placeOrder(orderRequest); result != "" {
+		return errors.New(result)
+	}
+
+	return nil
+}
+
+// convertAmountToQuantity sets the quantity of a dollar-based market order to what its amount trades at the quote,
+// slippage included, rounded down to the millionth of a share so a buy never costs more than the amount.
+func convertAmountToQuantity(orderRequest model.OrderRequest) (model.OrderRequest, error) {
+
+	if orderRequest.OrderType != "" && orderRequest.OrderType != util.OrderTypeMarket {
+		return orderRequest, errors.New("Failed to place order, amount is only supported on market orders")
+	}
+	if orderRequest.AmountCents < 0 {
+		return orderRequest, errors.New("Failed to place order, amount must be greater than 0")
+	}
+
+	stock := db.GetStockByTicker(orderRequest.Ticker)
+	if stock.StockID == 0 {
+		return orderRequest, errors.New("Failed to place order, stock " + orderRequest.Ticker + " not found")
+	}
+
+	quantity := util.GetQuantityForAmount(orderRequest.AmountCents, getQuotePriceCents(stock, orderRequest.TradeType))
+	orderRequest.Quantity = util.GetQuantityForAmount(orderRequest.AmountCents, getFillPriceCents(stock, orderRequest.TradeType, quantity, 0))
+	if orderRequest.Quantity <= 0 {
+		return orderRequest, errors.New("Failed to place order, amount is less than a millionth of a share")
+	}
+
+	return orderRequest, nil
+}
+
+func placeOrder(orderRequest model.OrderRequest) string {
+
+	switch orderRequest.OrderType {
+	case "", util.OrderTypeMarket:
+		var errMessage string
+		if orderRequest.TradeType == util.TradeTypeBuy {
+			_, errMessage = BuyStocksFractional(orderRequest.UserID, orderRequest.Ticker, orderRequest.Quantity, orderRequest.TaxLotIDs)
+		} else {
+			_, errMessage = SellStocksFractional(orderRequest.UserID, orderRequest.Ticker, orderRequest.Quantity, orderRequest.TaxLotIDs)
+		}
+		return errMessage
+	case util.OrderTypeLimit:
+		return placeLimitOrder(orderRequest)
+	case util.OrderTypeStop, util.OrderTypeStopLimit, util.OrderTypeTrailingStop:
+		return placeStopOrder(orderRequest)
+	}
+
+	return "Failed to place order, unknown order type " + orderRequest.OrderType
+}
+
+func placeLimitOrder(orderRequest model.OrderRequest) string {
+
+	//marketable limit orders execute right away at the quote, the rest first match resting orders
+	//of other users in the order book, then are saved as pending and filled by the price routine
+	//once the price crosses, or canceled right away for IOC / FOK
+
+	var canceledNotes string
+
+	err := bookTransaction(func(tx *gorm.DB) error {
+
+		stock := db.GetStockByTicker(orderRequest.Ticker)
+		if stock.StockID == 0 {
+			return errors.New("stock " + orderRequest.Ticker + " not found")
+		}
+
+		user := db.GetUserByIdTx(tx, orderRequest.UserID)
+		if user.UserID == 0 {
+			return errors.New("user does not exist")
+		}
+
+		var err error
+		canceledNotes, err = placeLimitOrderTx(tx, user, stock, orderRequest, 0)
+		return err
+	})
+
+	if err != nil {
+		return "Failed to place limit order, " + err.Error()
+	}
+
+	if canceledNotes != "" {
+		return "Order canceled, " + canceledNotes
+	}
+
+	return ""
+}
+
+// placeLimitOrderTx places a limit order in tx, under orderId for a released queued order, and returns the notes
+// of the order when it was canceled for its time in force.
+func placeLimitOrderTx(tx *gorm.DB, user orm.Users, stock orm.Stocks, orderRequest model.OrderRequest, orderId int64) (string, error) {
+
+	if orderRequest.LimitPriceCents <= 0 {
+		return "", errors.New("limit price must be greater than 0")
+	}
+
+	orderTemplate := orm.Orders{
+		OrderID:         orderId,
+		OrderType:       util.OrderTypeLimit,
+		TimeInForce:     orderRequest.TimeInForce,
+		LimitPriceCents: orderRequest.LimitPriceCents,
+		TaxLotIDs:       orderRequest.TaxLotIDs,
+	}
+
+	if isLimitOrderMarketable(orderRequest.TradeType, orderRequest.LimitPriceCents, getQuotePriceCents(stock, orderRequest.TradeType)) {
+		//the simulated market always has enough liquidity, so IOC and FOK fill completely here
+		var err error
+		if orderRequest.TradeType == util.TradeTypeBuy {
+			_, err = buyStocks(tx, &user, stock, orderRequest.Quantity, orderTemplate)
+		} else {
+			_, err = sellStocks(tx, &user, stock, orderRequest.Quantity, orderTemplate)
+		}
+		return "", err
+	}
+
+	err := checkBuyingPower(tx, user, stock, orderRequest.TradeType, orderRequest.Quantity, orderRequest.LimitPriceCents, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var canceledNotes string
+	order := orm.Orders{
+		OrderID:              orderId,
+		UserID:               user.UserID,
+		StockID:              stock.StockID,
+		TradeType:            orderRequest.TradeType,
+		OrderType:            util.OrderTypeLimit,
+		TimeInForce:          orderRequest.TimeInForce,
+		OrderStatus:          util.OrderStatusPending,
+		Quantity:             orderRequest.Quantity,
+		LimitPriceCents:      orderRequest.LimitPriceCents,
+		PricePerShareCents:   orderRequest.LimitPriceCents,
+		TotalOrderValueCents: util.GetValueCents(orderRequest.Quantity, orderRequest.LimitPriceCents),
+		CreatedAt:            time.Now(),
+		TaxLotIDs:            orderRequest.TaxLotIDs,
+	}
+
+	if orderRequest.TimeInForce == util.TimeInForceFOK &&
+		getBookQuantity(stock.StockID, user.UserID, orderRequest.TradeType, orderRequest.LimitPriceCents) < order.Quantity {
+		canceledNotes = "FOK order could not be filled completely"
+		order.OrderStatus = util.OrderStatusCanceled
+		order.Notes = canceledNotes
+	}
+
+	if err := savePlacedOrder(tx, &order); err != nil {
+		return "", err
+	}
+
+	if order.OrderStatus == util.OrderStatusCanceled {
+		return canceledNotes, nil
+	}
+
+	order, err = takeBookLiquidity(tx, order, stock, orderRequest.LimitPriceCents)
+	if err != nil {
+		return "", err
+	}
+
+	remainingQuantity := order.Quantity - order.FilledQuantity
+	if remainingQuantity == 0 {
+		return "", nil
+	}
+	if orderRequest.TimeInForce == util.TimeInForceFOK {
+		return "", errors.New("FOK order could not be filled completely")
+	}
+	if orderRequest.TimeInForce == util.TimeInForceIOC {
+		canceledNotes = fmt.Sprintf("IOC order could not be filled immediately, %g shares canceled", util.ConvertQuantityToShares(remainingQuantity))
+		return canceledNotes, tx.Model(&orm.Orders{}).Where("order_id = ?", order.OrderID).
+			Updates(map[string]interface{}{
+				"order_status": util.OrderStatusCanceled,
+				"notes":        canceledNotes,
+			}).Error
+	}
+
+	return "", nil
+}
+
+func placeStopOrder(orderRequest model.OrderRequest) string {
+
+	//stop orders wait for the price routine to cross the stop price,
+	//after which they are turned into a market or limit order
+
+	err := bookTransaction(func(tx *gorm.DB) error {
+
+		if orderRequest.TimeInForce == util.TimeInForceIOC || orderRequest.TimeInForce == util.TimeInForceFOK {
+			return errors.New(orderRequest.TimeInForce + " is not supported for stop orders")
+		}
+
+		stock := db.GetStockByTicker(orderRequest.Ticker)
+		if stock.StockID == 0 {
+			return errors.New("stock " + orderRequest.Ticker + " not found")
+		}
+
+		user := db.GetUserByIdTx(tx, orderRequest.UserID)
+		if user.UserID == 0 {
+			return errors.New("user does not exist")
+		}
+
+		order, err := buildRestingOrder(tx, user, stock, orderRequest)
+		if err != nil {
+			return err
+		}
+
+		return savePlacedOrder(tx, &order)
+	})
+
+	if err != nil {
+		return "Failed to place stop order, " + err.Error()
+	}
+
+	return ""
+}
+
+// savePlacedOrder saves a newly placed order. A released queued order, with its id set, keeps its row instead: the row
+// moves out of QUEUED, or errQueuedOrderGone is returned when it is no longer queued.
+func savePlacedOrder(tx *gorm.DB, order *orm.Orders) error {
+
+	if order.OrderID == 0 {
+		if err := tx.Create(order).Error; err != nil {
+			fmt.Println("Failed to save order:", err)
+			return errors.New("failed to save order")
+		}
+		recordBookChange(tx, order.OrderID)
+		return nil
+	}
+
+	//the user can cancel the queued order meanwhile, it is queued since it was created
+	result := tx.Model(&orm.Orders{}).
+		Where("order_id = ? and order_status = ?", order.OrderID, util.OrderStatusQueued).
+		Select("*").Omit("order_id", "created_at").
+		Updates(order)
+	if result.Error != nil {
+		fmt.Println("Failed to save order:", result.Error)
+		return errors.New("failed to save order")
+	}
+	if result.RowsAffected == 0 {
+		return errQueuedOrderGone
+	}
+
+	recordBookChange(tx, order.OrderID)
+	return nil
+}
+
+// buildRestingOrder validates a limit, stop, stop-limit or trailing stop request and returns the unsaved order,
+// PENDING for limit orders and AWAITING_TRIGGER for stop orders.
+func buildRestingOrder(tx *gorm.DB, user orm.Users, stock orm.Stocks, orderRequest model.OrderRequest) (orm.Orders, error) {
+
+	order := orm.Orders{
+		UserID:           user.UserID,
+		StockID:          stock.StockID,
+		TradeType:        orderRequest.TradeType,
+		OrderType:        orderRequest.OrderType,
+		TimeInForce:      orderRequest.TimeInForce,
+		Quantity:         orderRequest.Quantity,
+		LimitPriceCents:  orderRequest.LimitPriceCents,
+		StopPriceCents:   orderRequest.StopPriceCents,
+		TrailAmountCents: orderRequest.TrailAmountCents,
+		TrailPercent:     orderRequest.TrailPercent,
+		CreatedAt:        time.Now(),
+		TaxLotIDs:        orderRequest.TaxLotIDs,
+	}
+
+	if orderRequest.Quantity <= 0 {
+		return order, errors.New("quantity must be greater than 0")
+	}
+
+	switch orderRequest.OrderType {
+	case util.OrderTypeLimit:
+		if orderRequest.LimitPriceCents <= 0 {
+			return order, errors.New("limit price must be greater than 0")
+		}
+		order.OrderStatus = util.OrderStatusPending
+		order.PricePerShareCents = orderRequest.LimitPriceCents
+
+	case util.OrderTypeStop, util.OrderTypeStopLimit:
+		if orderRequest.StopPriceCents <= 0 {
+			return order, errors.New("stop price must be greater than 0")
+		}
+		if orderRequest.OrderType == util.OrderTypeStopLimit && orderRequest.LimitPriceCents <= 0 {
+			return order, errors.New("limit price must be greater than 0")
+		}
+		if isStopTriggered(orderRequest.TradeType, orderRequest.StopPriceCents, stock.CurrentPriceCents) {
+			return order, errors.New("stop price would trigger immediately at the current price")
+		}
+		order.OrderStatus = util.OrderStatusAwaitingTrigger
+		//estimated price, the actual one is known once the order fills
+		order.PricePerShareCents = orderRequest.StopPriceCents
+		if orderRequest.OrderType == util.OrderTypeStopLimit {
+			order.PricePerShareCents = orderRequest.LimitPriceCents
+		}
+
+	case util.OrderTypeTrailingStop:
+		if (orderRequest.TrailAmountCents > 0) == (orderRequest.TrailPercent > 0) {
+			return order, errors.New("either a trail amount or a trail percent is required")
+		}
+		if orderRequest.TrailAmountCents < 0 || orderRequest.TrailPercent < 0 || orderRequest.TrailPercent >= 100 {
+			return order, errors.New("invalid trail amount or percent")
+		}
+		//a sell stop trailing by the whole price or more would sit at or below 0
+		if orderRequest.TradeType == util.TradeTypeSell && orderRequest.TrailAmountCents >= stock.CurrentPriceCents {
+			return order, errors.New("trail amount must be less than the current price")
+		}
+		order.OrderStatus = util.OrderStatusAwaitingTrigger
+		order.TrailAnchorCents = stock.CurrentPriceCents
+		order.StopPriceCents = getTrailingStopPriceCents(order)
+		order.PricePerShareCents = order.StopPriceCents
+
+	default:
+		return order, errors.New("unsupported order type " + orderRequest.OrderType)
+	}
+
+	order.TotalOrderValueCents = util.GetValueCents(order.Quantity, order.PricePerShareCents)
+	if err := checkBuyingPower(tx, user, stock, order.TradeType, order.Quantity, order.PricePerShareCents, nil); err != nil {
+		return order, err
+	}
+
+	return order, nil
+}
+
+// isStopTriggered reports whether the price has reached the stop price: buy stops (protecting shorts)
+// trigger on the way up, sell stops (protecting longs) on the way down.
+func isStopTriggered(tradeType string, stopPriceCents int64, currentPriceCents int64) bool {
+	if tradeType == util.TradeTypeBuy {
+		return currentPriceCents >= stopPriceCents
+	}
+	return currentPriceCents <= stopPriceCents
+}
+
+func isPendingOrderMarketable(order orm.Orders, stock orm.Stocks) bool {
+	if order.OrderType == util.OrderTypeMarket {
+		return true
+	}
+	return isLimitOrderMarketable(order.TradeType, order.LimitPriceCents, getQuotePriceCents(stock, order.TradeType))
+}
+
+// isLimitOrderMarketable reports whether the limit price reaches the quote, the ask for buys and the bid for sells.
+func isLimitOrderMarketable(tradeType string, limitPriceCents int64, currentPriceCents int64) bool {
+	if tradeType == util.TradeTypeBuy {
+		return currentPriceCents <= limitPriceCents
+	}
+	return currentPriceCents >= limitPriceCents
+}
+
+// ProcessStopOrders triggers every stop order of the stock crossed by currentPriceCents, turning it into
+// a pending market or limit order. It returns one notification per triggered order for its owner.
+func ProcessStopOrders(stock orm.Stocks, currentPriceCents int64) []model.NotificationModel {
+
+	notifications := make([]model.NotificationModel, 0)
+
+	for _, order := range db.GetOrdersAwaitingTriggerByStockId(stock.StockID) {
+		if order.OrderType == util.OrderTypeTrailingStop {
+			order = ratchetTrailingStop(order, currentPriceCents)
+		}
+
+		if !isStopTriggered(order.TradeType, order.StopPriceCents, currentPriceCents) {
+			continue
+		}
+
+		notification, err := triggerStopOrder(order, stock, currentPriceCents)
+		if err != nil {
+			fmt.Printf("Failed to trigger stop order %d, %s\n", order.OrderID, err.Error())
+			continue
+		}
+		if notification.OrderID > 0 {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	return notifications
+}
+
+// getTrailingStopPriceCents is the trigger level trailing the order's anchor by its amount or percent,
+// below the high-water mark for sells and above the low-water mark for buys.
+func getTrailingStopPriceCents(order orm.Orders) int64 {
+
+	offsetCents := order.TrailAmountCents
+	if order.TrailPercent > 0 {
+		offsetCents = int64(math.Round(float64(order.TrailAnchorCents) * order.TrailPercent / 100))
+	}
+
+	if order.TradeType == util.TradeTypeBuy {
+		return order.TrailAnchorCents + offsetCents
+	}
+	return order.TrailAnchorCents - offsetCents
+}
+
+// ratchetTrailingStop moves the anchor (and with it the stop) when the price makes a new high for a sell
+// or a new low for a buy, and saves it so the trigger level survives restarts. The stop never moves back.
+func ratchetTrailingStop(order orm.Orders, currentPriceCents int64) orm.Orders {
+
+	isNewHigh := order.TradeType == util.TradeTypeSell && currentPriceCents > order.TrailAnchorCents
+	isNewLow := order.TradeType == util.TradeTypeBuy && currentPriceCents < order.TrailAnchorCents
+	if !isNewHigh && !isNewLow {
+		return order
+	}
+
+	order.TrailAnchorCents = currentPriceCents
+	order.StopPriceCents = getTrailingStopPriceCents(order)
+
+	err := db.DB.Model(&orm.Orders{}).
+		Where("order_id = ? and order_status = ?", order.OrderID, util.OrderStatusAwaitingTrigger).
+		Updates(map[string]interface{}{
+			"trail_anchor_cents":      order.TrailAnchorCents,
+			"stop_price_cents":        order.StopPriceCents,
+			"price_per_share_cents":   order.StopPriceCents,
+			"total_order_value_cents": util.GetValueCents(order.Quantity, order.StopPriceCents),
+		}).Error
+	if err != nil {
+		fmt.Printf("Failed to ratchet trailing stop %d, %s\n", order.OrderID, err.Error())
+	}
+
+	return order
+}
+
+func triggerStopOrder(order orm.Orders, stock orm.Stocks, currentPriceCents int64) (model.NotificationModel, error) {
+
+	var notification model.NotificationModel
+
+	err := bookTransaction(func(tx *gorm.DB) error {
+
+		user := db.GetUserByIdTx(tx, order.UserID)
+		if user.UserID == 0 {
+			return errors.New("user does not exist")
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"order_status": util.OrderStatusPending,
+			"triggered_at": now,
+		}
+
+		pricePerShareCents := currentPriceCents
+		if order.OrderType == util.OrderTypeStopLimit {
+			updates["order_type"] = util.OrderTypeLimit
+			pricePerShareCents = order.LimitPriceCents
+		} else {
+			updates["order_type"] = util.OrderTypeMarket
+		}
+		updates["price_per_share_cents"] = pricePerShareCents
+		updates["total_order_value_cents"] = util.GetValueCents(order.Quantity, pricePerShareCents)
+
+		message := fmt.Sprintf("%s stop order for %g %s triggered at $%.2f",
+			order.TradeType, util.ConvertQuantityToShares(order.Quantity), stock.Ticker, util.ConvertCentsToDollars(currentPriceCents))
+
+		if err := checkBuyingPower(tx, user, stock, order.TradeType, order.Quantity, pricePerShareCents, nil); err != nil {
+			updates["order_status"] = util.OrderStatusFailed
+			updates["notes"] = "stop triggered but " + err.Error()
+			message += ", but failed for insufficient buying power"
+		} else {
+			updates["notes"] = fmt.Sprintf("stop triggered at $%.2f", util.ConvertCentsToDollars(currentPriceCents))
+		}
+
+		result := tx.Model(&orm.Orders{}).
+			Where("order_id = ? and order_status = ?", order.OrderID, util.OrderStatusAwaitingTrigger).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			//no longer awaiting a trigger
+			return nil
+		}
+		recordBookChange(tx, order.OrderID)
+
+		notification = model.NotificationModel{
+			UserID:    user.UserID,
+			EventType: util.NotificationEventStopTriggered,
+			Message:   message,
+			OrderID:   order.OrderID,
+		}
+		if err := createNotification(tx, &notification, now); err != nil {
+			return err
+		}
+
+		if updates["order_status"] == util.OrderStatusFailed {
+			return cancelLinkedOrders(tx, order, fmt.Sprintf("canceled because order %d failed", order.OrderID))
+		}
+
+		return nil
+	})
+
+	return notification, err
+}
+
+// ProcessPendingOrders fills every pending order of the stock that is marketable at the current price.
+func ProcessPendingOrders(stock orm.Stocks) {
+
+	pendingOrders := db.GetPendingOrdersByStockId(stock.StockID)
+
+	for _, order := range pendingOrders {
+		if !isPendingOrderMarketable(order, stock) {
+			continue
+		}
+
+		result := fillPendingOrder(order, stock)
+		if result != "" {
+			fmt.Println(result)
+		}
+	}
+}
+
+func fillPendingOrder(order orm.Orders, stock orm.Stocks) string {
+
+	err := bookTransaction(func(tx *gorm.DB) error {
+		return fillOrder(tx, order, stock)
+	})
+
+	if err != nil {
+		return fmt.Sprintf("Failed to fill order %d, %s", order.OrderID, err.Error())
+	}
+
+	return ""
+}
+
+// fillOrder executes the rest of a pending order at the quote plus slippage and applies the order group rules
+// in the same transaction.
+func fillOrder(tx *gorm.DB, order orm.Orders, stock orm.Stocks) error {
+
+	user := db.GetUserByIdTx(tx, order.UserID)
+	if user.UserID == 0 {
+		return errors.New("user does not exist")
+	}
+
+	remainingQuantity := order.Quantity - order.FilledQuantity
+	fillPriceCents := getFillPriceCents(stock, order.TradeType, remainingQuantity, order.LimitPriceCents)
+
+	//the order's own reservation is released by this fill
+	if err := checkBuyingPower(tx, user, stock, order.TradeType, remainingQuantity, fillPriceCents, &order); err != nil {
+		return failOrder(tx, order, err.Error()+" at fill time")
+	}
+
+	_, _, err := fillOrderQuantity(tx, order, stock, remainingQuantity, fillPriceCents, nil)
+	return err
+}
+
+// fillOrderQuantity executes quantity (millionths of a share) of a pending order at pricePerShareCents and saves the execution,
+// counterpartyOrderId is the matching order for fills in the order book. The order is PARTIALLY_FILLED until its
+// whole quantity is filled, then EXECUTED at the average fill price with the order group rules applied.
+// It reports false, without doing anything, if the order was filled, canceled or amended since it was loaded.
+func fillOrderQuantity(tx *gorm.DB, order orm.Orders, stock orm.Stocks, quantity int64, pricePerShareCents int64, counterpartyOrderId *int64) (orm.Orders, bool, error) {
+
+	user := db.GetUserByIdTx(tx, order.UserID)
+	if user.UserID == 0 {
+		return order, false, errors.New("user does not exist")
+	}
+
+	fillValueCents := util.GetValueCents(quantity, pricePerShareCents)
+	//fees are charged in USD on the USD value of the fill
+	feeCents := getFeeCents(getFeeSchedule(user), order.TradeType, quantity, toUsdCents(getFxRates(), getStockCurrency(stock), fillValueCents))
+
+	filledQuantity := order.FilledQuantity + quantity
+	filledValueCents := db.GetExecutedValueCentsByOrderIdTx(tx, order.OrderID) + fillValueCents
+	averageFillPriceCents := int64(math.Round(float64(filledValueCents) * util.ShareScale / float64(filledQuantity)))
+
+	updates := map[string]interface{}{
+		"filled_quantity":          filledQuantity,
+		"average_fill_price_cents": averageFillPriceCents,
+		"fee_cents":                order.FeeCents + feeCents,
+	}
+
+	if filledQuantity == order.Quantity {
+		updates["order_status"] = util.OrderStatusExecuted
+		updates["price_per_share_cents"] = averageFillPriceCents
+		updates["total_order_value_cents"] = filledValueCents
+	} else {
+		//the rest of the order keeps its reservation
+		updates["order_status"] = util.OrderStatusPartiallyFilled
+		updates["total_order_value_cents"] = util.GetValueCents(order.Quantity-filledQuantity, order.PricePerShareCents)
+	}
+
+	//matching the quantities and limit price as well skips orders amended or filled since they were loaded
+	result := tx.Model(&orm.Orders{}).
+		Where("order_id = ? and order_status in ? and quantity = ? and filled_quantity = ? and limit_price_cents = ?",
+			order.OrderID, util.FillableOrderStatuses, order.Quantity, order.FilledQuantity, order.LimitPriceCents).
+		Updates(updates)
+	if result.Error != nil {
+		return order, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		//already filled or no longer pending
+		return order, false, nil
+	}
+	recordBookChange(tx, order.OrderID)
+
+	execution := orm.Executions{
+		OrderID:             order.OrderID,
+		UserID:              order.UserID,
+		StockID:             stock.StockID,
+		TradeType:           order.TradeType,
+		Quantity:            quantity,
+		PricePerShareCents:  pricePerShareCents,
+		FeeCents:            feeCents,
+		CounterpartyOrderID: counterpartyOrderId,
+		CreatedAt:           time.Now(),
+	}
+	if err := tx.Create(&execution).Error; err != nil {
+		fmt.Println("Failed to save execution:", err)
+		return order, false, errors.New("failed to save execution")
+	}
+
+	if err := postTradeJournal(tx, execution, stock); err != nil {
+		return order, false, err
+	}
+
+	averageCostCents, err := updateTaxLots(tx, user, order, execution)
+	if err != nil {
+		return order, false, err
+	}
+
+	holding := db.GetHoldingByUserIdAndStockIdTx(tx, user.UserID, stock.StockID)
+
+	if err := adjustCash(tx, user.UserID, util.CurrencyUsd, -feeCents, execution.CreatedAt); err != nil {
+		return order, false, err
+	}
+
+	updateResult := updatePosition(tx, &user, stock, order.TradeType, quantity, fillValueCents, averageCostCents, &holding)
+	if updateResult != "" {
+		return order, false, errors.New(updateResult)
+	}
+
+	order.FilledQuantity = filledQuantity
+	order.AverageFillPriceCents = averageFillPriceCents
+	order.FeeCents += feeCents
+	order.OrderStatus = updates["order_status"].(string)
+	order.TotalOrderValueCents = updates["total_order_value_cents"].(int64)
+	if order.OrderStatus == util.OrderStatusExecuted {
+		order.PricePerShareCents = averageFillPriceCents
+		return order, true, onOrderFilled(tx, order)
+	}
+
+	return order, true, nil
+}
+
+// failOrder marks a pending order FAILED, keeping any fills it already had, and cancels its linked orders.
+func failOrder(tx *gorm.DB, order orm.Orders, notes string) error {
+
+	result := tx.Model(&orm.Orders{}).
+		Where("order_id = ? and order_status in ?", order.OrderID, util.FillableOrderStatuses).
+		Updates(map[string]interface{}{
+			"order_status": util.OrderStatusFailed,
+			"notes":        notes,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	recordBookChange(tx, order.OrderID)
+
+	return cancelLinkedOrders(tx, order, fmt.Sprintf("canceled because order %d failed", order.OrderID))
+}
+
+// openOrderStatuses are the statuses of orders that are still resting and can be canceled or amended.
+var openOrderStatuses = append([]string{util.OrderStatusAwaitingTrigger, util.OrderStatusInactive, util.OrderStatusQueued}, util.FillableOrderStatuses...)
+
+func isOrderFillable(order orm.Orders) bool {
+	return order.OrderStatus == util.OrderStatusPending || order.OrderStatus == util.OrderStatusPartiallyFilled
+}
+
+func isOrderOpen(order orm.Orders) bool {
+	for _, status := range openOrderStatuses {
+		if order.OrderStatus == status {
+			return true
+		}
+	}
+	return false
+}
+
+func CancelOrder(userId int64, orderId int64) error {
+
+	order := db.GetOrderById(orderId)
+	if order.OrderID == 0 || order.UserID != userId {
+		return errors.New("order not found")
+	}
+
+	if !isOrderOpen(order) {
+		return errors.New("only pending orders can be canceled")
+	}
+
+	err := bookTransaction(func(tx *gorm.DB) error {
+
+		//the status check guards against the price routine filling the order meanwhile
+		result := tx.Model(&orm.Orders{}).
+			Where("order_id = ? and user_id = ? and order_status in ?", orderId, userId, openOrderStatuses).
+			Updates(map[string]interface{}{
+				"order_status": util.OrderStatusCanceled,
+				"notes":        "canceled by user",
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("order is no longer pending")
+		}
+		recordBookChange(tx, orderId)
+
+		return cancelLinkedOrders(tx, order, fmt.Sprintf("canceled with order %d", orderId))
+	})
+
+	return err
+}
+
+// AmendOrder changes the quantity and/or limit price of an open order, a zero value keeps the current one.
+func AmendOrder(userId int64, orderId int64, quantity int64, limitPriceCents int64) error {
+
+	err := bookTransaction(func(tx *gorm.DB) error {
+
+		order := db.GetOrderByIdTx(tx, orderId)
+		if order.OrderID == 0 || order.UserID != userId {
+			return errors.New("order not found")
+		}
+
+		if !isOrderOpen(order) {
+			return errors.New("only pending orders can be amended")
+		}
+
+		if quantity < 0 || limitPriceCents < 0 {
+			return errors.New("quantity and limit price must be greater than 0")
+		}
+
+		newQuantity := order.Quantity
+		if quantity > 0 {
+			newQuantity = quantity
+		}
+
+		newLimitPriceCents := order.LimitPriceCents
+		if limitPriceCents > 0 {
+			if order.OrderType != util.OrderTypeLimit && order.OrderType != util.OrderTypeStopLimit {
+				return errors.New("limit price can only be amended on limit orders")
+			}
+			newLimitPriceCents = limitPriceCents
+		}
+
+		if newQuantity == order.Quantity && newLimitPriceCents == order.LimitPriceCents {
+			return errors.New("nothing to amend")
+		}
+
+		if newQuantity <= order.FilledQuantity {
+			return fmt.Errorf("quantity must be greater than the %g shares already filled", util.ConvertQuantityToShares(order.FilledQuantity))
+		}
+		remainingQuantity := newQuantity - order.FilledQuantity
+
+		//price used for the reservation / estimate, same as when the order was placed
+		pricePerShareCents := order.PricePerShareCents
+		if order.OrderType == util.OrderTypeLimit || order.OrderType == util.OrderTypeStopLimit {
+			pricePerShareCents = newLimitPriceCents
+		}
+		totalOrderValueCents := util.GetValueCents(remainingQuantity, pricePerShareCents)
+
+		//the order's own reservation is replaced by the amended one
+		user := db.GetUserByIdTx(tx, userId)
+		stock := db.GetStockById(order.StockID)
+		if err := checkBuyingPower(tx, user, stock, order.TradeType, remainingQuantity, pricePerShareCents, &order); err != nil {
+			return err
+		}
+
+		result := tx.Model(&orm.Orders{}).
+			Where("order_id = ? and order_status = ? and filled_quantity = ?", orderId, order.OrderStatus, order.FilledQuantity).
+			Updates(map[string]interface{}{
+				"quantity":                newQuantity,
+				"limit_price_cents":       newLimitPriceCents,
+				"price_per_share_cents":   pricePerShareCents,
+				"total_order_value_cents": totalOrderValueCents,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("order is no longer pending")
+		}
+		recordBookChange(tx, orderId)
+
+		amendment := orm.OrderAmendments{
+			OrderID:            orderId,
+			UserID:             userId,
+			OldQuantity:        order.Quantity,
+			NewQuantity:        newQuantity,
+			OldLimitPriceCents: order.LimitPriceCents,
+			NewLimitPriceCents: newLimitPriceCents,
+			CreatedAt:          time.Now(),
+		}
+
+		return tx.Create(&amendment).Error
+	})
+
+	return err
+}
+
+// ExpireDayOrders expires every open DAY order placed before the regular session close and returns how many were expired.
+func ExpireDayOrders(sessionClose time.Time) int64 {
+
+	var expiredCount int64
+
+	err := bookTransaction(func(tx *gorm.DB) error {
+
+		orderIds := db.GetOpenDayOrderIdsTx(tx, openOrderStatuses, sessionClose)
+		result := tx.Model(&orm.Orders{}).
+			Where("order_id in ? and order_status in ?", orderIds, openOrderStatuses).
+			Updates(map[string]interface{}{
+				"order_status": util.OrderStatusExpired,
+				"notes":        "DAY order expired at session close " + util.GetDateTimeString(sessionClose),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		recordBookChange(tx, orderIds...)
+		expiredCount = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Failed to expire DAY orders, " + err.Error())
+		return 0
+	}
+
+	return expiredCount
+}
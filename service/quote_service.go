@@ -0,0 +1,50 @@
+package service
+
+import (
+	"math"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+)
+
+// getQuotePriceCents is the price a trade executes against, the ask for buys and the bid for sells.
+// It falls back to the current price until the generator has published a quote.
+func getQuotePriceCents(stock orm.Stocks, tradeType string) int64 {
+	if tradeType == util.TradeTypeBuy && stock.AskPriceCents > 0 {
+		return stock.AskPriceCents
+	}
+	if tradeType == util.TradeTypeSell && stock.BidPriceCents > 0 {
+		return stock.BidPriceCents
+	}
+	return stock.CurrentPriceCents
+}
+
+// getSlippageCents grows with the order size relative to the simulated liquidity, an order of
+// LiquidityShares shares moves the price by SlippageBps. A zero SlippageBps turns slippage off.
+func getSlippageCents(stock orm.Stocks, quantity int64) int64 {
+	if stock.SlippageBps <= 0 || stock.LiquidityShares <= 0 {
+		return 0
+	}
+	return int64(math.Round(float64(stock.CurrentPriceCents) * stock.SlippageBps / 10000 * util.ConvertQuantityToShares(quantity) / float64(stock.LiquidityShares)))
+}
+
+// getFillPriceCents is the quote worsened by the slippage of an order of quantity (millionths of a share),
+// never worse than limitPriceCents when it is set.
+func getFillPriceCents(stock orm.Stocks, tradeType string, quantity int64, limitPriceCents int64) int64 {
+
+	quotePriceCents := getQuotePriceCents(stock, tradeType)
+	slippageCents := getSlippageCents(stock, quantity)
+
+	if tradeType == util.TradeTypeBuy {
+		fillPriceCents := quotePriceCents + slippageCents
+		if limitPriceCents > 0 && fillPriceCents > limitPriceCents {
+			fillPriceCents = limitPriceCents
+		}
+		return fillPriceCents
+	}
+
+	fillPriceCents := max(quotePriceCents-slippageCents, 1)
+	if limitPriceCents > 0 && fillPriceCents < limitPriceCents {
+		fillPriceCents = limitPriceCents
+	}
+	return fillPriceCents
+}
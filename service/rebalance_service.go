@@ -0,0 +1,452 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveTargetAllocation replaces the user's target allocation, the stock percents and the cash percent must add up to 100.
+// A scheduled allocation is first rebalanced one period after it is saved.
+func SaveTargetAllocation(allocationRequest model.TargetAllocationRequest) (model.TargetAllocationModel, error) {
+
+	user := db.GetUserById(allocationRequest.UserID)
+	if user.UserID == 0 {
+		return model.TargetAllocationModel{}, errors.New("user does not exist")
+	}
+
+	if len(allocationRequest.Targets) == 0 {
+		return model.TargetAllocationModel{}, errors.New("at least one stock target is required")
+	}
+
+	if allocationRequest.RebalanceFrequency == "" {
+		allocationRequest.RebalanceFrequency = util.RebalanceFrequencyNone
+	}
+	switch allocationRequest.RebalanceFrequency {
+	case util.RebalanceFrequencyNone, util.RebalanceFrequencyDaily, util.RebalanceFrequencyWeekly, util.RebalanceFrequencyMonthly:
+	default:
+		return model.TargetAllocationModel{}, errors.New("unknown rebalance frequency " + allocationRequest.RebalanceFrequency)
+	}
+
+	if allocationRequest.DriftThresholdPercent == 0 {
+		allocationRequest.DriftThresholdPercent = util.DefaultDriftThresholdPercent
+	}
+	if allocationRequest.MinTradeCents == 0 {
+		allocationRequest.MinTradeCents = util.DefaultMinTradeCents
+	}
+	if allocationRequest.DriftThresholdPercent < 0 || allocationRequest.MinTradeCents < 0 {
+		return model.TargetAllocationModel{}, errors.New("drift threshold and minimum trade can't be negative")
+	}
+	if allocationRequest.CashPercent < 0 || allocationRequest.CashPercent > 100 {
+		return model.TargetAllocationModel{}, errors.New("cash percent must be between 0 and 100")
+	}
+
+	now := time.Now()
+	totalPercent := allocationRequest.CashPercent
+	targetAllocations := make([]orm.TargetAllocations, 0, len(allocationRequest.Targets))
+
+	for ticker, targetPercent := range allocationRequest.Targets {
+		if targetPercent <= 0 || targetPercent > 100 {
+			return model.TargetAllocationModel{}, errors.New("the target of " + ticker + " must be between 0 and 100 percent")
+		}
+		stock := db.GetStockByTicker(ticker)
+		if stock.StockID == 0 {
+			return model.TargetAllocationModel{}, errors.New("stock " + ticker + " not found")
+		}
+		totalPercent += targetPercent
+		targetAllocations = append(targetAllocations, orm.TargetAllocations{
+			UserID:        user.UserID,
+			StockID:       stock.StockID,
+			TargetPercent: targetPercent,
+			CreatedAt:     now,
+		})
+	}
+
+	if math.Abs(totalPercent-100) > 0.01 {
+		return model.TargetAllocationModel{}, fmt.Errorf("the targets and cash add up to %.2f%%, not 100%%", totalPercent)
+	}
+
+	portfolioTarget := orm.PortfolioTargets{
+		UserID:                user.UserID,
+		CashPercent:           allocationRequest.CashPercent,
+		DriftThresholdPercent: allocationRequest.DriftThresholdPercent,
+		MinTradeCents:         allocationRequest.MinTradeCents,
+		RebalanceFrequency:    allocationRequest.RebalanceFrequency,
+		CreatedAt:             now,
+		UpdatedAt:             now,
+	}
+	if existing := db.GetPortfolioTargetByUserId(user.UserID); existing.UserID > 0 {
+		portfolioTarget.CreatedAt = existing.CreatedAt
+		portfolioTarget.LastRebalancedAt = existing.LastRebalancedAt
+	}
+	if portfolioTarget.RebalanceFrequency != util.RebalanceFrequencyNone {
+		nextRebalanceAt := getNextRebalanceAt(portfolioTarget.RebalanceFrequency, now)
+		portfolioTarget.NextRebalanceAt = &nextRebalanceAt
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			UpdateAll: true,
+		}).Create(&portfolioTarget).Error
+		if err != nil {
+			fmt.Println("Failed to save portfolio target:", err)
+			return errors.New("failed to save portfolio target")
+		}
+
+		if err := tx.Where("user_id = ?", user.UserID).Delete(&orm.TargetAllocations{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&targetAllocations).Error; err != nil {
+			fmt.Println("Failed to save target allocations:", err)
+			return errors.New("failed to save target allocations")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return model.TargetAllocationModel{}, err
+	}
+
+	return GetTargetAllocation(user.UserID)
+}
+
+func getNextRebalanceAt(frequency string, from time.Time) time.Time {
+	switch frequency {
+	case util.RebalanceFrequencyDaily:
+		return from.AddDate(0, 0, 1)
+	case util.RebalanceFrequencyWeekly:
+		return from.AddDate(0, 0, 7)
+	}
+	return from.AddDate(0, 1, 0)
+}
+
+func GetTargetAllocation(userId int64) (model.TargetAllocationModel, error) {
+
+	portfolioTarget := db.GetPortfolioTargetByUserId(userId)
+	if portfolioTarget.UserID == 0 {
+		return model.TargetAllocationModel{}, errors.New("no target allocation saved")
+	}
+
+	stocksById := getStocksById()
+	targetAllocations := db.GetTargetAllocationsByUserId(userId)
+
+	allocationModel := model.TargetAllocationModel{
+		Targets:               make([]model.TargetWeightModel, len(targetAllocations)),
+		CashPercent:           portfolioTarget.CashPercent,
+		DriftThresholdPercent: portfolioTarget.DriftThresholdPercent,
+		MinTradeDollars:       util.ConvertCentsToDollars(portfolioTarget.MinTradeCents),
+		RebalanceFrequency:    portfolioTarget.RebalanceFrequency,
+	}
+	for i, targetAllocation := range targetAllocations {
+		allocationModel.Targets[i] = model.TargetWeightModel{
+			StockTicker:   stocksById[targetAllocation.StockID].Ticker,
+			TargetPercent: targetAllocation.TargetPercent,
+		}
+	}
+	if portfolioTarget.NextRebalanceAt != nil {
+		allocationModel.NextRebalanceAt = util.GetDateTimeString(*portfolioTarget.NextRebalanceAt)
+	}
+	if portfolioTarget.LastRebalancedAt != nil {
+		allocationModel.LastRebalancedAt = util.GetDateTimeString(*portfolioTarget.LastRebalancedAt)
+	}
+
+	return allocationModel, nil
+}
+
+// GetRebalancePreview returns the trades moving the user's portfolio to its target allocation, without placing them.
+func GetRebalancePreview(userId int64) (model.RebalancePreviewModel, error) {
+
+	user := db.GetUserById(userId)
+	if user.UserID == 0 {
+		return model.RebalancePreviewModel{}, errors.New("user does not exist")
+	}
+
+	portfolioTarget := db.GetPortfolioTargetByUserId(userId)
+	if portfolioTarget.UserID == 0 {
+		return model.RebalancePreviewModel{}, errors.New("no target allocation saved")
+	}
+
+	preview, _, err := getRebalancePlan(user, portfolioTarget, db.GetTargetAllocationsByUserId(userId))
+	return preview, err
+}
+
+// getRebalancePlan values the holdings and cash in USD at the generator's current prices and returns the preview with the
+// basket legs executing it. Only positions drifted at least the threshold from their target are traded, stocks held
+// without a target have a 0% target. The sells come first, and the buys share the cash they leave over the target cash,
+// scaled down together when it falls short. Trades under the minimum are skipped and sells never open a short.
+func getRebalancePlan(user orm.Users, portfolioTarget orm.PortfolioTargets, targetAllocations []orm.TargetAllocations) (model.RebalancePreviewModel, []model.OrderRequest, error) {
+
+	fxRates := getFxRates()
+	stocksById := getStocksById()
+	feeSchedule := getFeeSchedule(user)
+
+	targetPercents := make(map[int64]float64)
+	for _, targetAllocation := range targetAllocations {
+		targetPercents[targetAllocation.StockID] = targetAllocation.TargetPercent
+	}
+
+	cashCents := user.CashBalanceCents + getForeignCashUsdCents(db.DB, user.UserID, fxRates)
+	totalCents := cashCents
+
+	holdings := make(map[int64]orm.Holdings)
+	valuesCents := make(map[int64]int64)
+	for _, holding := range db.GetActiveHoldingsByUserID(user.UserID) {
+		stock := stocksById[holding.StockID]
+		holdings[holding.StockID] = holding
+		valuesCents[holding.StockID] = getUsdValueCents(fxRates, stock, holding.Quantity, stock.CurrentPriceCents)
+		totalCents += valuesCents[holding.StockID]
+	}
+
+	if totalCents <= 0 {
+		return model.RebalancePreviewModel{}, nil, errors.New("the portfolio has no value to rebalance")
+	}
+
+	getPercent := func(valueCents int64) float64 {
+		return float64(valueCents) / float64(totalCents) * 100
+	}
+
+	stockIds := make([]int64, 0, len(targetPercents)+len(holdings))
+	for stockId := range targetPercents {
+		stockIds = append(stockIds, stockId)
+	}
+	for stockId := range holdings {
+		if _, ok := targetPercents[stockId]; !ok {
+			stockIds = append(stockIds, stockId)
+		}
+	}
+	sort.Slice(stockIds, func(i, j int) bool {
+		return stocksById[stockIds[i]].Ticker < stocksById[stockIds[j]].Ticker
+	})
+
+	preview := model.RebalancePreviewModel{
+		PortfolioValueDollars: util.ConvertCentsToDollars(totalCents),
+		CashDollars:           util.ConvertCentsToDollars(cashCents),
+		CashPercent:           getPercent(cashCents),
+		TargetCashPercent:     portfolioTarget.CashPercent,
+		Positions:             make([]model.RebalancePositionModel, 0, len(stockIds)),
+		Trades:                make([]model.RebalanceTradeModel, 0),
+	}
+
+	legs := make([]model.OrderRequest, 0)
+	addTrade := func(stock orm.Stocks, tradeType string, quantity int64, valueCents int64, feeCents int64) {
+		preview.Trades = append(preview.Trades, model.RebalanceTradeModel{
+			StockTicker:         stock.Ticker,
+			TradeType:           tradeType,
+			Quantity:            util.ConvertQuantityToShares(quantity),
+			ValueDollars:        util.ConvertCentsToDollars(valueCents),
+			EstimatedFeeDollars: util.ConvertCentsToDollars(feeCents),
+		})
+		legs = append(legs, model.OrderRequest{
+			UserID:    user.UserID,
+			Ticker:    stock.Ticker,
+			TradeType: tradeType,
+			OrderType: util.OrderTypeMarket,
+			Quantity:  quantity,
+		})
+	}
+
+	projectedCashCents := cashCents
+	buyCents := make(map[int64]int64)
+	var totalBuyCents int64
+
+	for _, stockId := range stockIds {
+		stock := stocksById[stockId]
+		holding := holdings[stockId]
+		driftPercent := getPercent(valuesCents[stockId]) - targetPercents[stockId]
+
+		preview.Positions = append(preview.Positions, model.RebalancePositionModel{
+			StockTicker:    stock.Ticker,
+			Quantity:       util.ConvertQuantityToShares(holding.Quantity),
+			ValueDollars:   util.ConvertCentsToDollars(valuesCents[stockId]),
+			CurrentPercent: getPercent(valuesCents[stockId]),
+			TargetPercent:  targetPercents[stockId],
+			DriftPercent:   driftPercent,
+		})
+
+		if math.Abs(driftPercent) < portfolioTarget.DriftThresholdPercent || driftPercent == 0 {
+			continue
+		}
+
+		differenceCents := int64(math.Round(float64(totalCents)*targetPercents[stockId]/100)) - valuesCents[stockId]
+		if differenceCents > 0 {
+			buyCents[stockId] = differenceCents
+			totalBuyCents += differenceCents
+			continue
+		}
+		if holding.Quantity <= 0 {
+			continue
+		}
+
+		quantity := holding.Quantity
+		if targetPercents[stockId] > 0 {
+			amountCents := convertCents(fxRates, -differenceCents, util.CurrencyUsd, getStockCurrency(stock))
+			quantity = min(quantity, util.GetQuantityForAmount(amountCents, stock.CurrentPriceCents))
+		}
+		valueCents := getUsdValueCents(fxRates, stock, quantity, getFillPriceCents(stock, util.TradeTypeSell, quantity, 0))
+		if quantity <= 0 || valueCents < portfolioTarget.MinTradeCents {
+			continue
+		}
+		feeCents := getFeeCents(feeSchedule, util.TradeTypeSell, quantity, valueCents)
+
+		projectedCashCents += valueCents - feeCents
+		addTrade(stock, util.TradeTypeSell, quantity, valueCents, feeCents)
+	}
+
+	budgetCents := projectedCashCents - int64(math.Round(float64(totalCents)*portfolioTarget.CashPercent/100))
+	scale := 1.0
+	if totalBuyCents > budgetCents {
+		scale = float64(max(budgetCents, 0)) / float64(totalBuyCents)
+	}
+
+	for _, stockId := range stockIds {
+		if buyCents[stockId] == 0 {
+			continue
+		}
+		stock := stocksById[stockId]
+		amountCents := int64(float64(buyCents[stockId]) * scale)
+
+		//the fee comes out of the amount
+		quantity := getRebalanceBuyQuantity(fxRates, stock, amountCents)
+		feeCents := getFeeCents(feeSchedule, util.TradeTypeBuy, quantity, amountCents)
+		quantity = getRebalanceBuyQuantity(fxRates, stock, amountCents-feeCents)
+
+		valueCents := getUsdValueCents(fxRates, stock, quantity, getFillPriceCents(stock, util.TradeTypeBuy, quantity, 0))
+		if quantity <= 0 || valueCents < portfolioTarget.MinTradeCents {
+			continue
+		}
+		addTrade(stock, util.TradeTypeBuy, quantity, valueCents, getFeeCents(feeSchedule, util.TradeTypeBuy, quantity, valueCents))
+	}
+
+	preview.IsBalanced = len(preview.Trades) == 0
+
+	return preview, legs, nil
+}
+
+// getRebalanceBuyQuantity is the quantity amountCents (USD) buys at the ask with its slippage, rounded down to the
+// millionth of a share like a dollar-based order.
+func getRebalanceBuyQuantity(fxRates map[string]orm.FxRates, stock orm.Stocks, amountCents int64) int64 {
+	if amountCents <= 0 {
+		return 0
+	}
+	amountCents = convertCents(fxRates, amountCents, util.CurrencyUsd, getStockCurrency(stock))
+	quantity := util.GetQuantityForAmount(amountCents, getQuotePriceCents(stock, util.TradeTypeBuy))
+	return util.GetQuantityForAmount(amountCents, getFillPriceCents(stock, util.TradeTypeBuy, quantity, 0))
+}
+
+// ExecuteRebalance places the trades of the rebalance preview as an ALL_OR_NONE basket, so the portfolio is never left
+// half rebalanced.
+func ExecuteRebalance(userId int64) (model.RebalanceResultModel, error) {
+
+	user := db.GetUserById(userId)
+	if user.UserID == 0 {
+		return model.RebalanceResultModel{}, errors.New("user does not exist")
+	}
+
+	portfolioTarget := db.GetPortfolioTargetByUserId(userId)
+	if portfolioTarget.UserID == 0 {
+		return model.RebalanceResultModel{}, errors.New("no target allocation saved")
+	}
+
+	preview, legs, err := getRebalancePlan(user, portfolioTarget, db.GetTargetAllocationsByUserId(userId))
+	if err != nil {
+		return model.RebalanceResultModel{}, err
+	}
+
+	result := model.RebalanceResultModel{Preview: preview}
+	if len(legs) == 0 {
+		return result, nil
+	}
+
+	result.Basket, err = PlaceBasketOrder(model.BasketOrderRequest{
+		UserID:        userId,
+		ExecutionMode: util.BasketModeAllOrNone,
+		Legs:          legs,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if result.Basket.ExecutedLegs > 0 {
+		err = db.DB.Model(&orm.PortfolioTargets{}).Where("user_id = ?", userId).
+			Update("last_rebalanced_at", time.Now()).Error
+	}
+
+	return result, err
+}
+
+// RunScheduledRebalances rebalances the portfolios whose scheduled rebalance is due and moves their schedule to the
+// next period, a failed rebalance waits for the next period too. It returns the notifications to push to the users.
+func RunScheduledRebalances(now time.Time) []model.NotificationModel {
+
+	notifications := make([]model.NotificationModel, 0)
+
+	for _, portfolioTarget := range db.GetDuePortfolioTargets(now) {
+
+		nextRebalanceAt := *portfolioTarget.NextRebalanceAt
+		for !nextRebalanceAt.After(now) {
+			nextRebalanceAt = getNextRebalanceAt(portfolioTarget.RebalanceFrequency, nextRebalanceAt)
+		}
+
+		//claiming the run skips rebalances already moved to the next period
+		result := db.DB.Model(&orm.PortfolioTargets{}).
+			Where("user_id = ? and next_rebalance_at = ?", portfolioTarget.UserID, *portfolioTarget.NextRebalanceAt).
+			Update("next_rebalance_at", nextRebalanceAt)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		rebalance, err := ExecuteRebalance(portfolioTarget.UserID)
+
+		var message string
+		switch {
+		case err != nil:
+			message = "Scheduled rebalance failed, " + err.Error()
+		case rebalance.Basket.FailedLegs > 0:
+			message = "Scheduled rebalance was rejected, " + getBasketFailure(rebalance.Basket)
+		case rebalance.Basket.ExecutedLegs > 0:
+			message = fmt.Sprintf("Scheduled rebalance executed %d trades", rebalance.Basket.ExecutedLegs)
+		default:
+			//within the drift thresholds, nothing to report
+			continue
+		}
+
+		notification := model.NotificationModel{
+			UserID:    portfolioTarget.UserID,
+			EventType: util.NotificationEventRebalance,
+			Message:   message,
+		}
+		if err := createNotification(db.DB, &notification, now); err != nil {
+			fmt.Printf("Failed to save rebalance notification of user %d, %s\n", portfolioTarget.UserID, err.Error())
+			continue
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications
+}
+
+// getBasketFailure is the reason of the first leg failing a basket, the other legs of a rejected basket are not executed.
+func getBasketFailure(basket model.BasketOrderModel) string {
+	if basket.ErrorMessage != "" {
+		return basket.ErrorMessage
+	}
+	for _, leg := range basket.Legs {
+		if leg.OrderStatus == util.OrderStatusFailed && !strings.HasPrefix(leg.ErrorMessage, "not executed") {
+			return leg.Ticker + ": " + leg.ErrorMessage
+		}
+	}
+	return "unknown reason"
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+)
+
+// CreateRecurringInvestment schedules a market buy of a fixed amount of a stock every cadence period, from FirstRunAt
+// or right away. Runs falling outside the regular session wait for the next one.
+func CreateRecurringInvestment(investmentRequest model.RecurringInvestmentRequest) (model.RecurringInvestmentModel, error) {
+
+	user := db.GetUserById(investmentRequest.UserID)
+	if user.UserID == 0 {
+		return model.RecurringInvestmentModel{}, errors.New("user does not exist")
+	}
+
+	stock := db.GetStockByTicker(investmentRequest.Ticker)
+	if stock.StockID == 0 {
+		return model.RecurringInvestmentModel{}, errors.New("stock " + investmentRequest.Ticker + " not found")
+	}
+
+	if investmentRequest.AmountCents <= 0 {
+		return model.RecurringInvestmentModel{}, errors.New("amount must be greater than 0")
+	}
+
+	switch investmentRequest.Cadence {
+	case util.RecurringCadenceDaily, util.RecurringCadenceWeekly, util.RecurringCadenceBiweekly, util.RecurringCadenceMonthly:
+	default:
+		return model.RecurringInvestmentModel{}, errors.New("unknown cadence " + investmentRequest.Cadence)
+	}
+
+	now := time.Now()
+	nextRunAt := now
+	if investmentRequest.FirstRunAt != "" {
+		firstRunAt, err := time.Parse(time.RFC3339, investmentRequest.FirstRunAt)
+		if err != nil {
+			return model.RecurringInvestmentModel{}, errors.New("firstRunAt must be an RFC 3339 date")
+		}
+		if firstRunAt.Before(now) {
+			return model.RecurringInvestmentModel{}, errors.New("firstRunAt can't be in the past")
+		}
+		nextRunAt = firstRunAt
+	}
+
+	recurringInvestment := orm.RecurringInvestments{
+		UserID:      user.UserID,
+		StockID:     stock.StockID,
+		AmountCents: investmentRequest.AmountCents,
+		Cadence:     investmentRequest.Cadence,
+		Status:      util.RecurringInvestmentStatusActive,
+		NextRunAt:   nextRunAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := db.DB.Create(&recurringInvestment).Error; err != nil {
+		fmt.Println("Failed to save recurring investment:", err)
+		return model.RecurringInvestmentModel{}, errors.New("failed to save recurring investment")
+	}
+
+	return getRecurringInvestmentModel(recurringInvestment, stock), nil
+}
+
+func GetRecurringInvestments(userId int64) []model.RecurringInvestmentModel {
+
+	stocksById := getStocksById()
+	recurringInvestments := db.GetRecurringInvestmentsByUserId(userId)
+
+	investmentModels := make([]model.RecurringInvestmentModel, len(recurringInvestments))
+	for i, recurringInvestment := range recurringInvestments {
+		investmentModels[i] = getRecurringInvestmentModel(recurringInvestment, stocksById[recurringInvestment.StockID])
+	}
+
+	return investmentModels
+}
+
+func getRecurringInvestmentModel(recurringInvestment orm.RecurringInvestments, stock orm.Stocks) model.RecurringInvestmentModel {
+
+	investmentModel := model.RecurringInvestmentModel{
+		RecurringInvestmentID: recurringInvestment.RecurringInvestmentID,
+		StockTicker:           stock.Ticker,
+		Currency:              getStockCurrency(stock),
+		AmountDollars:         util.ConvertCentsToDollars(recurringInvestment.AmountCents),
+		Cadence:               recurringInvestment.Cadence,
+		Status:                recurringInvestment.Status,
+		NextRunAt:             util.GetDateTimeString(recurringInvestment.NextRunAt),
+		LastRunStatus:         recurringInvestment.LastRunStatus,
+		CreatedAt:             util.GetDateTimeString(recurringInvestment.CreatedAt),
+	}
+	if recurringInvestment.LastRunAt != nil {
+		investmentModel.LastRunAt = util.GetDateTimeString(*recurringInvestment.LastRunAt)
+	}
+
+	return investmentModel
+}
+
+// PauseRecurringInvestment stops the runs of an active recurring investment until it is resumed.
+func PauseRecurringInvestment(userId int64, recurringInvestmentId int64) error {
+
+	recurringInvestment := db.GetRecurringInvestmentById(recurringInvestmentId)
+	if recurringInvestment.RecurringInvestmentID == 0 || recurringInvestment.UserID != userId {
+		return errors.New("recurring investment not found")
+	}
+
+	result := db.DB.Model(&orm.RecurringInvestments{}).
+		Where("recurring_investment_id = ? and status = ?", recurringInvestmentId, util.RecurringInvestmentStatusActive).
+		Updates(map[string]interface{}{
+			"status":     util.RecurringInvestmentStatusPaused,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("recurring investment is not active")
+	}
+
+	return nil
+}
+
+// ResumeRecurringInvestment reactivates a paused recurring investment, the runs missed while it was paused are skipped.
+func ResumeRecurringInvestment(userId int64, recurringInvestmentId int64) error {
+
+	recurringInvestment := db.GetRecurringInvestmentById(recurringInvestmentId)
+	if recurringInvestment.RecurringInvestmentID == 0 || recurringInvestment.UserID != userId {
+		return errors.New("recurring investment not found")
+	}
+
+	now := time.Now()
+	result := db.DB.Model(&orm.RecurringInvestments{}).
+		Where("recurring_investment_id = ? and status = ?", recurringInvestmentId, util.RecurringInvestmentStatusPaused).
+		Updates(map[string]interface{}{
+			"status":      util.RecurringInvestmentStatusActive,
+			"next_run_at": getNextRecurringRunAt(recurringInvestment.Cadence, recurringInvestment.NextRunAt, now),
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("recurring investment is not paused")
+	}
+
+	return nil
+}
+
+// getNextRecurringRunAt is the first run of the cadence from runAt that is after now, runAt itself when it is.
+func getNextRecurringRunAt(cadence string, runAt time.Time, now time.Time) time.Time {
+	for !runAt.After(now) {
+		switch cadence {
+		case util.RecurringCadenceDaily:
+			runAt = runAt.AddDate(0, 0, 1)
+		case util.RecurringCadenceWeekly:
+			runAt = runAt.AddDate(0, 0, 7)
+		case util.RecurringCadenceBiweekly:
+			runAt = runAt.AddDate(0, 0, 14)
+		default:
+			runAt = runAt.AddDate(0, 1, 0)
+		}
+	}
+	return runAt
+}
+
+// RunRecurringInvestments places the buys of the recurring investments due through the normal order path and moves
+// each to its next run. A run the user's cash can't cover is skipped. Every run sends a notification, which is
+// returned to push to the users.
+func RunRecurringInvestments(now time.Time) []model.NotificationModel {
+
+	notifications := make([]model.NotificationModel, 0)
+	stocksById := getStocksById()
+
+	for _, recurringInvestment := range db.GetDueRecurringInvestments(now) {
+
+		//claiming the run skips runs already made, or paused since they were loaded
+		result := db.DB.Model(&orm.RecurringInvestments{}).
+			Where("recurring_investment_id = ? and status = ? and next_run_at = ?", recurringInvestment.RecurringInvestmentID,
+				util.RecurringInvestmentStatusActive, recurringInvestment.NextRunAt).
+			Update("next_run_at", getNextRecurringRunAt(recurringInvestment.Cadence, recurringInvestment.NextRunAt, now))
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		stock := stocksById[recurringInvestment.StockID]
+		runStatus, message := runRecurringInvestment(recurringInvestment, stock)
+
+		err := db.DB.Model(&orm.RecurringInvestments{}).
+			Where("recurring_investment_id = ?", recurringInvestment.RecurringInvestmentID).
+			Updates(map[string]interface{}{
+				"last_run_at":     now,
+				"last_run_status": runStatus,
+				"updated_at":      now,
+			}).Error
+		if err != nil {
+			fmt.Printf("Failed to save run of recurring investment %d, %s\n", recurringInvestment.RecurringInvestmentID, err.Error())
+		}
+
+		notification := model.NotificationModel{
+			UserID:    recurringInvestment.UserID,
+			EventType: util.NotificationEventRecurringInvestment,
+			Message:   message,
+		}
+		if err := createNotification(db.DB, &notification, now); err != nil {
+			fmt.Printf("Failed to save recurring investment notification of user %d, %s\n", recurringInvestment.UserID, err.Error())
+			continue
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications
+}
+
+// runRecurringInvestment buys the amount of the stock as a dollar-based market order, unless the USD cash and the cash
+// in the stock's currency don't cover it. It returns the run status and the message for the user.
+func runRecurringInvestment(recurringInvestment orm.RecurringInvestments, stock orm.Stocks) (string, string) {
+
+	currency := getStockCurrency(stock)
+	description := fmt.Sprintf("recurring investment of %.2f %s in %s", util.ConvertCentsToDollars(recurringInvestment.AmountCents), currency, stock.Ticker)
+
+	user := db.GetUserById(recurringInvestment.UserID)
+	fxRates := getFxRates()
+
+	availableCents := user.CashBalanceCents
+	if currency != util.CurrencyUsd {
+		availableCents += toUsdCents(fxRates, currency, db.GetCashBalanceByUserIdAndCurrencyTx(db.DB, user.UserID, currency).BalanceCents)
+	}
+	if toUsdCents(fxRates, currency, recurringInvestment.AmountCents) > availableCents {
+		return util.RecurringRunSkipped, fmt.Sprintf("Skipped the %s, only $%.2f cash available", description,
+			util.ConvertCentsToDollars(max(availableCents, 0)))
+	}
+
+	err := PlaceOrder(model.OrderRequest{
+		UserID:      user.UserID,
+		Ticker:      stock.Ticker,
+		TradeType:   util.TradeTypeBuy,
+		OrderType:   util.OrderTypeMarket,
+		AmountCents: recurringInvestment.AmountCents,
+	})
+	if err != nil {
+		return util.RecurringRunFailed, "The " + description + " failed, " + err.Error()
+	}
+
+	return util.RecurringRunExecuted, "Placed the " + description
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+)
+
+// RiskOrder is an order request as seen by the pre-trade risk rules.
+type RiskOrder struct {
+	User               orm.Users
+	Stock              orm.Stocks
+	TradeType          string
+	OrderType          string
+	Quantity           int64
+	PricePerShareCents int64 // limit or stop price, the quote for market orders, in the stock's currency
+	HoldingQuantity    int64 // current position, negative when short
+	EquityCents        int64 // in USD
+	UsdRateMicros      int64 // USD per unit of the stock's currency, in millionths
+}
+
+// RiskRejection is returned for an order rejected by a risk rule, Code is one of the util.RiskReason codes.
+type RiskRejection struct {
+	Code    string
+	Message string
+}
+
+func (rejection *RiskRejection) Error() string {
+	return "order rejected by risk check, " + rejection.Message
+}
+
+// RiskRule is a pre-trade check run against every order before it is placed, Check returns nil to accept the order.
+type RiskRule interface {
+	Check(order RiskOrder) *RiskRejection
+}
+
+var riskRules = []RiskRule{
+	maxOrderNotionalRule{},
+	maxPositionSizeRule{},
+	concentrationRule{},
+	maxShortSharesRule{},
+	fatFingerRule{},
+}
+
+// RegisterRiskRule adds a rule to the checks run before every order, meant to be called during startup.
+func RegisterRiskRule(rule RiskRule) {
+	riskRules = append(riskRules, rule)
+}
+
+// getPositionAfter is the position once the whole order is filled.
+func (order RiskOrder) getPositionAfter() int64 {
+	if order.TradeType == util.TradeTypeBuy {
+		return order.HoldingQuantity + order.Quantity
+	}
+	return order.HoldingQuantity - order.Quantity
+}
+
+// getUsdValueCents is the USD value of quantity at the order price, the limits are in USD.
+func (order RiskOrder) getUsdValueCents(quantity int64) int64 {
+	return util.ConvertCurrencyCents(util.GetValueCents(quantity, order.PricePerShareCents), order.UsdRateMicros, util.FxRateScale)
+}
+
+// isIncreasingPosition reports whether the order opens or grows a long or short position,
+// rules limiting the exposure never block reducing it.
+func (order RiskOrder) isIncreasingPosition() bool {
+	return abs(order.getPositionAfter()) > abs(order.HoldingQuantity)
+}
+
+func abs(value int64) int64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+type maxOrderNotionalRule struct{}
+
+func (maxOrderNotionalRule) Check(order RiskOrder) *RiskRejection {
+	notionalCents := order.getUsdValueCents(order.Quantity)
+	if notionalCents <= util.MaxOrderNotionalCents {
+		return nil
+	}
+	return &RiskRejection{
+		Code: util.RiskReasonMaxOrderNotional,
+		Message: fmt.Sprintf("order value $%.2f exceeds the limit of $%.2f",
+			util.ConvertCentsToDollars(notionalCents), util.ConvertCentsToDollars(util.MaxOrderNotionalCents)),
+	}
+}
+
+type maxPositionSizeRule struct{}
+
+func (maxPositionSizeRule) Check(order RiskOrder) *RiskRejection {
+	positionAfter := abs(order.getPositionAfter())
+	if positionAfter <= util.MaxPositionShares*util.ShareScale || !order.isIncreasingPosition() {
+		return nil
+	}
+	return &RiskRejection{
+		Code: util.RiskReasonMaxPositionSize,
+		Message: fmt.Sprintf("position of %g shares in %s exceeds the limit of %d shares",
+			util.ConvertQuantityToShares(positionAfter), order.Stock.Ticker, util.MaxPositionShares),
+	}
+}
+
+type concentrationRule struct{}
+
+func (concentrationRule) Check(order RiskOrder) *RiskRejection {
+	if !order.isIncreasingPosition() || order.EquityCents <= 0 {
+		//no equity is left for the buying power check
+		return nil
+	}
+	positionValueCents := order.getUsdValueCents(abs(order.getPositionAfter()))
+	concentrationPercent := float64(positionValueCents) / float64(order.EquityCents) * 100
+	if concentrationPercent <= util.MaxConcentrationPercent {
+		return nil
+	}
+	return &RiskRejection{
+		Code: util.RiskReasonConcentration,
+		Message: fmt.Sprintf("position in %s would be %.1f%% of the account equity, the limit is %.1f%%",
+			order.Stock.Ticker, concentrationPercent, util.MaxConcentrationPercent),
+	}
+}
+
+type maxShortSharesRule struct{}
+
+func (maxShortSharesRule) Check(order RiskOrder) *RiskRejection {
+	positionAfter := order.getPositionAfter()
+	if positionAfter >= -util.MaxShortShares*util.ShareScale || !order.isIncreasingPosition() {
+		return nil
+	}
+	return &RiskRejection{
+		Code: util.RiskReasonMaxShortShares,
+		Message: fmt.Sprintf("short position of %g shares in %s exceeds the limit of %d shares",
+			util.ConvertQuantityToShares(-positionAfter), order.Stock.Ticker, util.MaxShortShares),
+	}
+}
+
+type fatFingerRule struct{}
+
+func (fatFingerRule) Check(order RiskOrder) *RiskRejection {
+	if order.OrderType != util.OrderTypeLimit && order.OrderType != util.OrderTypeStopLimit || order.Stock.CurrentPriceCents <= 0 {
+		return nil
+	}
+	deviationPercent := math.Abs(float64(order.PricePerShareCents-order.Stock.CurrentPriceCents)) / float64(order.Stock.CurrentPriceCents) * 100
+	if deviationPercent <= util.FatFingerPercent {
+		return nil
+	}
+	return &RiskRejection{
+		Code: util.RiskReasonFatFinger,
+		Message: fmt.Sprintf("limit price $%.2f is %.1f%% away from the current price $%.2f, the limit is %.1f%%",
+			util.ConvertCentsToDollars(order.PricePerShareCents), deviationPercent,
+			util.ConvertCentsToDollars(order.Stock.CurrentPriceCents), util.FatFingerPercent),
+	}
+}
+
+// checkOrderRisk runs the risk rules against an order request. A rejected order is saved as FAILED with the
+// reason in its notes and the *RiskRejection is returned. Unknown stocks or users are left to the order placement.
+func checkOrderRisk(orderRequest model.OrderRequest) error {
+
+	rejection, order := getRiskRejection(orderRequest)
+	if rejection == nil {
+		return nil
+	}
+
+	if err := db.DB.Create(&order).Error; err != nil {
+		fmt.Println("Failed to save rejected order:", err)
+	}
+
+	return rejection
+}
+
+// getRiskRejection returns the first rule rejecting the order request with the unsaved FAILED order recording it,
+// or nil when the order passes.
+func getRiskRejection(orderRequest model.OrderRequest) (*RiskRejection, orm.Orders) {
+
+	stock := db.GetStockByTicker(orderRequest.Ticker)
+	user := db.GetUserById(orderRequest.UserID)
+	if stock.StockID == 0 || user.UserID == 0 {
+		return nil, orm.Orders{}
+	}
+
+	orderType := orderRequest.OrderType
+	if orderType == "" {
+		orderType = util.OrderTypeMarket
+	}
+	if orderRequest.TimeInForce == "" {
+		orderRequest.TimeInForce = util.TimeInForceDay
+	}
+
+	var pricePerShareCents int64
+	switch orderType {
+	case util.OrderTypeLimit, util.OrderTypeStopLimit:
+		pricePerShareCents = orderRequest.LimitPriceCents
+	case util.OrderTypeStop:
+		pricePerShareCents = orderRequest.StopPriceCents
+	default:
+		pricePerShareCents = getQuotePriceCents(stock, orderRequest.TradeType)
+	}
+
+	riskOrder := RiskOrder{
+		User:               user,
+		Stock:              stock,
+		TradeType:          orderRequest.TradeType,
+		OrderType:          orderType,
+		Quantity:           orderRequest.Quantity,
+		PricePerShareCents: pricePerShareCents,
+		HoldingQuantity:    db.GetHoldingByUserIdAndStockId(user.UserID, stock.StockID).Quantity,
+		EquityCents:        getMarginAccount(db.DB, user, getStocksById()).EquityCents,
+		UsdRateMicros:      getUsdRateMicros(getFxRates(), getStockCurrency(stock)),
+	}
+
+	for _, rule := range riskRules {
+		rejection := rule.Check(riskOrder)
+		if rejection == nil {
+			continue
+		}
+
+		order := orm.Orders{
+			UserID:               user.UserID,
+			StockID:              stock.StockID,
+			TradeType:            riskOrder.TradeType,
+			OrderType:            orderType,
+			TimeInForce:          orderRequest.TimeInForce,
+			OrderStatus:          util.OrderStatusFailed,
+			Quantity:             riskOrder.Quantity,
+			LimitPriceCents:      orderRequest.LimitPriceCents,
+			StopPriceCents:       orderRequest.StopPriceCents,
+			PricePerShareCents:   pricePerShareCents,
+			TotalOrderValueCents: util.GetValueCents(riskOrder.Quantity, pricePerShareCents),
+			CreatedAt:            time.Now(),
+			Notes:                rejection.Code + ": " + rejection.Message,
+		}
+
+		return rejection, order
+	}
+
+	return nil, orm.Orders{}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+
+	"gorm.io/gorm"
+)
+
+func GetTaxLots(userId int64) []model.TaxLotModel {
+
+	stocksById := getStocksById()
+	taxLots := db.GetOpenTaxLotsByUserId(userId)
+	taxLotModels := make([]model.TaxLotModel, len(taxLots))
+
+	for i, taxLot := range taxLots {
+		stock := stocksById[taxLot.StockID]
+		taxLotModels[i] = model.TaxLotModel{
+			TaxLotID:             taxLot.TaxLotID,
+			StockTicker:          stock.Ticker,
+			IsShort:              taxLot.IsShort,
+			Quantity:             util.ConvertQuantityToShares(taxLot.Quantity),
+			RemainingQuantity:    util.ConvertQuantityToShares(taxLot.RemainingQuantity),
+			CostPerShareDollars:  util.ConvertCentsToDollars(taxLot.CostPerShareCents),
+			UnrealizedPnLDollars: util.ConvertCentsToDollars(getUnrealizedPnlCents(taxLot, stock)),
+			AcquiredAt:           util.GetDateTimeString(taxLot.AcquiredAt),
+		}
+	}
+
+	return taxLotModels
+}
+
+func GetRealizedLots(userId int64) []model.RealizedLotModel {
+
+	stocksById := getStocksById()
+	realizedLots := db.GetRealizedLotsByUserId(userId)
+	realizedLotModels := make([]model.RealizedLotModel, len(realizedLots))
+
+	for i, realizedLot := range realizedLots {
+		realizedLotModels[i] = model.RealizedLotModel{
+			RealizedLotID:        realizedLot.RealizedLotID,
+			TaxLotID:             realizedLot.TaxLotID,
+			StockTicker:          stocksById[realizedLot.StockID].Ticker,
+			IsShort:              realizedLot.IsShort,
+			Quantity:             util.ConvertQuantityToShares(realizedLot.Quantity),
+			CostPerShareDollars:  util.ConvertCentsToDollars(realizedLot.CostPerShareCents),
+			PricePerShareDollars: util.ConvertCentsToDollars(realizedLot.PricePerShareCents),
+			RealizedPnLDollars:   util.ConvertCentsToDollars(realizedLot.RealizedPnlCents),
+			ClosedAt:             util.GetDateTimeString(realizedLot.ClosedAt),
+		}
+	}
+
+	return realizedLotModels
+}
+
+func getCostBasisMethod(user orm.Users) string {
+	if user.CostBasisMethod == "" {
+		return util.CostBasisFifo
+	}
+	return user.CostBasisMethod
+}
+
+func getLotPnlCents(taxLot orm.TaxLots, quantity int64, pricePerShareCents int64) int64 {
+	if taxLot.IsShort {
+		return util.GetValueCents(quantity, taxLot.CostPerShareCents-pricePerShareCents)
+	}
+	return util.GetValueCents(quantity, pricePerShareCents-taxLot.CostPerShareCents)
+}
+
+func getUnrealizedPnlCents(taxLot orm.TaxLots, stock orm.Stocks) int64 {
+	return getLotPnlCents(taxLot, taxLot.RemainingQuantity, stock.CurrentPriceCents)
+}
+
+// getAverageLotCostCents is the average cost per share of the open lots, exact up to the final rounding.
+func getAverageLotCostCents(taxLots []orm.TaxLots) int64 {
+	var quantity, quantityCost int64
+	for _, taxLot := range taxLots {
+		quantity += taxLot.RemainingQuantity
+		quantityCost += taxLot.RemainingQuantity * taxLot.CostPerShareCents
+	}
+	if quantity == 0 {
+		return 0
+	}
+	return int64(math.Round(float64(quantityCost) / float64(quantity)))
+}
+
+// sortLotsToClose orders the lots the way the cost basis method consumes them, lots picked on the
+// order come first for SPECIFIC_LOT.
+func sortLotsToClose(taxLots []orm.TaxLots, costBasisMethod string, taxLotIds []int64) {
+
+	//the lots are read oldest first, the stable sort keeps that order for ties
+	sort.SliceStable(taxLots, func(i, j int) bool {
+		a, b := taxLots[i], taxLots[j]
+		switch costBasisMethod {
+		case util.CostBasisLifo:
+			return a.AcquiredAt.After(b.AcquiredAt) || a.AcquiredAt.Equal(b.AcquiredAt) && a.TaxLotID > b.TaxLotID
+		case util.CostBasisHifo:
+			if a.IsShort {
+				return a.CostPerShareCents < b.CostPerShareCents
+			}
+			return a.CostPerShareCents > b.CostPerShareCents
+		case util.CostBasisSpecificLot:
+			aIndex, bIndex := slices.Index(taxLotIds, a.TaxLotID), slices.Index(taxLotIds, b.TaxLotID)
+			if aIndex >= 0 && bIndex >= 0 {
+				return aIndex < bIndex
+			}
+			return aIndex >= 0 && bIndex < 0
+		}
+		return false
+	})
+}
+
+// updateTaxLots closes the user's lots on the other side of the execution in cost basis order, saving the realized
+// P&L of each, and opens a new lot with the rest of the execution. It returns the average cost of the open lots after.
+func updateTaxLots(tx *gorm.DB, user orm.Users, order orm.Orders, execution orm.Executions) (int64, error) {
+
+	openLots := db.GetOpenTaxLotsByUserIdAndStockIdTx(tx, user.UserID, execution.StockID)
+
+	//a buy covers short lots, a sell closes long lots
+	closeShortLots := execution.TradeType == util.TradeTypeBuy
+	lotsToClose := make([]orm.TaxLots, 0)
+	for _, taxLot := range openLots {
+		if taxLot.IsShort == closeShortLots {
+			lotsToClose = append(lotsToClose, taxLot)
+		}
+	}
+	sortLotsToClose(lotsToClose, getCostBasisMethod(user), order.TaxLotIDs)
+
+	remainingQuantity := execution.Quantity
+
+	for _, taxLot := range lotsToClose {
+		if remainingQuantity == 0 {
+			break
+		}
+
+		closeQuantity := min(remainingQuantity, taxLot.RemainingQuantity)
+		taxLot.RemainingQuantity -= closeQuantity
+		if taxLot.RemainingQuantity == 0 {
+			taxLot.ClosedAt = &execution.CreatedAt
+		}
+		if err := tx.Save(&taxLot).Error; err != nil {
+			fmt.Println("Failed to save tax lot:", err)
+			return 0, errors.New("failed to save tax lot")
+		}
+
+		realizedLot := orm.RealizedLots{
+			TaxLotID:           taxLot.TaxLotID,
+			UserID:             user.UserID,
+			StockID:            execution.StockID,
+			ExecutionID:        &execution.ExecutionID,
+			IsShort:            taxLot.IsShort,
+			Quantity:           closeQuantity,
+			CostPerShareCents:  taxLot.CostPerShareCents,
+			PricePerShareCents: execution.PricePerShareCents,
+			RealizedPnlCents:   getLotPnlCents(taxLot, closeQuantity, execution.PricePerShareCents),
+			ClosedAt:           execution.CreatedAt,
+		}
+		if err := tx.Create(&realizedLot).Error; err != nil {
+			fmt.Println("Failed to save realized lot:", err)
+			return 0, errors.New("failed to save realized lot")
+		}
+
+		remainingQuantity -= closeQuantity
+	}
+
+	if remainingQuantity > 0 {
+		taxLot := orm.TaxLots{
+			UserID:            user.UserID,
+			StockID:           execution.StockID,
+			ExecutionID:       &execution.ExecutionID,
+			IsShort:           execution.TradeType == util.TradeTypeSell,
+			Quantity:          remainingQuantity,
+			RemainingQuantity: remainingQuantity,
+			CostPerShareCents: execution.PricePerShareCents,
+			AcquiredAt:        execution.CreatedAt,
+		}
+		if err := tx.Create(&taxLot).Error; err != nil {
+			fmt.Println("Failed to save tax lot:", err)
+			return 0, errors.New("failed to save tax lot")
+		}
+	}
+
+	return getAverageLotCostCents(db.GetOpenTaxLotsByUserIdAndStockIdTx(tx, user.UserID, execution.StockID)), nil
+}
+
+// validateTaxLotIds checks that the lots picked on an order are open lots of the stock the order can close.
+func validateTaxLotIds(orderRequest model.OrderRequest) error {
+
+	if len(orderRequest.TaxLotIDs) == 0 {
+		return nil
+	}
+
+	user := db.GetUserById(orderRequest.UserID)
+	if getCostBasisMethod(user) != util.CostBasisSpecificLot {
+		return fmt.Errorf("tax lots can only be picked with the %s cost basis method", util.CostBasisSpecificLot)
+	}
+
+	stock := db.GetStockByTicker(orderRequest.Ticker)
+	openLots := db.GetOpenTaxLotsByUserIdAndStockIdTx(db.DB, user.UserID, stock.StockID)
+
+	for _, taxLotId := range orderRequest.TaxLotIDs {
+		isClosable := slices.ContainsFunc(openLots, func(taxLot orm.TaxLots) bool {
+			return taxLot.TaxLotID == taxLotId && taxLot.IsShort == (orderRequest.TradeType == util.TradeTypeBuy)
+		})
+		if !isClosable {
+			return fmt.Errorf("tax lot %d is not an open lot this order can close", taxLotId)
+		}
+	}
+
+	return nil
+}
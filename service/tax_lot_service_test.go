@@ -0,0 +1,53 @@
+package service
+
+import (
+	"slices"
+	"testing"
+	"time"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+)
+
+func TestSortLotsToClose(t *testing.T) {
+	acquiredAt := time.Date(2026, 1, 5, 15, 0, 0, 0, time.UTC)
+
+	//oldest first, the order they are read in
+	newLots := func(isShort bool) []orm.TaxLots {
+		return []orm.TaxLots{
+			{TaxLotID: 1, IsShort: isShort, CostPerShareCents: 10000, AcquiredAt: acquiredAt},
+			{TaxLotID: 2, IsShort: isShort, CostPerShareCents: 12000, AcquiredAt: acquiredAt.Add(time.Hour)},
+			{TaxLotID: 3, IsShort: isShort, CostPerShareCents: 9000, AcquiredAt: acquiredAt.Add(time.Hour)},
+			{TaxLotID: 4, IsShort: isShort, CostPerShareCents: 12000, AcquiredAt: acquiredAt.Add(2 * time.Hour)},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		isShort         bool
+		costBasisMethod string
+		taxLotIds       []int64
+		want            []int64
+	}{
+		{"FIFO", false, util.CostBasisFifo, nil, []int64{1, 2, 3, 4}},
+		{"LIFO breaks ties by the newest lot", false, util.CostBasisLifo, nil, []int64{4, 3, 2, 1}},
+		{"HIFO keeps the oldest first on ties", false, util.CostBasisHifo, nil, []int64{2, 4, 1, 3}},
+		{"HIFO short lots lowest first", true, util.CostBasisHifo, nil, []int64{3, 1, 2, 4}},
+		{"specific lots then FIFO", false, util.CostBasisSpecificLot, []int64{4, 2}, []int64{4, 2, 1, 3}},
+		{"unknown lots ignored", false, util.CostBasisSpecificLot, []int64{9, 3}, []int64{3, 1, 2, 4}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			taxLots := newLots(test.isShort)
+			sortLotsToClose(taxLots, test.costBasisMethod, test.taxLotIds)
+
+			got := make([]int64, len(taxLots))
+			for i, taxLot := range taxLots {
+				got[i] = taxLot.TaxLotID
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("sortLotsToClose = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -24,6 +24,7 @@ func GetUserByEmailAndPassword(email string, password string) model.UserModel {
 		Username:           user.Username,
 		Email:              user.Email,
 		CashBalanceDollars: util.ConvertCentsToDollars(user.CashBalanceCents),
+		BaseCurrency:       getBaseCurrency(user),
 		CreatedAt:          util.GetDateTimeString(user.CreatedAt),
 		UpdatedAt:          util.GetDateTimeString(user.UpdatedAt),
 		NotificationsOn:    user.NotificationsOn,
@@ -37,6 +38,7 @@ func GetUserById(userId int64) model.UserModel {
 		Username:           user.Username,
 		Email:              user.Email,
 		CashBalanceDollars: util.ConvertCentsToDollars(user.CashBalanceCents),
+		BaseCurrency:       getBaseCurrency(user),
 		CreatedAt:          util.GetDateTimeString(user.CreatedAt),
 		UpdatedAt:          util.GetDateTimeString(user.UpdatedAt),
 		NotificationsOn:    user.NotificationsOn,
@@ -88,7 +90,12 @@ func CreateAccount(email string, password string) (model.Auth, error) {
 			CashBalanceCents: util.InitialInvestmentCents,
 		}
 
-		err = db.DB.Create(&user).Error
+		err = tx.Create(&user).Error
+		if err != nil {
+			return err
+		}
+
+		err = postDepositJournal(tx, user.UserID, util.InitialInvestmentCents, "Initial investment", user.CreatedAt)
 		if err != nil {
 			return err
 		}
@@ -125,11 +132,31 @@ func UpdateUserSettings(userId int64, userSettings map[string]interface{}) (mode
 		switch key {
 		case "notifications":
 			user.NotificationsOn = value.(bool)
+		case "costBasisMethod":
+			costBasisMethod := value.(string)
+			switch costBasisMethod {
+			case util.CostBasisFifo, util.CostBasisLifo, util.CostBasisHifo, util.CostBasisSpecificLot:
+				user.CostBasisMethod = costBasisMethod
+			default:
+				return userModel, errors.New("unknown cost basis method " + costBasisMethod)
+			}
+		case "dripEnabled":
+			user.DripEnabled = value.(bool)
+		case "baseCurrency":
+			baseCurrency := value.(string)
+			if _, ok := getFxRates()[baseCurrency]; !ok {
+				return userModel, errors.New("unknown currency " + baseCurrency)
+			}
+			user.BaseCurrency = baseCurrency
 		}
 	}
 
 	userModel.UserID = userId
 	userModel.NotificationsOn = user.NotificationsOn
+	userModel.FeeSchedule = getFeeSchedule(user).Name
+	userModel.CostBasisMethod = getCostBasisMethod(user)
+	userModel.DripEnabled = user.DripEnabled
+	userModel.BaseCurrency = getBaseCurrency(user)
 	userModel.Email = user.Email
 	userModel.Username = user.Username
 	userModel.CashBalanceDollars = util.ConvertCentsToDollars(user.CashBalanceCents)
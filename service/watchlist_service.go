@@ -0,0 +1,186 @@
+package service
+
+import (
+	"fmt"
+	"time"
+	"trading_platform_backend/db"
+	"trading_platform_backend/model"
+	"trading_platform_backend/orm"
+	"trading_platform_backend/util"
+)
+
+// ProcessWatchlistAlerts alerts the watchers of a stock whose target price the move from previousPriceCents to the
+// stock's current price crossed, in either direction. A ONCE watch is deactivated, a REARM watch alerts again on the
+// next crossing. A watch with a pending action places its trade on the first crossing, or once the regular session
+// opens when market orders are not accepted. The notifications are saved for every watcher, those of the users with
+// notifications on are returned to push.
+func ProcessWatchlistAlerts(stock orm.Stocks, previousPriceCents int64, now time.Time) []model.NotificationModel {
+
+	notifications := make([]model.NotificationModel, 0)
+
+	priceCents := stock.CurrentPriceCents
+	if previousPriceCents <= 0 || priceCents == previousPriceCents {
+		return notifications
+	}
+
+	for _, watch := range db.GetActiveStockWatchlistByStockIdAndTargetRange(stock.StockID,
+		min(previousPriceCents, priceCents), max(previousPriceCents, priceCents)) {
+
+		//a price resting on the target crossed it on the move that reached it
+		if watch.TargetPriceCents == previousPriceCents {
+			continue
+		}
+
+		updates := map[string]interface{}{"last_alerted_at": now}
+		if watch.AlertPolicy != util.WatchAlertPolicyRearm {
+			updates["is_active"] = false
+		}
+
+		result := db.DB.Model(&orm.StockWatchlist{}).
+			Where("stock_watchlist_id = ? and is_active = true", watch.StockWatchlistID).
+			Updates(updates)
+		if result.Error != nil {
+			fmt.Printf("Failed to update watch %d, %s\n", watch.StockWatchlistID, result.Error.Error())
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		direction := "above"
+		if priceCents < previousPriceCents {
+			direction = "below"
+		}
+		currency := getStockCurrency(stock)
+
+		notification := model.NotificationModel{
+			UserID:    int64(watch.UserId),
+			EventType: util.NotificationEventWatchlistAlert,
+			Message: fmt.Sprintf("%s crossed %s your target price of %.2f %s, now at %.2f %s", stock.Ticker, direction,
+				util.ConvertCentsToDollars(watch.TargetPriceCents), currency, util.ConvertCentsToDollars(priceCents), currency),
+		}
+		if watch.ActionStatus == util.WatchActionStatusPending {
+			//left pending, RunTriggeredWatchActions places it when the regular session opens
+			if !isOrderTypeAccepted(GetCurrentMarketSession(), util.OrderTypeMarket) {
+				notification.Message += ". The watch's trade will be placed when the regular session opens"
+			} else {
+				orderId, actionMessage := runWatchAction(watch, stock, now)
+				notification.Message += ". " + actionMessage
+				notification.OrderID = orderId
+			}
+		}
+
+		if saveWatchNotification(&notification, now) {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	return notifications
+}
+
+// RunTriggeredWatchActions places the trades of the watches whose price crossed the target while market orders were
+// not accepted. The notifications are saved for every watcher, those of the users with notifications on are returned
+// to push.
+func RunTriggeredWatchActions(now time.Time) []model.NotificationModel {
+
+	notifications := make([]model.NotificationModel, 0)
+
+	if !isOrderTypeAccepted(GetCurrentMarketSession(), util.OrderTypeMarket) {
+		return notifications
+	}
+
+	stocksById := getStocksById()
+
+	for _, watch := range db.GetStockWatchlistWithTriggeredActions() {
+
+		stock := stocksById[int64(watch.StockId)]
+		orderId, actionMessage := runWatchAction(watch, stock, now)
+
+		notification := model.NotificationModel{
+			UserID:    int64(watch.UserId),
+			EventType: util.NotificationEventWatchlistAlert,
+			Message: fmt.Sprintf("%s reached your target price of %.2f %s. %s", stock.Ticker,
+				util.ConvertCentsToDollars(watch.TargetPriceCents), getStockCurrency(stock), actionMessage),
+			OrderID: orderId,
+		}
+
+		if saveWatchNotification(&notification, now) {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	return notifications
+}
+
+// saveWatchNotification saves the notification and reports whether to push it, when its user has notifications on.
+func saveWatchNotification(notification *model.NotificationModel, now time.Time) bool {
+
+	if err := createNotification(db.DB, notification, now); err != nil {
+		fmt.Printf("Failed to save watchlist alert of user %d, %s\n", notification.UserID, err.Error())
+		return false
+	}
+
+	return db.GetUserById(notification.UserID).NotificationsOn
+}
+
+// runWatchAction places the trade of a watch through the market order path and records its outcome on the watch.
+// It returns the order placed, 0 when the trade failed, and the outcome for the user.
+func runWatchAction(watch orm.StockWatchlist, stock orm.Stocks, now time.Time) (int64, string) {
+
+	userId := int64(watch.UserId)
+	quantity := watch.ActionQuantity
+
+	var order orm.Orders
+	errMessage := ""
+
+	tradeType := util.TradeTypeBuy
+	if watch.ActionType != util.WatchActionBuy {
+		tradeType = util.TradeTypeSell
+		//the shares reserved by pending sells are already being sold
+		quantity = db.GetHoldingByUserIdAndStockId(userId, stock.StockID).Quantity -
+			db.GetReservedSellQuantityByUserIdAndStockIdTx(db.DB, userId, stock.StockID)
+	}
+
+	if quantity <= 0 {
+		errMessage = "Failed to sell stock, no shares held outside pending sell orders"
+	} else if err := checkOrderRisk(model.OrderRequest{
+		UserID:    userId,
+		Ticker:    stock.Ticker,
+		TradeType: tradeType,
+		OrderType: util.OrderTypeMarket,
+		Quantity:  quantity,
+	}); err != nil {
+		errMessage = err.Error()
+	} else if tradeType == util.TradeTypeBuy {
+		order, errMessage = BuyStocksFractional(userId, stock.Ticker, quantity, nil)
+	} else {
+		order, errMessage = SellStocksFractional(userId, stock.Ticker, quantity, nil)
+	}
+
+	updates := map[string]interface{}{
+		"action_status":      util.WatchActionStatusExecuted,
+		"action_message":     errMessage,
+		"action_executed_at": now,
+	}
+
+	outcome := errMessage
+	if errMessage != "" {
+		updates["action_status"] = util.WatchActionStatusFailed
+	} else {
+		updates["action_order_id"] = order.OrderID
+		if watch.ActionType == util.WatchActionBuy {
+			outcome = fmt.Sprintf("Bought %g shares, order %d", util.ConvertQuantityToShares(quantity), order.OrderID)
+		} else {
+			outcome = fmt.Sprintf("Sold %g shares, order %d", util.ConvertQuantityToShares(quantity), order.OrderID)
+		}
+	}
+
+	err := db.DB.Model(&orm.StockWatchlist{}).
+		Where("stock_watchlist_id = ? and action_status = ?", watch.StockWatchlistID, util.WatchActionStatusPending).
+		Updates(updates).Error
+	if err != nil {
+		fmt.Printf("Failed to save action of watch %d, %s\n", watch.StockWatchlistID, err.Error())
+	}
+
+	return order.OrderID, outcome
+}
@@ -1,14 +1,222 @@
 package util
 
+import "time"
+
 const (
 	TradeTypeBuy  = "BUY"
 	TradeTypeSell = "SELL"
 )
 
+// Stop orders start as AWAITING_TRIGGER, move to PENDING as a market or limit order once
+// the stop price is crossed, and end as EXECUTED (or FAILED).
+const (
+	OrderStatusExecuted        = "EXECUTED"
+	OrderStatusCompleted       = "COMPLETED"
+	OrderStatusPending         = "PENDING"
+	OrderStatusPartiallyFilled = "PARTIALLY_FILLED" // pending order with part of its quantity filled
+	OrderStatusAwaitingTrigger = "AWAITING_TRIGGER"
+	OrderStatusInactive        = "INACTIVE" // bracket child waiting for its parent to fill
+	OrderStatusCanceled        = "CANCELED"
+	OrderStatusExpired         = "EXPIRED"
+	OrderStatusFailed          = "FAILED"
+	OrderStatusQueued          = "QUEUED" // placed while its order type is not accepted, released when a session accepts it
+)
+
+// FillableOrderStatuses are the statuses of orders that can still get fills.
+var FillableOrderStatuses = []string{OrderStatusPending, OrderStatusPartiallyFilled}
+
+const (
+	OrderTypeMarket       = "MARKET"
+	OrderTypeLimit        = "LIMIT"
+	OrderTypeStop         = "STOP"
+	OrderTypeStopLimit    = "STOP_LIMIT"
+	OrderTypeTrailingStop = "TRAILING_STOP"
+)
+
+const (
+	OrderGroupTypeBracket = "BRACKET"
+	OrderGroupTypeOco     = "OCO"
+	OrderGroupTypeBasket  = "BASKET"
+)
+
+// A basket of market orders either executes every leg or none (ALL_OR_NONE), or executes each leg it can (BEST_EFFORT).
+const (
+	BasketModeAllOrNone  = "ALL_OR_NONE"
+	BasketModeBestEffort = "BEST_EFFORT"
+	MaxBasketLegs        = 20
+)
+
+// How often a target allocation is rebalanced on its own, NONE rebalances on demand only.
+const (
+	RebalanceFrequencyNone    = "NONE"
+	RebalanceFrequencyDaily   = "DAILY"
+	RebalanceFrequencyWeekly  = "WEEKLY"
+	RebalanceFrequencyMonthly = "MONTHLY"
+)
+
+const (
+	DefaultDriftThresholdPercent = 5.0 // percentage points
+	DefaultMinTradeCents         = 100 // $1
+)
+
+const (
+	RecurringCadenceDaily    = "DAILY"
+	RecurringCadenceWeekly   = "WEEKLY"
+	RecurringCadenceBiweekly = "BIWEEKLY"
+	RecurringCadenceMonthly  = "MONTHLY"
+)
+
+const (
+	RecurringInvestmentStatusActive = "ACTIVE"
+	RecurringInvestmentStatusPaused = "PAUSED"
+)
+
+// Outcome of the last run of a recurring investment
+const (
+	RecurringRunExecuted = "EXECUTED"
+	RecurringRunSkipped  = "SKIPPED" // not enough cash
+	RecurringRunFailed   = "FAILED"
+)
+
+// What a watch does once the price crosses its target
+const (
+	WatchAlertPolicyOnce  = "ONCE"  // alert, then deactivate the watch
+	WatchAlertPolicyRearm = "REARM" // alert, and again on every later crossing
+)
+
+// Trade a watch places the first time the price crosses its target
+const (
+	WatchActionBuy     = "BUY"      // action_quantity shares
+	WatchActionSellAll = "SELL_ALL" // the whole long position
+)
+
+const (
+	WatchActionStatusPending  = "PENDING" // waiting for the target
+	WatchActionStatusExecuted = "EXECUTED"
+	WatchActionStatusFailed   = "FAILED"
+)
+
+const (
+	TimeInForceDay = "DAY" // expires at the regular session close
+	TimeInForceGTC = "GTC" // good till canceled
+	TimeInForceIOC = "IOC" // immediate or cancel
+	TimeInForceFOK = "FOK" // fill or kill
+)
+
+const (
+	NotificationEventStopTriggered       = "STOP_TRIGGERED"
+	NotificationEventMarginCall          = "MARGIN_CALL"
+	NotificationEventForcedLiquidation   = "FORCED_LIQUIDATION"
+	NotificationEventStockSplit          = "STOCK_SPLIT"
+	NotificationEventDividend            = "DIVIDEND"
+	NotificationEventOptionExercised     = "OPTION_EXERCISED"
+	NotificationEventOptionExpired       = "OPTION_EXPIRED"
+	NotificationEventRebalance           = "REBALANCE"
+	NotificationEventRecurringInvestment = "RECURRING_INVESTMENT"
+	NotificationEventWatchlistAlert      = "WATCHLIST_ALERT"
+)
+
+const OrderBookDepthLevels = 10
+
+// Sessions of a trading day, in order. Weekends and holidays are CLOSED all day.
+const (
+	MarketSessionPreMarket  = "PRE_MARKET"  // 4:00 to 9:30, limit orders only
+	MarketSessionRegular    = "REGULAR"     // 9:30 to 16:00 (13:00 on early close days)
+	MarketSessionAfterHours = "AFTER_HOURS" // regular close to 20:00, limit orders only
+	MarketSessionClosed     = "CLOSED"
+)
+
+// Order in which the fills closing a position consume its tax lots
+const (
+	CostBasisFifo        = "FIFO"         // oldest lot first
+	CostBasisLifo        = "LIFO"         // newest lot first
+	CostBasisHifo        = "HIFO"         // highest cost first, lowest sale price first for short lots
+	CostBasisSpecificLot = "SPECIFIC_LOT" // lots picked on the order, then FIFO
+)
+
+const (
+	DefaultInitialMarginPercent     = 50.0
+	DefaultMaintenanceMarginPercent = 30.0
+	MarginCallGracePeriod           = 2 * time.Minute // time to cure a margin call before positions are liquidated
+)
+
+const (
+	IdempotencyKeyHeader    = "Idempotency-Key"
+	IdempotencyKeyMaxLength = 255
+	IdempotencyKeyTTL       = 24 * time.Hour // a retry after this executes the request again
+)
+
+// Reason codes returned in ApiResponse.ErrorCode when the pre-trade risk checks reject an order
+const (
+	RiskReasonMaxOrderNotional = "MAX_ORDER_NOTIONAL"
+	RiskReasonMaxPositionSize  = "MAX_POSITION_SIZE"
+	RiskReasonConcentration    = "CONCENTRATION_LIMIT"
+	RiskReasonMaxShortShares   = "MAX_SHORT_SHARES"
+	RiskReasonFatFinger        = "FAT_FINGER_PRICE"
+)
+
+const (
+	MaxOrderNotionalCents   = 25000000 // $250,000 per order
+	MaxPositionShares       = 10000    // long or short, per stock
+	MaxConcentrationPercent = 60.0     // position value as a percent of the account equity
+	MaxShortShares          = 5000     // per stock
+	FatFingerPercent        = 10.0     // max distance of a limit price from the current price
+)
+
+const (
+	JournalEventTrade           = "TRADE"
+	JournalEventDeposit         = "DEPOSIT"
+	JournalEventCorporateAction = "CORPORATE_ACTION"
+	JournalEventOpeningBalance  = "OPENING_BALANCE"
+	JournalEventOptionTrade     = "OPTION_TRADE"
+	JournalEventFxConversion    = "FX_CONVERSION"
+)
+
+// Journal accounts, cash entries are in cents of their currency and securities entries in millionths of a share. The
+// user's balances are the debits minus the credits of USER_CASH and USER_SECURITIES, the other accounts are the contra sides.
+const (
+	LedgerAccountUserCash         = "USER_CASH"
+	LedgerAccountUserSecurities   = "USER_SECURITIES"
+	LedgerAccountMarket           = "MARKET" // counterparty of every trade
+	LedgerAccountFees             = "FEES"
+	LedgerAccountDeposits         = "DEPOSITS"
+	LedgerAccountCorporateActions = "CORPORATE_ACTIONS"
+	LedgerAccountOpeningBalance   = "OPENING_BALANCE"
+	LedgerAccountFx               = "FX" // counterparty of currency conversions
+)
+
+const (
+	CorporateActionSplit        = "SPLIT"
+	CorporateActionReverseSplit = "REVERSE_SPLIT"
+	CorporateActionCashDividend = "CASH_DIVIDEND"
+)
+
+// Splits go from SCHEDULED to COMPLETED on their ex date, cash dividends record their holders
+// on the ex date (RECORDED) and are COMPLETED once paid on the pay date.
+const (
+	CorporateActionStatusScheduled = "SCHEDULED"
+	CorporateActionStatusRecorded  = "RECORDED"
+	CorporateActionStatusCompleted = "COMPLETED"
+	CorporateActionStatusCanceled  = "CANCELED"
+)
+
+const (
+	OptionTypeCall = "CALL"
+	OptionTypePut  = "PUT"
+)
+
+// Contracts are ACTIVE until the regular close of their expiration date, when in the money
+// long positions are exercised and the contract becomes EXPIRED.
+const (
+	OptionContractStatusActive  = "ACTIVE"
+	OptionContractStatusExpired = "EXPIRED"
+)
+
 const (
-	OrderStatusExecuted  = "EXECUTED"
-	OrderStatusCompleted = "COMPLETED"
-	OrderStatusPending   = "PENDING"
-	OrderStatusCanceled  = "CANCELED"
-	OrderStatusFailed    = "FAILED"
+	OptionContractMultiplier = 100   // shares of the underlying delivered per contract, until adjusted by a split
+	OptionSpreadBps          = 200.0 // bid/ask spread of option premiums
+	OptionStrikesPerSide     = 5     // strikes listed above and below the at the money strike
+	OptionWeeklyExpirations  = 4     // next Fridays listed
+	OptionMonthlyExpirations = 3     // third Fridays of the following months listed
+	RiskFreeRate             = 0.04  // annualized, continuously compounded
 )
@@ -1,9 +1,32 @@
 package util
 
+import "math"
+
+// InitialInvestmentCents is the USD deposit every new account starts with.
 const InitialInvestmentCents = 10000000
 
+// Cash is int64 cents of its currency. USD is the settlement currency: users.cash_balance_cents is the USD balance
+// and every other currency is held in cash_balances.
+const CurrencyUsd = "USD"
+
+// FX rates are fixed-point int64 USD per unit of a currency, in millionths.
+const FxRateScale = 1000000
+
 func ConvertCentsToDollars(cents int64) float64 {
 	dollars := float64(cents) / 100.0
 	truncated := float64(int(dollars*100)) / 100.0
 	return truncated
 }
+
+func ConvertDollarsToCents(dollars float64) int64 {
+	return int64(math.Round(dollars * 100))
+}
+
+// ConvertCurrencyCents converts amountCents of a currency worth fromRateMicros to a currency worth toRateMicros,
+// rounded half away from zero to the cent.
+func ConvertCurrencyCents(amountCents int64, fromRateMicros int64, toRateMicros int64) int64 {
+	if fromRateMicros == toRateMicros || toRateMicros <= 0 {
+		return amountCents
+	}
+	return int64(math.Round(float64(amountCents) * float64(fromRateMicros) / float64(toRateMicros)))
+}
@@ -1,7 +1,52 @@
 package util
 
-import "time"
+import (
+	"time"
+	_ "time/tzdata" // embedded so the market time zone loads on hosts without zoneinfo
+)
+
+// Session boundaries in minutes after midnight, market time
+const (
+	MarketTimeZone        = "America/New_York"
+	PreMarketOpenMinute   = 4 * 60
+	RegularOpenMinute     = 9*60 + 30
+	RegularCloseMinute    = 16 * 60
+	EarlyCloseMinute      = 13 * 60 // regular close on early close holidays
+	AfterHoursCloseMinute = 20 * 60
+)
+
+var marketLocation = loadMarketLocation()
+
+func loadMarketLocation() *time.Location {
+	location, err := time.LoadLocation(MarketTimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
 
 func GetDateTimeString(time time.Time) string {
 	return time.Format("01-02-2006 15:04:05")
 }
+
+func GetDateString(time time.Time) string {
+	return time.Format("2006-01-02")
+}
+
+// GetMarketDate returns midnight, market time, of the market day t falls on.
+func GetMarketDate(t time.Time) time.Time {
+	marketTime := t.In(marketLocation)
+	return time.Date(marketTime.Year(), marketTime.Month(), marketTime.Day(), 0, 0, 0, 0, marketLocation)
+}
+
+// GetMarketMinute returns the minutes elapsed since midnight, market time.
+func GetMarketMinute(t time.Time) int {
+	marketTime := t.In(marketLocation)
+	return marketTime.Hour()*60 + marketTime.Minute()
+}
+
+// GetMarketTimeAt returns the time minute minutes after midnight, market time, on the market day date.
+func GetMarketTimeAt(date time.Time, minute int) time.Time {
+	marketDate := date.In(marketLocation)
+	return time.Date(marketDate.Year(), marketDate.Month(), marketDate.Day(), 0, minute, 0, 0, marketLocation)
+}
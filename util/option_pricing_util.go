@@ -0,0 +1,66 @@
+package util
+
+import "math"
+
+// OptionGreeks are per share of the underlying, for a one dollar move of the underlying price,
+// theta per calendar day and vega and rho per percentage point.
+type OptionGreeks struct {
+	Delta float64
+	Gamma float64
+	Theta float64
+	Vega  float64
+	Rho   float64
+}
+
+// GetBlackScholesPrice returns the Black-Scholes premium in cents of a European option on one share and its Greeks.
+// At expiry, or without volatility, the option is worth its intrinsic value.
+func GetBlackScholesPrice(isCall bool, spotCents int64, strikeCents int64, yearsToExpiry float64, volatility float64) (float64, OptionGreeks) {
+	spot := float64(spotCents) / 100
+	strike := float64(strikeCents) / 100
+	if yearsToExpiry <= 0 || volatility <= 0 || spot <= 0 || strike <= 0 {
+		var greeks OptionGreeks
+		if isCall {
+			if spot > strike {
+				greeks.Delta = 1
+			}
+			return math.Max(spot-strike, 0) * 100, greeks
+		}
+		if strike > spot {
+			greeks.Delta = -1
+		}
+		return math.Max(strike-spot, 0) * 100, greeks
+	}
+
+	sqrtT := math.Sqrt(yearsToExpiry)
+	d1 := (math.Log(spot/strike) + (RiskFreeRate+volatility*volatility/2)*yearsToExpiry) / (volatility * sqrtT)
+	d2 := d1 - volatility*sqrtT
+	discountedStrike := strike * math.Exp(-RiskFreeRate*yearsToExpiry)
+	density := normalDensity(d1)
+
+	greeks := OptionGreeks{
+		Gamma: density / (spot * volatility * sqrtT),
+		Vega:  spot * density * sqrtT / 100,
+	}
+	decay := -spot * density * volatility / (2 * sqrtT)
+	var price float64
+	if isCall {
+		price = spot*normalCdf(d1) - discountedStrike*normalCdf(d2)
+		greeks.Delta = normalCdf(d1)
+		greeks.Theta = (decay - RiskFreeRate*discountedStrike*normalCdf(d2)) / 365
+		greeks.Rho = yearsToExpiry * discountedStrike * normalCdf(d2) / 100
+	} else {
+		price = discountedStrike*normalCdf(-d2) - spot*normalCdf(-d1)
+		greeks.Delta = normalCdf(d1) - 1
+		greeks.Theta = (decay + RiskFreeRate*discountedStrike*normalCdf(-d2)) / 365
+		greeks.Rho = -yearsToExpiry * discountedStrike * normalCdf(-d2) / 100
+	}
+	return math.Max(price, 0) * 100, greeks
+}
+
+func normalCdf(x float64) float64 {
+	return 0.5 * (1 + math.Erf(x/math.Sqrt2))
+}
+
+func normalDensity(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
@@ -0,0 +1,50 @@
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGetBlackScholesPrice(t *testing.T) {
+	tests := []struct {
+		name          string
+		isCall        bool
+		spotCents     int64
+		strikeCents   int64
+		yearsToExpiry float64
+		volatility    float64
+		wantCents     float64
+		wantDelta     float64
+	}{
+		{"at the money call", true, 10000, 10000, 1, 0.2, 992.505, 0.617911},
+		{"at the money put", false, 10000, 10000, 1, 0.2, 600.400, -0.382089},
+		{"in the money call", true, 11000, 10000, 0.5, 0.3, 1603.625, 0.742039},
+		{"in the money put", false, 9000, 10000, 0.25, 0.25, 1051.680, -0.758156},
+		{"expired call in the money", true, 11000, 10000, 0, 0.2, 1000, 1},
+		{"expired call out of the money", true, 9000, 10000, 0, 0.2, 0, 0},
+		{"put without volatility", false, 9000, 10000, 1, 0, 1000, -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotCents, greeks := GetBlackScholesPrice(test.isCall, test.spotCents, test.strikeCents, test.yearsToExpiry, test.volatility)
+			if math.Abs(gotCents-test.wantCents) > 0.001 {
+				t.Errorf("price = %f cents, want %f", gotCents, test.wantCents)
+			}
+			if math.Abs(greeks.Delta-test.wantDelta) > 0.000001 {
+				t.Errorf("delta = %f, want %f", greeks.Delta, test.wantDelta)
+			}
+		})
+	}
+}
+
+func TestGetBlackScholesPricePutCallParity(t *testing.T) {
+	callCents, _ := GetBlackScholesPrice(true, 10500, 10000, 0.75, 0.35)
+	putCents, _ := GetBlackScholesPrice(false, 10500, 10000, 0.75, 0.35)
+
+	//C - P = S - K e^(-rT)
+	wantCents := 10500 - 10000*math.Exp(-RiskFreeRate*0.75)
+	if math.Abs(callCents-putCents-wantCents) > 0.000001 {
+		t.Errorf("call - put = %f cents, want %f", callCents-putCents, wantCents)
+	}
+}
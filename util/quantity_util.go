@@ -0,0 +1,38 @@
+package util
+
+import "math"
+
+// Quantities are fixed-point int64 in millionths of a share, the way money is int64 cents.
+const ShareScale = 1000000
+
+// ConvertSharesToQuantity rounds shares to the nearest millionth of a share.
+func ConvertSharesToQuantity(shares float64) int64 {
+	return int64(math.Round(shares * ShareScale))
+}
+
+func ConvertQuantityToShares(quantity int64) float64 {
+	return float64(quantity) / ShareScale
+}
+
+// GetValueCents returns the value of quantity at pricePerShareCents, rounded half away from zero to the cent.
+func GetValueCents(quantity int64, pricePerShareCents int64) int64 {
+	valueMicroCents := quantity * pricePerShareCents
+	if valueMicroCents < 0 {
+		return -((-valueMicroCents + ShareScale/2) / ShareScale)
+	}
+	return (valueMicroCents + ShareScale/2) / ShareScale
+}
+
+// GetQuantityForAmount returns the quantity amountCents buys at pricePerShareCents, rounded down to the millionth
+// of a share so its value never exceeds the amount.
+func GetQuantityForAmount(amountCents int64, pricePerShareCents int64) int64 {
+	if pricePerShareCents <= 0 {
+		return 0
+	}
+	return amountCents * ShareScale / pricePerShareCents
+}
+
+// IsWholeQuantity reports whether quantity is a whole number of shares.
+func IsWholeQuantity(quantity int64) bool {
+	return quantity%ShareScale == 0
+}
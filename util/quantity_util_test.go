@@ -0,0 +1,55 @@
+package util
+
+import "testing"
+
+func TestGetValueCents(t *testing.T) {
+	tests := []struct {
+		name               string
+		quantity           int64
+		pricePerShareCents int64
+		want               int64
+	}{
+		{"whole shares", 3 * ShareScale, 12345, 37035},
+		{"fractional shares", 1500000, 1001, 1502},
+		{"rounds half up", 500000, 1, 1},
+		{"rounds down below half", 499999, 1, 0},
+		{"negative rounds half away from zero", -500000, 1, -1},
+		{"negative rounds toward zero below half", -499999, 1, 0},
+		{"zero quantity", 0, 12345, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := GetValueCents(test.quantity, test.pricePerShareCents); got != test.want {
+				t.Errorf("GetValueCents(%d, %d) = %d, want %d", test.quantity, test.pricePerShareCents, got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetQuantityForAmount(t *testing.T) {
+	tests := []struct {
+		name               string
+		amountCents        int64
+		pricePerShareCents int64
+		want               int64
+	}{
+		{"whole shares", 30000, 10000, 3 * ShareScale},
+		{"rounds down to the millionth", 10000, 30000, 333333},
+		{"less than a millionth", 1, 1000000000, 0},
+		{"zero price", 10000, 0, 0},
+		{"negative price", 10000, -100, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := GetQuantityForAmount(test.amountCents, test.pricePerShareCents)
+			if got != test.want {
+				t.Errorf("GetQuantityForAmount(%d, %d) = %d, want %d", test.amountCents, test.pricePerShareCents, got, test.want)
+			}
+			if test.pricePerShareCents > 0 && GetValueCents(got, test.pricePerShareCents) > test.amountCents {
+				t.Errorf("quantity %d is worth more than %d cents", got, test.amountCents)
+			}
+		})
+	}
+}
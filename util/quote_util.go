@@ -0,0 +1,13 @@
+package util
+
+import "math"
+
+// GetBidAskCents splits spreadBps (basis points of the mid price) around the mid price,
+// keeping at least one cent on each side.
+func GetBidAskCents(midPriceCents int64, spreadBps float64) (int64, int64) {
+	halfSpreadCents := int64(math.Round(float64(midPriceCents) * spreadBps / 20000))
+	if halfSpreadCents < 1 {
+		halfSpreadCents = 1
+	}
+	return midPriceCents - halfSpreadCents, midPriceCents + halfSpreadCents
+}